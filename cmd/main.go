@@ -4,8 +4,16 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/jaypaulb/CanvusNoteMapper/internal/api"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/config"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/imports"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/jobs"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/llm"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/scans"
 	"github.com/joho/godotenv"
 )
 
@@ -18,6 +26,69 @@ func main() {
 	}
 	log.Printf("[main] GOOGLE_GENAI_API_KEY loaded: %v", os.Getenv("GOOGLE_GENAI_API_KEY") != "")
 
+	// Load persisted settings (config file + environment overrides)
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("[main] Failed to load config: %v", err)
+	}
+	log.Printf("[main] MCS server configured: %v, API key set: %v", cfg.MCSServer != "", cfg.APIKey != "")
+	if err := api.ApplyTLSConfig(cfg.TLS); err != nil {
+		// Keep running with the system trust store so the settings can be fixed from the UI
+		log.Printf("[main] Invalid TLS settings, using system defaults: %v", err)
+	}
+
+	// Select the note extraction backend
+	extractor, err := llm.NewExtractor(llm.ExtractorConfig{
+		Provider: cfg.LLM.Provider,
+		Model:    cfg.LLM.Model,
+		APIKey:   cfg.LLM.APIKey,
+		BaseURL:  cfg.LLM.BaseURL,
+	})
+	if err != nil {
+		log.Fatalf("[main] Failed to configure LLM extractor: %v", err)
+	}
+	// Without a working model, notes are still found by colour, without text
+	extractor = llm.WithDetectorFallback(extractor)
+	moded, err := llm.WithMode(extractor, cfg.LLM.Mode)
+	if err != nil {
+		log.Fatalf("[main] Failed to configure LLM extractor: %v", err)
+	}
+	api.SetExtractor(extractor)
+	log.Printf("[main] Using LLM extractor: %s", moded.Name())
+
+	// Start the scan job worker pool
+	workers := jobs.DefaultWorkers
+	if v := os.Getenv("SCAN_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			workers = n
+		} else {
+			log.Printf("[main] Ignoring invalid SCAN_WORKERS=%q", v)
+		}
+	}
+	api.SetJobManager(jobs.NewManager(workers))
+
+	// Per-session scan state, evicted after SCAN_TTL of inactivity
+	scanTTL := scans.DefaultTTL
+	if v := os.Getenv("SCAN_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			scanTTL = d
+		} else {
+			log.Printf("[main] Ignoring invalid SCAN_TTL=%q", v)
+		}
+	}
+	api.SetScanStore(scans.NewStore(scanTTL))
+
+	// Imports are saved next to the config file, so undo and sync survive a restart
+	importsFile := os.Getenv("IMPORTS_FILE")
+	if importsFile == "" {
+		importsFile = filepath.Join(filepath.Dir(config.Path()), imports.DefaultFile)
+	}
+	importLog, err := imports.OpenStore(importsFile, imports.DefaultMaxImports)
+	if err != nil {
+		log.Fatalf("[main] Failed to load imports: %v", err)
+	}
+	api.SetImportStore(importLog)
+
 	// Get port from environment variable or use default
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -30,11 +101,21 @@ func main() {
 	mux.HandleFunc("/api/upload-image", api.UploadImageHandler)
 	mux.HandleFunc("/api/scan-notes", api.ScanNotesHandler)
 	mux.HandleFunc("/api/create-notes", api.CreateNotesHandler)
+	mux.HandleFunc("POST /api/sync-notes", api.SyncNotesHandler)
+	mux.HandleFunc("POST /api/create-anchor", api.CreateAnchorHandler)
 	mux.HandleFunc("/api/set-credentials", api.SetCredentialsHandler)
+	mux.HandleFunc("/api/get-config", api.GetConfigHandler)
+	mux.HandleFunc("POST /api/test-connection", api.TestConnectionHandler)
 	mux.HandleFunc("/api/get-canvas-size", api.GetCanvasSizeHandler)
 	mux.HandleFunc("/api/get-canvases", api.GetCanvasesHandler)
 	mux.HandleFunc("/api/get-anchors", api.GetAnchorsOnlyHandler)
 	mux.HandleFunc("/api/get-anchor-info", api.GetAnchorInfoHandler)
+	mux.HandleFunc("GET /api/canvases/{id}/events", api.CanvasEventsHandler)
+	mux.HandleFunc("GET /api/imports", api.ImportsHandler)
+	mux.HandleFunc("POST /api/imports/{id}/undo", api.UndoImportHandler)
+	mux.HandleFunc("GET /api/jobs/{id}", api.JobHandler)
+	mux.HandleFunc("GET /api/jobs/{id}/events", api.JobEventsHandler)
+	mux.HandleFunc("POST /api/jobs/{id}/cancel", api.CancelJobHandler)
 
 	// Serve static files from web directory
 	fileServer := http.FileServer(http.Dir("web"))
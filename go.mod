@@ -4,6 +4,8 @@ go 1.24.1
 
 require github.com/google/generative-ai-go v0.19.0 // Gemini Go SDK for LLM integration
 
+require golang.org/x/image v0.24.0 // WebP decoding
+
 require (
 	cloud.google.com/go/ai v0.8.0 // indirect
 	cloud.google.com/go/auth v0.16.0 // indirect
@@ -30,7 +32,7 @@ require (
 	golang.org/x/sys v0.32.0 // indirect
 	golang.org/x/text v0.24.0 // indirect
 	golang.org/x/time v0.11.0 // indirect
-	google.golang.org/api v0.230.0 // indirect
+	google.golang.org/api v0.230.0
 	google.golang.org/genproto v0.0.0-20240528184218-531527333157 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250218202821-56aae31c358a // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250414145226-207652e42e2e // indirect
@@ -38,8 +40,9 @@ require (
 	google.golang.org/protobuf v1.36.6 // indirect
 )
 
+require github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
+
 require (
 	cloud.google.com/go v0.115.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
-	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646 // indirect
 )
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/config"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/imports"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/mcs"
+)
+
+// anchorMargin is the space kept clear around a new anchor, as a fraction of its width
+const anchorMargin = 0.05
+
+// POST /api/create-anchor
+// Takes the same request as /api/create-notes without a zoneID. Creates an
+// anchor with the photo's aspect ratio in free space on the canvas and
+// imports the notes into it. The anchor is removed again if no note could
+// be created, and undoing the import deletes it along with the notes.
+func CreateAnchorHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("[CreateAnchorHandler] Called /api/create-anchor")
+	req, ok := decodeNotes(w, r, "CreateAnchorHandler")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := req.SourceImage.validate(req.ScanID); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if req.CanvasID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"canvasID required"}`))
+		return
+	}
+	if req.AnchorWidth < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"anchorWidth must be positive"}`))
+		return
+	}
+
+	// The anchor has the photo's aspect ratio, at its pixel size unless a width is given
+	size := &canvusapi.Size{Width: req.ImageWidth, Height: req.ImageHeight}
+	if req.AnchorWidth > 0 {
+		size = &canvusapi.Size{Width: req.AnchorWidth, Height: req.AnchorWidth * req.ImageHeight / req.ImageWidth}
+	}
+	name := req.AnchorName
+	if name == "" {
+		name = "Notes " + time.Now().Format("2006-01-02 15:04")
+	}
+
+	// A photo placed beside the anchor needs room too
+	reserved := size.Width
+	if req.SourceImage != nil && req.SourceImage.Position == sourceImageBeside {
+		reserved = size.Width * (2 + besideGap)
+	}
+	cfg := config.GetConfig()
+	client := mcs.NewClient(cfg.MCSServer, cfg.APIKey, req.CanvasID)
+	location, err := client.FindFreeSpace(r.Context(), req.CanvasID, reserved, size.Height, size.Width*anchorMargin)
+	if err != nil {
+		log.Printf("[CreateAnchorHandler] No place for a %.0fx%.0f anchor: %v", reserved, size.Height, err)
+		status := http.StatusBadGateway
+		if errors.Is(err, mcs.ErrNoFreeSpace) {
+			status = http.StatusConflict
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	anchor, err := client.CreateAnchor(r.Context(), req.CanvasID, name, location, size)
+	if err != nil {
+		log.Printf("[CreateAnchorHandler] Failed to create anchor: %v", err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create anchor: " + err.Error()})
+		return
+	}
+	log.Printf("[CreateAnchorHandler] Created anchor %s %q (%.0fx%.0f at %.0f,%.0f)", anchor.ID, name, size.Width, size.Height, location.X, location.Y)
+
+	req.ZoneID = anchor.ID
+	placed, ok := placeNotes(w, r, "CreateAnchorHandler", req)
+	if !ok {
+		removeAnchor(r, client, req.CanvasID, anchor.ID)
+		return
+	}
+	imp := imports.Import{CanvasID: req.CanvasID, AnchorID: anchor.ID, ScanID: req.ScanID, Anchors: []string{anchor.ID}}
+	resp, status, _ := importNotes(r.Context(), "CreateAnchorHandler", placed, imp)
+	if _, recorded := resp["importID"]; recorded {
+		resp["anchor"] = anchor
+	} else {
+		// Nothing is left to put in the anchor
+		removeAnchor(r, client, req.CanvasID, anchor.ID)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// removeAnchor deletes an anchor created for notes that could not be imported
+func removeAnchor(r *http.Request, client *mcs.MCSClient, canvasID, anchorID string) {
+	// Clean up even if the caller has gone away, so no empty anchor is left behind
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 2*time.Minute)
+	defer cancel()
+	api := canvusapi.NewClient(client.Server, canvasID, client.APIKey)
+	if err := api.DeleteAnchor(ctx, anchorID); err != nil {
+		log.Printf("[removeAnchor] Failed to delete anchor %s: %v", anchorID, err)
+		return
+	}
+	log.Printf("[removeAnchor] Deleted anchor %s", anchorID)
+}
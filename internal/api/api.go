@@ -1,22 +1,73 @@
 package api
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"reflect"
+	"sync"
 
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
 	"github.com/jaypaulb/CanvusNoteMapper/internal/config"
 	"github.com/jaypaulb/CanvusNoteMapper/internal/image"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/imports"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/jobs"
 	"github.com/jaypaulb/CanvusNoteMapper/internal/llm"
 	"github.com/jaypaulb/CanvusNoteMapper/internal/mapping"
 	"github.com/jaypaulb/CanvusNoteMapper/internal/mcs"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/scans"
 )
 
-// Global variable to store the last uploaded image
-var lastUploadedImage []byte
-var lastUploadedImageMimeType string
+// extractor is the note extraction backend used by the upload and scan handlers
+var (
+	extractorMu sync.RWMutex
+	extractor   llm.Extractor
+)
+
+// SetExtractor sets the note extraction backend used by the handlers
+func SetExtractor(e llm.Extractor) {
+	extractorMu.Lock()
+	defer extractorMu.Unlock()
+	extractor = e
+}
+
+// currentExtractor returns the configured extractor, falling back to the
+// environment, in the extraction mode requested ("full" or "hybrid") or
+// else the configured one
+func currentExtractor(mode string) (llm.Extractor, error) {
+	extractorMu.RLock()
+	e := extractor
+	extractorMu.RUnlock()
+	if e == nil {
+		env, err := llm.NewExtractor(llm.ConfigFromEnv())
+		if err != nil {
+			return nil, err
+		}
+		e = llm.WithDetectorFallback(env)
+	}
+	if mode == "" {
+		mode = config.GetConfig().LLM.Mode
+	}
+	return llm.WithMode(e, mode)
+}
+
+// writeExtractorError reports why no extractor is available for a request: an
+// invalid mode is the caller's mistake, anything else the server's
+func writeExtractorError(w http.ResponseWriter, caller string, err error) {
+	log.Printf("[%s] No extractor available: %v", caller, err)
+	status, message := http.StatusInternalServerError, "No extractor available: "+err.Error()
+	if errors.Is(err, llm.ErrInvalidMode) {
+		status, message = http.StatusBadRequest, err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
 
 // POST /api/upload-image
 func UploadImageHandler(w http.ResponseWriter, r *http.Request) {
@@ -32,6 +83,11 @@ func UploadImageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer file.Close()
+	// Several photos of one board are stitched together
+	if photos := r.MultipartForm.File["image"]; len(photos) > 1 {
+		uploadStitched(w, r, photos)
+		return
+	}
 	log.Printf("[UploadImageHandler] Received file: name=%s, size=%d bytes, content-type=%s",
 		fileHeader.Filename, fileHeader.Size, fileHeader.Header.Get("Content-Type"))
 
@@ -51,94 +107,41 @@ func UploadImageHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("[UploadImageHandler] Finished reading file, total size: %d bytes", len(imageData))
 
-	// Process the image to ensure it's within size limits
-	processedImage, mimeType, err := image.ProcessImage(imageData)
+	ext, err := currentExtractor(r.FormValue("mode"))
 	if err != nil {
-		log.Printf("[UploadImageHandler] Failed to process image: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"error":"Failed to process image: ` + err.Error() + `"}`))
+		writeExtractorError(w, "UploadImageHandler", err)
 		return
 	}
-	log.Printf("[UploadImageHandler] Processed image size: %d bytes, MIME type: %s", len(processedImage), mimeType)
-
-	// Store the processed image data in memory (for create-notes endpoint)
-	lastUploadedImage = processedImage
-	lastUploadedImageMimeType = mimeType
-
-	// Get zone parameters from form data (optional, with defaults)
-	zoneDimensions := [2]int{640, 480}
-	zoneLocation := [2]int{0, 0}
-	zoneScale := 1.0
-	if v := r.FormValue("zoneDimensions"); v != "" {
-		json.Unmarshal([]byte(v), &zoneDimensions)
-	}
-	if v := r.FormValue("zoneLocation"); v != "" {
-		json.Unmarshal([]byte(v), &zoneLocation)
-	}
-	if v := r.FormValue("zoneScale"); v != "" {
-		json.Unmarshal([]byte(v), &zoneScale)
-	}
-	log.Printf("[UploadImageHandler] Zone parameters: dimensions=%v, location=%v, scale=%v",
-		zoneDimensions, zoneLocation, zoneScale)
-
-	// Process image with LLM immediately
-	log.Printf("[UploadImageHandler] Processing image with LLM...")
-	log.Printf("[UploadImageHandler] Using MIME type: '%s'", mimeType)
-	llmInput := llm.ExtractPostitNotesInput{
-		ImageData: processedImage,
-		MimeType:  mimeType,
-	}
-	log.Printf("[UploadImageHandler] Created LLM input with MIME type: '%s'", llmInput.MimeType)
 
-	notes, err := llm.ExtractPostitNotes(llmInput)
+	// Optional perspective correction: explicit corners or auto-detection
+	opts, err := processOptionsFromRequest(r)
 	if err != nil {
-		log.Printf("[UploadImageHandler] LLM extraction failed: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"error":"Failed to extract notes: ` + err.Error() + `"}`))
+		log.Printf("[UploadImageHandler] Invalid preprocessing options: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
-	log.Printf("[UploadImageHandler] LLM extraction complete. Found %d notes", len(notes))
-
-	// Map LLM output to internal Note format
-	var rawNotes []llm.Note
-	for i, n := range notes {
-		rawNotes = append(rawNotes, llm.Note{
-			Content: n.Text,
-			Color:   n.BackgroundColor,
-			X:       n.Location["x"],
-			Y:       n.Location["y"],
-			Width:   n.Size["width"],
-			Height:  n.Size["height"],
-			Scale:   n.Scale,
-		})
-		log.Printf("[UploadImageHandler] Mapped note %d: text='%s', color=%s, pos=(%d,%d), size=%dx%d",
-			i, n.Text, n.BackgroundColor, n.Location["x"], n.Location["y"], n.Size["width"], n.Size["height"])
-	}
-
-	// Apply spatial mapping
-	imgW, imgH := 1280, 720 // TODO: Extract from image metadata
-	log.Printf("[UploadImageHandler] Using image dimensions: %dx%d", imgW, imgH)
-
-	mapped := mapping.MapNotesToZone(rawNotes, imgW, imgH, zoneDimensions, zoneLocation)
-	for i := range mapped {
-		mapped[i].Scale = zoneScale
-	}
-	log.Printf("[UploadImageHandler] Mapped %d notes to zone", len(mapped))
 
-	mcsNotes := mapping.MapNotesToMCSFormat(mapped)
-	log.Printf("[UploadImageHandler] Converted %d notes to MCS format", len(mcsNotes))
+	// Each upload starts a new scan, remembered for this browser via cookie
+	scan := scanStore().Create(scans.Zone{
+		CanvasID: r.FormValue("canvasID"),
+		AnchorID: r.FormValue("zoneID"),
+	})
+	log.Printf("[UploadImageHandler] Created scan %s", scan.ID)
 
-	// Return the processed results immediately
-	resp := map[string]interface{}{
-		"status":      "complete",
-		"message":     "Image processed successfully. Notes extracted.",
-		"notes":       mcsNotes,
-		"imageWidth":  imgW,
-		"imageHeight": imgH,
+	// Preprocessing, extraction and mapping run as a background job
+	job, err := jobManager().Submit("upload", scanPipeline("UploadImageHandler", ext, scan.ID, imageData, opts,
+		"Image processed successfully. Notes extracted."))
+	if err != nil {
+		log.Printf("[UploadImageHandler] Failed to queue job: %v", err)
+		scanStore().Delete(scan.ID)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to queue scan: " + err.Error()})
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
-	log.Printf("[UploadImageHandler] Response sent with %d notes", len(mcsNotes))
+	setScanCookie(w, scan.ID)
+	writeJobAccepted(w, job, scan.ID)
+	log.Printf("[UploadImageHandler] Queued job %s for scan %s", job.ID, scan.ID)
 }
 
 // POST /api/scan-notes
@@ -153,49 +156,187 @@ func ScanNotesHandler(w http.ResponseWriter, r *http.Request) {
 
 	log.Println("[ScanNotesHandler] Processing POST request")
 
-	// Use the last uploaded image
-	if len(lastUploadedImage) == 0 {
-		log.Printf("[ScanNotesHandler] No image available for scanning")
+	// Re-scan the image of the referenced scan
+	scanID := scanIDFromRequest(r)
+	scan, err := scanStore().Get(scanID)
+	if err != nil || scan.Image == nil {
+		log.Printf("[ScanNotesHandler] No image available for scan %q: %v", scanID, err)
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(`{"error":"No image available for scanning"}`))
 		return
 	}
 
-	// Parse additional fields from the form
-	zoneDimensions := [2]int{0, 0}
-	zoneLocation := [2]int{0, 0}
-	zoneScale := 1.0
-	if v := r.FormValue("zoneDimensions"); v != "" {
-		json.Unmarshal([]byte(v), &zoneDimensions)
+	ext, err := currentExtractor(r.FormValue("mode"))
+	if err != nil {
+		writeExtractorError(w, "ScanNotesHandler", err)
+		return
 	}
-	if v := r.FormValue("zoneLocation"); v != "" {
-		json.Unmarshal([]byte(v), &zoneLocation)
+
+	job, err := jobManager().Submit("scan", scanPipeline("ScanNotesHandler", ext, scan.ID, nil, image.Options{},
+		"LLM processing complete. Notes extracted."))
+	if err != nil {
+		log.Printf("[ScanNotesHandler] Failed to queue job: %v", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to queue scan: " + err.Error()})
+		return
 	}
-	if v := r.FormValue("zoneScale"); v != "" {
-		json.Unmarshal([]byte(v), &zoneScale)
+	writeJobAccepted(w, job, scan.ID)
+	log.Printf("[ScanNotesHandler] Queued job %s for scan %s", job.ID, scan.ID)
+}
+
+// scanPipeline returns a job that preprocesses the raw upload (or reuses the
+// scan's stored image when raw is nil), extracts notes and maps them to MCS
+// format. The image and notes are saved on the scan.
+func scanPipeline(tag string, ext llm.Extractor, scanID string, raw []byte, opts image.Options, message string) jobs.Func {
+	return func(ctx context.Context, report jobs.Reporter) (interface{}, error) {
+		var processed *image.ProcessedImage
+		if raw != nil {
+			report("preprocessing", 0.1)
+			p, err := image.ProcessImageWithOptions(raw, opts)
+			if err != nil {
+				log.Printf("[%s] Failed to process image: %v", tag, err)
+				return nil, fmt.Errorf("failed to process image: %w", err)
+			}
+			processed = p
+			log.Printf("[%s] Processed image size: %d bytes, MIME type: %s, dimensions: %dx%d (original %dx%d)",
+				tag, len(processed.Data), processed.MimeType, processed.Width, processed.Height, processed.OriginalWidth, processed.OriginalHeight)
+
+			// Store the processed image on the scan (for scan-notes endpoint),
+			// without the full-resolution image, which is only needed once
+			stored := *processed
+			stored.Full = nil
+			if _, err := scanStore().Update(scanID, func(s *scans.Scan) { s.Image = &stored }); err != nil {
+				return nil, err
+			}
+		} else {
+			scan, err := scanStore().Get(scanID)
+			if err != nil {
+				return nil, err
+			}
+			processed = scan.Image
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		report("extracting", 0.3)
+		log.Printf("[%s] Using extractor: %s", tag, ext.Name())
+		var extraction *llm.Extraction
+		var err error
+		if processed.Full != nil {
+			extraction, err = extractTiled(ctx, tag, ext, processed, report)
+		} else {
+			extraction, err = ext.Extract(ctx, llm.ExtractPostitNotesInput{
+				ImageData: processed.Data,
+				MimeType:  processed.MimeType,
+			})
+		}
+		if err != nil {
+			log.Printf("[%s] LLM extraction failed: %v", tag, err)
+			return nil, fmt.Errorf("failed to extract notes: %w", err)
+		}
+		log.Printf("[%s] LLM extraction complete. Found %d notes and %d edges", tag, len(extraction.Notes), len(extraction.Edges))
+
+		report("mapping", 0.9)
+		return saveScanNotes(tag, scanID, extraction, processed, message)
 	}
-	log.Printf("[ScanNotesHandler] Zone parameters: dimensions=%v, location=%v, scale=%v",
-		zoneDimensions, zoneLocation, zoneScale)
+}
+
+// saveScanNotes maps extracted notes to MCS format, snaps their colours and
+// saves them on the scan, returning the job result
+func saveScanNotes(tag, scanID string, extraction *llm.Extraction, processed *image.ProcessedImage, message string) (map[string]interface{}, error) {
+	rawNotes := toRawNotes(tag, extraction.Notes)
+	mcsNotes := mapping.MapNotesToMCSFormat(rawNotes)
+	log.Printf("[%s] Converted %d notes to MCS format (image pixel coordinates)", tag, len(mcsNotes))
+	colors := mapping.SnapNoteColors(mcsNotes, notePalette())
+	for i, c := range colors {
+		log.Printf("[%s] Note %d color %s -> %s %s (ΔE %.1f)", tag, i, c.Raw, c.Name, c.Color, c.Distance)
+	}
+	if _, err := scanStore().Update(scanID, func(s *scans.Scan) {
+		s.Notes = mcsNotes
+		s.Edges = extraction.Edges
+		s.Colors = colors
+	}); err != nil {
+		return nil, err
+	}
+	return scanResponse(message, scanID, mcsNotes, extraction, colors, processed), nil
+}
 
-	// Create input for LLM extraction
-	llmInput := llm.ExtractPostitNotesInput{
-		ImageData: lastUploadedImage,
-		MimeType:  lastUploadedImageMimeType,
+// extractTiled extracts the full-resolution image in overlapping tiles,
+// merges the notes seen twice and scales them to the processed image
+func extractTiled(ctx context.Context, tag string, ext llm.Extractor, processed *image.ProcessedImage, report jobs.Reporter) (*llm.Extraction, error) {
+	tiles, err := image.SplitTiles(processed.Full, image.TileOptions{})
+	if err != nil {
+		return nil, err
+	}
+	b := processed.Full.Bounds()
+	extraction, err := extractMerged(ctx, ext, tiles, b.Dx(), b.Dy(), processed.ResizeRatio, report)
+	if err != nil {
+		return nil, err
 	}
-	log.Printf("[ScanNotesHandler] Created LLM input with image data size: %d bytes", len(llmInput.ImageData))
+	log.Printf("[%s] Extracted %d tiles of the %dx%d image into %d notes", tag, len(tiles), b.Dx(), b.Dy(), len(extraction.Notes))
+	return extraction, nil
+}
 
-	log.Printf("[ScanNotesHandler] Calling ExtractPostitNotes...")
-	notes, err := llm.ExtractPostitNotes(llmInput)
+// extractMerged extracts overlapping tiles of a width x height frame,
+// merges the notes seen twice and scales them by ratio
+func extractMerged(ctx context.Context, ext llm.Extractor, tiles []image.Tile, width, height int, ratio float64, report jobs.Reporter) (*llm.Extraction, error) {
+	results, err := llm.ExtractTiles(ctx, ext, tiles, llm.DefaultTileWorkers, func(done, total int) {
+		report("extracting", 0.3+0.6*float64(done)/float64(total))
+	})
 	if err != nil {
-		log.Printf("[ScanNotesHandler] LLM extraction failed: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"error":"Failed to extract notes: ` + err.Error() + `"}`))
-		return
+		return nil, err
+	}
+	extraction := mapping.MergeTiles(results, width, height)
+	for i, n := range extraction.Notes {
+		extraction.Notes[i].Location = map[string]int{
+			"x": int(math.Round(float64(n.Location["x"]) * ratio)),
+			"y": int(math.Round(float64(n.Location["y"]) * ratio)),
+		}
+		extraction.Notes[i].Size = map[string]int{
+			"width":  int(math.Round(float64(n.Size["width"]) * ratio)),
+			"height": int(math.Round(float64(n.Size["height"]) * ratio)),
+		}
+	}
+	return extraction, nil
+}
+
+// notePalette returns the configured note palette, falling back to the
+// Canvus defaults if it is invalid
+func notePalette() mapping.Palette {
+	palette, err := mapping.NewPalette(config.GetConfig().Mapping.Palette)
+	if err != nil {
+		log.Printf("[notePalette] Invalid palette, using the default: %v", err)
+		palette, _ = mapping.NewPalette(nil)
+	}
+	return palette
+}
+
+// processOptionsFromRequest reads the optional "corners" (four [x,y] points in
+// original image pixels, checked against the image once it is decoded),
+// "autoPerspective" and "tiled" form fields
+func processOptionsFromRequest(r *http.Request) (image.Options, error) {
+	var opts image.Options
+	if v := r.FormValue("corners"); v != "" {
+		var pts [][2]float64
+		if err := json.Unmarshal([]byte(v), &pts); err != nil || len(pts) != 4 {
+			return opts, fmt.Errorf("corners must be four [x,y] points")
+		}
+		var q image.Quad
+		for i, p := range pts {
+			q[i] = image.Point{X: p[0], Y: p[1]}
+		}
+		opts.Corners = &q
 	}
-	log.Printf("[ScanNotesHandler] LLM extraction complete. Found %d notes", len(notes))
+	opts.AutoPerspective = r.FormValue("autoPerspective") == "true"
+	// Tiled extraction reads the image before it is downsized
+	opts.KeepFull = r.FormValue("tiled") == "true"
+	return opts, nil
+}
 
-	// Map LLM output to llm.Note for further processing
-	var rawNotes []llm.Note
+// toRawNotes converts LLM output to llm.Note in image pixel coordinates
+func toRawNotes(tag string, notes []llm.ExtractPostitNotesOutput) []llm.Note {
+	rawNotes := make([]llm.Note, 0, len(notes))
 	for i, n := range notes {
 		rawNotes = append(rawNotes, llm.Note{
 			Content: n.Text,
@@ -206,32 +347,58 @@ func ScanNotesHandler(w http.ResponseWriter, r *http.Request) {
 			Height:  n.Size["height"],
 			Scale:   n.Scale,
 		})
-		log.Printf("[ScanNotesHandler] Mapped note %d: text='%s', color=%s, pos=(%d,%d), size=%dx%d",
-			i, n.Text, n.BackgroundColor, n.Location["x"], n.Location["y"], n.Size["width"], n.Size["height"])
+		log.Printf("[%s] Mapped note %d: text='%s', color=%s, pos=(%d,%d), size=%dx%d",
+			tag, i, n.Text, n.BackgroundColor, n.Location["x"], n.Location["y"], n.Size["width"], n.Size["height"])
 	}
+	return rawNotes
+}
 
-	imgW, imgH := 1280, 720 // TODO: Optionally extract from LLM or image metadata
-	log.Printf("[ScanNotesHandler] Using image dimensions: %dx%d", imgW, imgH)
+// scanResponse builds the upload/scan response. Notes are in the pixel
+// coordinates of the processed image described by imageWidth/imageHeight;
+// edges and colors refer to notes by their index.
+func scanResponse(message, scanID string, mcsNotes []canvusapi.Note, extraction *llm.Extraction, colors []mapping.ColorMatch, img *image.ProcessedImage) map[string]interface{} {
+	resp := map[string]interface{}{
+		"status":         "complete",
+		"message":        message,
+		"scanID":         scanID,
+		"notes":          mcsNotes,
+		"edges":          extraction.Edges,
+		"colors":         colors,
+		"imageWidth":     img.Width,
+		"imageHeight":    img.Height,
+		"originalWidth":  img.OriginalWidth,
+		"originalHeight": img.OriginalHeight,
+		"resizeRatio":    img.ResizeRatio,
+	}
+	if img.Perspective != nil {
+		resp["perspective"] = img.Perspective
+	}
+	if extraction.Fallback != "" {
+		resp["warning"] = "The extractor failed, so notes were found by colour and have no text: " + extraction.Fallback
+	}
+	return resp
+}
 
-	mapped := mapping.MapNotesToZone(rawNotes, imgW, imgH, zoneDimensions, zoneLocation)
-	for i := range mapped {
-		mapped[i].Scale = zoneScale
+// copyNote deep-copies a stored note, so it can be modified without touching the scan
+func copyNote(note canvusapi.Note) canvusapi.Note {
+	out := note
+	if note.Location != nil {
+		loc := *note.Location
+		out.Location = &loc
 	}
-	log.Printf("[ScanNotesHandler] Mapped %d notes to zone", len(mapped))
-
-	mcsNotes := mapping.MapNotesToMCSFormat(mapped)
-	log.Printf("[ScanNotesHandler] Converted %d notes to MCS format", len(mcsNotes))
-
-	resp := map[string]interface{}{
-		"status":      "complete",
-		"message":     "LLM processing complete. Notes extracted.",
-		"notes":       mcsNotes,
-		"imageWidth":  imgW,
-		"imageHeight": imgH,
+	if note.Size != nil {
+		size := *note.Size
+		out.Size = &size
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
-	log.Printf("[ScanNotesHandler] Response sent with %d notes", len(mcsNotes))
+	return out
+}
+
+// noteFields converts a note to a map, for comparing what was sent with what MCS returned
+func noteFields(note *canvusapi.Note) map[string]interface{} {
+	var out map[string]interface{}
+	data, _ := json.Marshal(note)
+	json.Unmarshal(data, &out)
+	return out
 }
 
 // encodeToBase64 encodes bytes to a base64 string
@@ -259,7 +426,7 @@ func GetAnchorsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("[GetAnchorsHandler] Request: %+v\n", req)
 	client := mcs.NewClient(cfg.MCSServer, cfg.APIKey, req.CanvasID)
-	canvases, err := client.GetCanvases()
+	canvases, err := client.GetCanvases(r.Context())
 	if err != nil {
 		log.Printf("[GetAnchorsHandler] Failed to fetch canvases: %v\n", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -268,7 +435,7 @@ func GetAnchorsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	anchors := []mcs.AnchorInfo{}
 	if req.CanvasID != "" {
-		anchors, err = client.GetAnchors(req.CanvasID)
+		anchors, err = client.GetAnchors(r.Context(), req.CanvasID)
 		if err != nil {
 			log.Printf("[GetAnchorsHandler] Failed to fetch anchors for canvas %s: %v\n", req.CanvasID, err)
 		}
@@ -291,133 +458,217 @@ func CreateNotesHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	// --- Scaling Logic ---
-	// Require imageWidth and imageHeight in the request
-	var req struct {
-		CanvasID    string        `json:"canvasID"`
-		Notes       []interface{} `json:"notes"`
-		ZoneID      string        `json:"zoneID"`
-		ImageWidth  float64       `json:"imageWidth"`
-		ImageHeight float64       `json:"imageHeight"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("[CreateNotesHandler] Error decoding request body: %v\n", err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error":"Invalid JSON: "` + err.Error() + `}`))
+	req, ok := prepareNotes(w, r, "CreateNotesHandler")
+	if !ok {
 		return
 	}
-	if req.ImageWidth == 0.0 || req.ImageHeight == 0.0 {
-		log.Printf("[CreateNotesHandler] imageWidth or imageHeight missing or zero, cannot scale notes correctly.")
+	if err := req.SourceImage.validate(req.ScanID); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error":"imageWidth and imageHeight required"}`))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
 
-	cfg := config.GetConfig()
-	client := mcs.NewClient(cfg.MCSServer, cfg.APIKey, req.CanvasID)
+	imp := imports.Import{CanvasID: req.CanvasID, AnchorID: req.ZoneID, ScanID: req.ScanID}
+	resp, status, batch := importNotes(r.Context(), "CreateNotesHandler", req, imp)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+	if batch != nil {
+		log.Printf("[CreateNotesHandler] Created %d notes via MCS API (%d failed)", len(batch.CreatedIDs), batch.Failed)
+	}
+}
 
-	// Fetch anchor info for the selected zone
-	anchor, err := client.GetAnchorInfo(req.CanvasID, req.ZoneID)
-	if err != nil {
-		log.Printf("[CreateNotesHandler] Failed to fetch anchor info: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"error":"Failed to fetch anchor info: "` + err.Error() + `}`))
-		return
+// importNotes creates placed notes with their connectors and source photo and
+// records them as imp. It returns the response body and status; the batch is
+// nil if no note could be created.
+func importNotes(ctx context.Context, caller string, req *placedNotes, imp imports.Import) (map[string]interface{}, int, *mcs.BatchResult) {
+	batch, err := req.client.CreateNotes(ctx, req.CanvasID, req.Notes, mcs.BatchOptions{Policy: req.OnFailure})
+	if batch == nil {
+		log.Printf("[%s] Failed to create notes: %v\n", caller, err)
+		return map[string]interface{}{"error": err.Error()}, http.StatusBadRequest, nil
+	}
+	for _, res := range batch.Results {
+		if res.Created != nil {
+			logNoteValidation(res.Index+1, &req.Notes[res.Index], res.Created)
+		}
 	}
-	anchorJson, _ := json.MarshalIndent(anchor, "", "  ")
-	log.Printf("[CreateNotesHandler] Anchor zone details: %s", string(anchorJson))
-
-	// Calculate scaleFactor (difference between image and anchor zone size)
-	scaleFactor := 1.0
-	if anchor.Width > 0 && anchor.Height > 0 {
-		scaleFactor = min(anchor.Width/req.ImageWidth, anchor.Height/req.ImageHeight)
-	}
-	log.Printf("[CreateNotesHandler] Calculated scaleFactor: %.4f (imageWidth=%.2f, imageHeight=%.2f, anchor.Width=%.2f, anchor.Height=%.2f)", scaleFactor, req.ImageWidth, req.ImageHeight, anchor.Width, anchor.Height)
-	// --- End Scaling Logic ---
-
-	finalScale := scaleFactor * anchor.Scale
-
-	for i, note := range req.Notes {
-		noteMap, _ := note.(map[string]interface{})
-		noteJson, _ := json.MarshalIndent(noteMap, "", "  ")
-		log.Printf("[CreateNotesHandler][Note %d] Source: %s", i+1, string(noteJson))
-
-		// 1. Scale location and size by scaleFactor * anchor.Scale
-		// 2. Offset location by anchor.X and anchor.Y
-		// 3. Set note.scale = 1
-		if noteMap["location"] != nil {
-			if loc, ok := noteMap["location"].(map[string]interface{}); ok {
-				noteMap["location"] = map[string]interface{}{
-					"x": anchor.X + loc["x"].(float64)*finalScale,
-					"y": anchor.Y + loc["y"].(float64)*finalScale,
-				}
+
+	resp := map[string]interface{}{
+		"created": len(batch.CreatedIDs),
+		"failed":  batch.Failed,
+		"batch":   batch,
+	}
+	if err == nil && len(req.Edges) > 0 {
+		connectors := createEdgeConnectors(ctx, req.client, req.CanvasID, req.Edges, batch)
+		for _, c := range connectors {
+			if c.ID != "" {
+				imp.Connectors = append(imp.Connectors, c.ID)
 			}
 		}
-		if noteMap["size"] != nil {
-			if size, ok := noteMap["size"].(map[string]interface{}); ok {
-				noteMap["size"] = map[string]interface{}{
-					"width":  size["width"].(float64) * finalScale,
-					"height": size["height"].(float64) * finalScale,
-				}
-			}
+		resp["connectors"] = connectors
+	}
+	if err == nil && req.SourceImage != nil && len(batch.CreatedIDs) > 0 {
+		if img, imgErr := uploadSourceImage(ctx, req, batch); imgErr != nil {
+			log.Printf("[%s] Failed to upload source photo: %v", caller, imgErr)
+			resp["sourceImage"] = map[string]string{"error": imgErr.Error()}
+		} else {
+			imp.Images = append(imp.Images, img.ID)
+			resp["sourceImage"] = img
 		}
-		// Set the note's scale to 1 (all scaling handled in math above)
-		noteMap["scale"] = 1
-		// Optionally, set parent_id or other anchor fields if needed
-
-		noteJson, _ = json.MarshalIndent(noteMap, "", "  ")
-		log.Printf("[CreateNotesHandler][Note %d] Target (to MCS): %s", i+1, string(noteJson))
-		resp, err := client.CreateNote(req.CanvasID, noteMap)
-		respJson, _ := json.MarshalIndent(resp, "", "  ")
-		log.Printf("[CreateNotesHandler][Note %d] Response from MCS: %s", i+1, string(respJson))
-		// Validation: check if response matches target (ignoring id, parent_id, etc.)
-		match := true
-		for k, v := range noteMap {
-			if k == "id" || k == "parent_id" || k == "ParentID" || k == "ID" {
-				continue
-			}
-			if !reflect.DeepEqual(resp[k], v) {
-				match = false
-				log.Printf("[CreateNotesHandler][Note %d] Validation mismatch: key '%s' target=%v response=%v", i+1, k, v, resp[k])
-			}
+	}
+	if imp, ok := recordImport(imp, batch); ok {
+		resp["importID"] = imp.ID
+	}
+	switch {
+	case err != nil:
+		log.Printf("[%s] Failed to create notes, rolled back %d: %v\n", caller, len(batch.RolledBack), err)
+		resp["error"] = "Failed to create notes: " + err.Error()
+		if len(batch.RollbackErrors) > 0 {
+			resp["error"] = fmt.Sprintf("Failed to create notes: %v (%d notes could not be rolled back)", err, len(batch.CreatedIDs))
 		}
-		if match {
-			log.Printf("[CreateNotesHandler][Note %d] Validation: PASS", i+1)
-		} else {
-			log.Printf("[CreateNotesHandler][Note %d] Validation: FAIL", i+1)
+		return resp, http.StatusBadGateway, batch
+	case batch.Failed > 0:
+		resp["status"] = fmt.Sprintf("%d of %d notes created", len(batch.CreatedIDs), len(req.Notes))
+	default:
+		resp["status"] = "notes created"
+	}
+	return resp, http.StatusOK, batch
+}
+
+// edgeConnector is the outcome of connecting the notes at the ends of an edge
+type edgeConnector struct {
+	Edge  int    `json:"edge"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// createEdgeConnectors connects the notes a batch created as the edges say.
+// Edges to notes that were not created are reported and skipped.
+func createEdgeConnectors(ctx context.Context, client *mcs.MCSClient, canvasID string, edges []llm.Edge, batch *mcs.BatchResult) []edgeConnector {
+	ids := make(map[int]string, len(batch.Results))
+	for _, res := range batch.Results {
+		if res.ID != "" {
+			ids[res.Index] = res.ID
 		}
-		if err != nil {
-			log.Printf("[CreateNotesHandler] Failed to create note: %v\n", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(`{"error":"Failed to create note: "` + err.Error() + `}`))
-			return
+	}
+	out := make([]edgeConnector, len(edges))
+	var connectors []canvusapi.Connector
+	var owners []int
+	for i, edge := range edges {
+		out[i].Edge = i
+		src, dst := ids[edge.Source], ids[edge.Target]
+		if src == "" || dst == "" {
+			out[i].Error = fmt.Sprintf("note %d or %d was not created", edge.Source+1, edge.Target+1)
+			continue
+		}
+		connectors = append(connectors, mapping.MapEdgeToConnector(edge, src, dst))
+		owners = append(owners, i)
+	}
+	created := 0
+	for _, res := range client.CreateConnectors(ctx, canvasID, connectors) {
+		out[owners[res.Index]].ID, out[owners[res.Index]].Error = res.ID, res.Error
+		if res.ID != "" {
+			created++
 		}
 	}
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"notes created"}`))
-	log.Println("[CreateNotesHandler] Created notes via MCS API")
+	log.Printf("[createEdgeConnectors] Created %d connectors for %d edges", created, len(edges))
+	return out
+}
+
+// logNoteValidation logs whether the note MCS created matches what was sent
+// (ignoring id, parent_id, etc.)
+func logNoteValidation(n int, target, created *canvusapi.Note) {
+	respJson, _ := json.MarshalIndent(created, "", "  ")
+	log.Printf("[CreateNotesHandler][Note %d] Response from MCS: %s", n, string(respJson))
+	match := true
+	sent, got := noteFields(target), noteFields(created)
+	for k, v := range sent {
+		if k == "id" || k == "parent_id" {
+			continue
+		}
+		if !reflect.DeepEqual(got[k], v) {
+			match = false
+			log.Printf("[CreateNotesHandler][Note %d] Validation mismatch: key '%s' target=%v response=%v", n, k, v, got[k])
+		}
+	}
+	if match {
+		log.Printf("[CreateNotesHandler][Note %d] Validation: PASS", n)
+	} else {
+		log.Printf("[CreateNotesHandler][Note %d] Validation: FAIL", n)
+	}
 }
 
 // POST /api/set-credentials
 func SetCredentialsHandler(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		MCSServer string `json:"mcsServer"`
-		APIKey    string `json:"apiKey"`
+		MCSServer       string            `json:"mcsServer"`
+		APIKey          string            `json:"apiKey"`
+		DefaultCanvasID *string           `json:"defaultCanvasID"`
+		DefaultAnchorID *string           `json:"defaultAnchorID"`
+		TLS             *config.TLSConfig `json:"tls"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(`{"error":"Invalid JSON"}`))
 		return
 	}
-	config.SetConfig(&config.Config{
-		MCSServer: req.MCSServer,
-		APIKey:    req.APIKey,
-	})
+	cfg := config.GetConfig()
+	cfg.MCSServer = req.MCSServer
+	cfg.APIKey = req.APIKey
+	if req.DefaultCanvasID != nil {
+		cfg.DefaultCanvasID = *req.DefaultCanvasID
+	}
+	if req.DefaultAnchorID != nil {
+		cfg.DefaultAnchorID = *req.DefaultAnchorID
+	}
+	if req.TLS != nil {
+		// Validate before saving, so a bad CA path or fingerprint is not persisted
+		if _, err := canvusapi.NewTransport(tlsOptions(*req.TLS)); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		cfg.TLS = *req.TLS
+	}
+	if err := ApplyTLSConfig(cfg.TLS); err != nil {
+		log.Printf("[SetCredentialsHandler] Failed to apply TLS settings: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if err := config.SetConfig(cfg); err != nil {
+		log.Printf("[SetCredentialsHandler] Failed to save config: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"Credentials set but could not be saved: ` + err.Error() + `"}`))
+		return
+	}
+	resp := map[string]string{"status": "ok"}
+	if cfg.TLS.InsecureSkipVerify {
+		log.Printf("[SetCredentialsHandler] WARNING: insecure TLS enabled for %s", req.MCSServer)
+		resp["warning"] = insecureWarning
+	}
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"ok"}`))
+	json.NewEncoder(w).Encode(resp)
 	log.Printf("Set credentials: server=%s\n", req.MCSServer)
 }
 
+// GET /api/get-config
+// Returns the non-secret configuration so the UI can skip re-entering credentials
+func GetConfigHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := config.GetConfig()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mcsServer":       cfg.MCSServer,
+		"hasApiKey":       cfg.APIKey != "",
+		"defaultCanvasID": cfg.DefaultCanvasID,
+		"defaultAnchorID": cfg.DefaultAnchorID,
+		"tls":             cfg.TLS,
+		"llmProvider":     cfg.LLM.Provider,
+		"llmMode":         cfg.LLM.Mode,
+		"mapping":         cfg.Mapping,
+	})
+}
+
 // GET /api/get-canvas-size
 func GetCanvasSizeHandler(w http.ResponseWriter, r *http.Request) {
 	cfg := config.GetConfig()
@@ -435,7 +686,7 @@ func GetCanvasSizeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	client := mcs.NewClient(cfg.MCSServer, cfg.APIKey, req.CanvasID)
-	size, err := client.GetCanvasSize(req.CanvasID)
+	size, err := client.GetCanvasSize(r.Context(), req.CanvasID)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(`{"error":"Failed to fetch canvas size: "` + err.Error() + `}`))
@@ -454,7 +705,7 @@ func GetCanvasesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	client := mcs.NewClient(cfg.MCSServer, cfg.APIKey, "")
-	canvases, err := client.GetCanvases()
+	canvases, err := client.GetCanvases(r.Context())
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(`{"error":"Failed to fetch canvases: "` + err.Error() + `}`))
@@ -479,7 +730,7 @@ func GetAnchorsOnlyHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	client := mcs.NewClient(cfg.MCSServer, cfg.APIKey, canvasID)
-	anchors, err := client.GetAnchors(canvasID)
+	anchors, err := client.GetAnchors(r.Context(), canvasID)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(`{"error":"Failed to fetch anchors: "` + err.Error() + `}`))
@@ -505,7 +756,7 @@ func GetAnchorInfoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	client := mcs.NewClient(cfg.MCSServer, cfg.APIKey, canvasID)
-	anchor, err := client.GetAnchorInfo(canvasID, anchorID)
+	anchor, err := client.GetAnchorInfo(r.Context(), canvasID, anchorID)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(`{"error":"Failed to fetch anchor info: "` + err.Error() + `}`))
@@ -514,11 +765,3 @@ func GetAnchorInfoHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(anchor)
 }
-
-// Helper for min (copy from mapping)
-func min(a, b float64) float64 {
-	if a < b {
-		return a
-	}
-	return b
-}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/config"
+)
+
+// GET /api/canvases/{id}/events
+// Relays live widget changes on a canvas as Server-Sent Events named added,
+// updated or deleted. The optional "types" query parameter limits events to
+// a comma-separated list of widget types, e.g. ?types=Note,Connector.
+func CanvasEventsHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := config.GetConfig()
+	if cfg.MCSServer == "" || cfg.APIKey == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"MCS credentials not set"}`))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"Streaming not supported"}`))
+		return
+	}
+	canvasID := r.PathValue("id")
+	types := map[string]bool{}
+	for _, t := range strings.Split(r.URL.Query().Get("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types[t] = true
+		}
+	}
+
+	client := canvusapi.NewClient(cfg.MCSServer, canvasID, cfg.APIKey)
+	stream := client.WatchWidgets(r.Context(), canvusapi.StreamOptions{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	log.Printf("[CanvasEventsHandler] Client %s watching canvas %s", r.RemoteAddr, canvasID)
+
+	for event := range stream.Events() {
+		if len(types) > 0 && !types[event.Widget.WidgetType] {
+			continue
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("[CanvasEventsHandler] Failed to encode event: %v", err)
+			continue
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		flusher.Flush()
+	}
+	log.Printf("[CanvasEventsHandler] Stopped watching canvas %s for %s: %v", canvasID, r.RemoteAddr, stream.Err())
+}
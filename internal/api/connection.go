@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/config"
+)
+
+// insecureWarning is returned to the UI whenever certificate verification is off
+const insecureWarning = "TLS certificate verification is disabled; the API key can be intercepted. Use a CA file or a pinned fingerprint instead."
+
+// tlsOptions converts the TLS settings from the config to client options
+func tlsOptions(t config.TLSConfig) canvusapi.TLSOptions {
+	return canvusapi.TLSOptions{
+		CAFile:      t.CAFile,
+		Fingerprint: t.Fingerprint,
+		Insecure:    t.InsecureSkipVerify,
+	}
+}
+
+// ApplyTLSConfig makes new MCS clients verify the server according to t
+func ApplyTLSConfig(t config.TLSConfig) error {
+	return canvusapi.SetTLSOptions(tlsOptions(t))
+}
+
+// POST /api/test-connection
+// Checks that the MCS server is reachable, trusted and accepts the API key.
+// Fields left out of the request fall back to the saved configuration, so the
+// UI can test settings before saving them. The saved API key is only sent to
+// the saved server with the saved TLS settings, so a caller cannot have it
+// sent to another host or to one posing as the server.
+func TestConnectionHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := config.GetConfig()
+	var req struct {
+		MCSServer string            `json:"mcsServer"`
+		APIKey    string            `json:"apiKey"`
+		TLS       *config.TLSConfig `json:"tls"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"Invalid JSON"}`))
+		return
+	}
+	if req.MCSServer == "" {
+		req.MCSServer = cfg.MCSServer
+	}
+	tlsCfg := cfg.TLS
+	if req.TLS != nil {
+		tlsCfg = *req.TLS
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if req.APIKey == "" {
+		if req.MCSServer != cfg.MCSServer || tlsCfg != cfg.TLS {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"API key required to test a server or TLS settings other than the saved ones"}`))
+			return
+		}
+		req.APIKey = cfg.APIKey
+	}
+	if req.MCSServer == "" || req.APIKey == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"MCS server and API key required"}`))
+		return
+	}
+
+	transport, err := canvusapi.NewTransport(tlsOptions(tlsCfg))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "error", "kind": "config", "error": err.Error()})
+		return
+	}
+	client := canvusapi.NewClient(req.MCSServer, "", req.APIKey)
+	client.HTTP = &http.Client{Transport: transport}
+	client.Timeout = 15 * time.Second
+
+	info, err := client.TestConnection(r.Context())
+	if err != nil {
+		log.Printf("[TestConnectionHandler] Connection to %s failed: %v", req.MCSServer, err)
+		resp := map[string]interface{}{"status": "error", "kind": connectionErrorKind(err), "error": err.Error()}
+		if canvusapi.IsTLSError(err) {
+			// Show what the server presented, so it can be checked and pinned
+			ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+			defer cancel()
+			if cert, certErr := canvusapi.FetchCertificate(ctx, req.MCSServer); certErr == nil {
+				resp["certificate"] = map[string]interface{}{
+					"fingerprint": canvusapi.Fingerprint(cert),
+					"subject":     cert.Subject.String(),
+					"issuer":      cert.Issuer.String(),
+					"notAfter":    cert.NotAfter.Format(time.RFC3339),
+				}
+			}
+		}
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	log.Printf("[TestConnectionHandler] Connected to %s (%d canvases, %s)", req.MCSServer, info.Canvases, info.TLSVersion)
+	resp := map[string]interface{}{"status": "ok", "connection": info}
+	if tlsCfg.InsecureSkipVerify {
+		resp["warning"] = insecureWarning
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// connectionErrorKind classifies a failed test connection for the UI
+func connectionErrorKind(err error) string {
+	var apiErr *canvusapi.APIError
+	switch {
+	case canvusapi.IsTLSError(err):
+		return "tls"
+	case errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden):
+		return "auth"
+	case errors.As(err, &apiErr):
+		return "server"
+	default:
+		return "network"
+	}
+}
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	stdimage "image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/config"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/jobs"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/llm"
+)
+
+// stubMCS is a stand-in MCS server for one canvas holding one anchor. It
+// records the widgets created through it.
+type stubMCS struct {
+	*httptest.Server
+	mu         sync.Mutex
+	anchor     map[string]interface{}
+	notes      []map[string]interface{}
+	connectors []map[string]interface{}
+	nextID     int
+}
+
+func newStubMCS(t *testing.T, canvasID string, anchor map[string]interface{}) *stubMCS {
+	t.Helper()
+	s := &stubMCS{anchor: anchor}
+	prefix := "/api/v1/canvases/" + canvasID
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if r.Header.Get("Private-Token") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		path := strings.TrimPrefix(r.URL.Path, prefix)
+		create := func(list *[]map[string]interface{}, widgetType string) {
+			var widget map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&widget); err != nil {
+				t.Errorf("%s %s: %v", r.Method, r.URL.Path, err)
+			}
+			s.nextID++
+			widget["id"] = fmt.Sprintf("%s-%d", widgetType, s.nextID)
+			widget["widget_type"] = widgetType
+			*list = append(*list, widget)
+			json.NewEncoder(w).Encode(widget)
+		}
+		switch {
+		case r.Method == http.MethodGet && path == "/anchors/"+anchor["id"].(string):
+			json.NewEncoder(w).Encode(s.anchor)
+		case r.Method == http.MethodPost && path == "/notes":
+			create(&s.notes, "Note")
+		case r.Method == http.MethodPost && path == "/connectors":
+			create(&s.connectors, "Connector")
+		default:
+			t.Errorf("unexpected MCS request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// waitForJob waits for a job to finish and returns its result
+func waitForJob(t *testing.T, id string) map[string]interface{} {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		snap, err := jobManager().Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch snap.Status {
+		case jobs.StatusComplete:
+			result, err := json.Marshal(snap.Result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out map[string]interface{}
+			json.Unmarshal(result, &out)
+			return out
+		case jobs.StatusFailed, jobs.StatusCancelled:
+			t.Fatalf("job %s %s: %s", id, snap.Status, snap.Error)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return nil
+}
+
+// accepted decodes a 202 response starting a job
+func accepted(t *testing.T, rec *httptest.ResponseRecorder) (jobID, scanID string) {
+	t.Helper()
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		JobID  string `json:"jobID"`
+		ScanID string `json:"scanID"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.JobID, resp.ScanID
+}
+
+// TestUploadScanCreateFlow runs a photo through upload, re-scan and note
+// creation with the mock extractor and a stub MCS server, and checks where
+// the notes land on the canvas.
+func TestUploadScanCreateFlow(t *testing.T) {
+	mcsServer := newStubMCS(t, "canvas-1", map[string]interface{}{
+		"id":          "anchor-1",
+		"widget_type": "Anchor",
+		"anchor_name": "Board",
+		"location":    map[string]float64{"x": 100, "y": 200},
+		"size":        map[string]float64{"width": 2560, "height": 1440},
+		"scale":       1,
+	})
+	previous := config.GetConfig()
+	if err := config.SetConfig(&config.Config{MCSServer: mcsServer.URL, APIKey: "test-key"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { config.SetConfig(previous) })
+	SetExtractor(llm.MockExtractor{})
+	t.Cleanup(func() { SetExtractor(nil) })
+
+	// Upload a blank 1280x720 photo; the mock places notes by the image size
+	var photo bytes.Buffer
+	png.Encode(&photo, stdimage.NewRGBA(stdimage.Rect(0, 0, 1280, 720)))
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("image", "board.png")
+	part.Write(photo.Bytes())
+	form.WriteField("canvasID", "canvas-1")
+	form.WriteField("zoneID", "anchor-1")
+	form.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/upload-image", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	UploadImageHandler(rec, req)
+	jobID, scanID := accepted(t, rec)
+	result := waitForJob(t, jobID)
+	if n := len(result["notes"].([]interface{})); n != 5 {
+		t.Fatalf("upload found %d notes, want 5", n)
+	}
+	if result["imageWidth"] != 1280.0 || result["imageHeight"] != 720.0 {
+		t.Errorf("got image %vx%v, want 1280x720", result["imageWidth"], result["imageHeight"])
+	}
+
+	// Re-scan the stored image
+	body.Reset()
+	form = multipart.NewWriter(&body)
+	form.WriteField("scanID", scanID)
+	form.Close()
+	req = httptest.NewRequest(http.MethodPost, "/api/scan-notes", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec = httptest.NewRecorder()
+	ScanNotesHandler(rec, req)
+	jobID, _ = accepted(t, rec)
+	waitForJob(t, jobID)
+
+	// Create the scan's notes in the anchor
+	req = httptest.NewRequest(http.MethodPost, "/api/create-notes",
+		strings.NewReader(`{"scanID":"`+scanID+`","canvasID":"canvas-1","zoneID":"anchor-1"}`))
+	rec = httptest.NewRecorder()
+	CreateNotesHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create-notes returned %d: %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		Created  int    `json:"created"`
+		ImportID string `json:"importID"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if created.Created != 5 || created.ImportID == "" {
+		t.Errorf("got %s, want 5 notes created and an import ID", rec.Body.String())
+	}
+
+	// The 1280x720 image fills the 2560x1440 anchor at (100,200), so image
+	// pixels are doubled and offset by the anchor position. Mock notes are
+	// 180 pixels square.
+	want := map[string][4]float64{
+		"Top Left":     {100, 200, 360, 360},
+		"Top Right":    {100 + 2*1100, 200, 360, 360},
+		"Bottom Left":  {100, 200 + 2*540, 360, 360},
+		"Bottom Right": {100 + 2*1100, 200 + 2*540, 360, 360},
+		"Center":       {100 + 2*550, 200 + 2*270, 360, 360},
+	}
+	mcsServer.mu.Lock()
+	defer mcsServer.mu.Unlock()
+	if len(mcsServer.notes) != len(want) {
+		t.Fatalf("MCS got %d notes, want %d", len(mcsServer.notes), len(want))
+	}
+	for _, n := range mcsServer.notes {
+		text, _ := n["text"].(string)
+		w, ok := want[text]
+		if !ok {
+			t.Errorf("unexpected note %q", text)
+			continue
+		}
+		loc := n["location"].(map[string]interface{})
+		size := n["size"].(map[string]interface{})
+		got := [4]float64{loc["x"].(float64), loc["y"].(float64), size["width"].(float64), size["height"].(float64)}
+		if got != w {
+			t.Errorf("note %q at %v, want %v", text, got, w)
+		}
+		if n["scale"] != 1.0 {
+			t.Errorf("note %q has scale %v, want 1", text, n["scale"])
+		}
+	}
+	// The mock's two edges become connectors between the created notes
+	if len(mcsServer.connectors) != 2 {
+		t.Errorf("MCS got %d connectors, want 2", len(mcsServer.connectors))
+	}
+}
+
+func TestUploadInvalidMode(t *testing.T) {
+	SetExtractor(llm.MockExtractor{})
+	t.Cleanup(func() { SetExtractor(nil) })
+	var photo bytes.Buffer
+	png.Encode(&photo, stdimage.NewRGBA(stdimage.Rect(0, 0, 64, 48)))
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("image", "board.png")
+	part.Write(photo.Bytes())
+	form.WriteField("mode", `fo"o`)
+	form.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/upload-image", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	UploadImageHandler(rec, req)
+	var resp map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not JSON: %s", rec.Body.String())
+	}
+	if rec.Code != http.StatusBadRequest || !strings.HasPrefix(resp["error"], "invalid mode: ") {
+		t.Errorf("got %d %q, want 400 invalid mode", rec.Code, resp["error"])
+	}
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/config"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/imports"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/mcs"
+)
+
+// importLog records every /api/create-notes run so it can be undone
+var (
+	importLogMu sync.Mutex
+	importLog   *imports.Store
+)
+
+// SetImportStore sets the store that records imports
+func SetImportStore(s *imports.Store) {
+	importLogMu.Lock()
+	defer importLogMu.Unlock()
+	importLog = s
+}
+
+// importStore returns the configured import store, creating a default one if needed
+func importStore() *imports.Store {
+	importLogMu.Lock()
+	defer importLogMu.Unlock()
+	if importLog == nil {
+		importLog = imports.NewStore(imports.DefaultMaxImports)
+	}
+	return importLog
+}
+
+// recordImport stores the notes a batch created, with their state as created,
+// along with the other widgets in imp (connectors, images, anchors)
+func recordImport(imp imports.Import, batch *mcs.BatchResult) (imports.Import, bool) {
+	var widgets []imports.Widget
+	for _, res := range batch.Results {
+		if res.Created != nil {
+			widgets = append(widgets, imports.Widget{ID: res.ID, Snapshot: *res.Created})
+		}
+	}
+	// Notes removed by a rollback no longer exist
+	rolledBack := map[string]bool{}
+	for _, id := range batch.RolledBack {
+		rolledBack[id] = true
+	}
+	kept := widgets[:0]
+	for _, w := range widgets {
+		if !rolledBack[w.ID] {
+			kept = append(kept, w)
+		}
+	}
+	if len(kept) == 0 {
+		return imports.Import{}, false
+	}
+	imp.Widgets = kept
+	imp = importStore().Record(imp)
+	log.Printf("[recordImport] Import %s: %d notes, %d connectors, %d images and %d anchors into canvas %s, anchor %s",
+		imp.ID, len(kept), len(imp.Connectors), len(imp.Images), len(imp.Anchors), imp.CanvasID, imp.AnchorID)
+	return imp, true
+}
+
+// GET /api/imports?canvasID=...
+// Lists recent imports, newest first
+func ImportsHandler(w http.ResponseWriter, r *http.Request) {
+	list := importStore().List(r.URL.Query().Get("canvasID"))
+	if list == nil {
+		list = []imports.Import{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"imports": list})
+}
+
+// POST /api/imports/{id}/undo
+// Deletes the notes, connectors, images and anchors created by an import.
+// Notes that were already deleted are skipped, as are notes moved, resized or
+// edited since the import unless ?force=true is given. Connectors are only
+// deleted along with both of their notes, and the photo and a created anchor
+// only once none of the notes are left. An import that was undone, or is being
+// undone by another request, returns 409.
+func UndoImportHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := config.GetConfig()
+	if cfg.MCSServer == "" || cfg.APIKey == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"MCS credentials not set"}`))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	imp, err := importStore().BeginUndo(r.PathValue("id"))
+	if err != nil {
+		status := http.StatusConflict // already undone, or being undone by another request
+		if errors.Is(err, imports.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	defer importStore().EndUndo(imp.ID)
+	force := r.URL.Query().Get("force") == "true"
+	client := canvusapi.NewClient(cfg.MCSServer, imp.CanvasID, cfg.APIKey)
+
+	type skippedWidget struct {
+		ID     string `json:"id"`
+		Reason string `json:"reason"`
+	}
+	deleted := []string{}
+	skipped := []skippedWidget{}
+	failed := []skippedWidget{}
+	deleteWidget := func(id string, del func(ctx context.Context, id string) error) {
+		err := del(r.Context(), id)
+		var apiErr *canvusapi.APIError
+		switch {
+		case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+			skipped = append(skipped, skippedWidget{id, "already deleted"})
+		case err != nil:
+			failed = append(failed, skippedWidget{id, err.Error()})
+		default:
+			deleted = append(deleted, id)
+		}
+	}
+
+	// Notes go first, since whether the rest can go depends on which notes are gone
+	gone := map[string]bool{}
+	kept := 0
+	for _, widget := range imp.Widgets {
+		current, err := client.GetNoteTyped(r.Context(), widget.ID)
+		var apiErr *canvusapi.APIError
+		switch {
+		case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound,
+			err == nil && current.State == "deleted":
+			skipped = append(skipped, skippedWidget{widget.ID, "already deleted"})
+			gone[widget.ID] = true
+			continue
+		case err != nil:
+			failed = append(failed, skippedWidget{widget.ID, err.Error()})
+			kept++
+			continue
+		case !force && imports.Modified(&widget.Snapshot, current):
+			skipped = append(skipped, skippedWidget{widget.ID, "modified since import"})
+			kept++
+			continue
+		}
+		if err := client.DeleteNote(r.Context(), widget.ID); err != nil {
+			failed = append(failed, skippedWidget{widget.ID, err.Error()})
+			kept++
+			continue
+		}
+		deleted = append(deleted, widget.ID)
+		gone[widget.ID] = true
+	}
+	// A connector goes only once both of its notes are gone, so none is left
+	// dangling and none is removed from a note that was kept
+	for _, id := range imp.Connectors {
+		connector, err := client.GetConnectorTyped(r.Context(), id)
+		var apiErr *canvusapi.APIError
+		switch {
+		case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound,
+			err == nil && connector.State == "deleted":
+			skipped = append(skipped, skippedWidget{id, "already deleted"})
+		case err != nil:
+			failed = append(failed, skippedWidget{id, err.Error()})
+		case connector.Src == nil || connector.Dst == nil || !gone[connector.Src.ID] || !gone[connector.Dst.ID]:
+			skipped = append(skipped, skippedWidget{id, "connected notes kept"})
+		default:
+			deleteWidget(id, client.DeleteConnector)
+		}
+	}
+	// The source photo and anchors go last, and are kept while any note remains
+	for _, id := range imp.Images {
+		if kept > 0 {
+			skipped = append(skipped, skippedWidget{id, "notes kept"})
+			continue
+		}
+		deleteWidget(id, client.DeleteImage)
+	}
+	for _, id := range imp.Anchors {
+		if kept > 0 {
+			skipped = append(skipped, skippedWidget{id, "notes left in anchor"})
+			continue
+		}
+		deleteWidget(id, client.DeleteAnchor)
+	}
+	log.Printf("[UndoImportHandler] Import %s: deleted %d, skipped %d, failed %d", imp.ID, len(deleted), len(skipped), len(failed))
+
+	// Until every widget is gone the import stays open, so it can be undone again with ?force=true
+	status := "undone"
+	if kept == 0 && len(failed) == 0 {
+		imp, _ = importStore().MarkUndone(imp.ID)
+	} else {
+		status = "partially undone"
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  status,
+		"import":  imp,
+		"deleted": deleted,
+		"skipped": skipped,
+		"failed":  failed,
+	})
+}
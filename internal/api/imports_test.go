@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/config"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/imports"
+)
+
+// widgetServer is a stand-in MCS server holding widgets by path, e.g.
+// "/notes/n1". GET returns a widget and DELETE removes it.
+func widgetServer(t *testing.T, canvasID string, widgets map[string]interface{}) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	prefix := "/api/v1/canvases/" + canvasID
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		path := strings.TrimPrefix(r.URL.Path, prefix)
+		widget, ok := widgets[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"msg":"not found"}`))
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(widget)
+		case http.MethodDelete:
+			delete(widgets, path)
+		default:
+			t.Errorf("unexpected MCS request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestUndoImport(t *testing.T) {
+	note := func(id, text string) canvusapi.Note {
+		n := canvusapi.Note{Text: text}
+		n.ID = id
+		n.Location = &canvusapi.Location{X: 10, Y: 20}
+		n.Size = &canvusapi.Size{Width: 100, Height: 100}
+		n.Scale = canvusapi.Float(1)
+		return n
+	}
+	connector := func(id, src, dst string) canvusapi.Connector {
+		c := canvusapi.Connector{Src: &canvusapi.ConnectorEnd{ID: src}, Dst: &canvusapi.ConnectorEnd{ID: dst}}
+		c.ID = id
+		return c
+	}
+	widgets := map[string]interface{}{
+		"/notes/n1":      note("n1", "kept as imported"),
+		"/notes/n2":      note("n2", "edited on the wall"),
+		"/notes/n3":      note("n3", "kept as imported"),
+		"/connectors/c1": connector("c1", "n1", "n3"),
+		"/connectors/c2": connector("c2", "n1", "n2"),
+		"/images/i1":     map[string]string{"id": "i1"},
+		"/anchors/a1":    map[string]string{"id": "a1"},
+	}
+	srv := widgetServer(t, "canvas-1", widgets)
+	previous := config.GetConfig()
+	config.SetConfig(&config.Config{MCSServer: srv.URL, APIKey: "test-key"})
+	t.Cleanup(func() { config.SetConfig(previous) })
+	previousLog := importStore()
+	SetImportStore(imports.NewStore(0))
+	t.Cleanup(func() { SetImportStore(previousLog) })
+
+	imp := importStore().Record(imports.Import{
+		CanvasID: "canvas-1",
+		Widgets: []imports.Widget{
+			{ID: "n1", Snapshot: note("n1", "kept as imported")},
+			{ID: "n2", Snapshot: note("n2", "as imported")},
+			{ID: "n3", Snapshot: note("n3", "kept as imported")},
+		},
+		Connectors: []string{"c1", "c2"},
+		Images:     []string{"i1"},
+		Anchors:    []string{"a1"},
+	})
+
+	type undoResult struct {
+		Status  string   `json:"status"`
+		Deleted []string `json:"deleted"`
+		Skipped []struct {
+			ID     string `json:"id"`
+			Reason string `json:"reason"`
+		} `json:"skipped"`
+	}
+	undo := func(query string, wantStatus int) undoResult {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/imports/"+imp.ID+"/undo"+query, nil)
+		req.SetPathValue("id", imp.ID)
+		rec := httptest.NewRecorder()
+		UndoImportHandler(rec, req)
+		if rec.Code != wantStatus {
+			t.Fatalf("undo%s returned %d, want %d: %s", query, rec.Code, wantStatus, rec.Body.String())
+		}
+		var res undoResult
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		sort.Strings(res.Deleted)
+		return res
+	}
+
+	// The edited note keeps its connector, the photo and the anchor
+	res := undo("", http.StatusOK)
+	if res.Status != "partially undone" || strings.Join(res.Deleted, ",") != "c1,n1,n3" {
+		t.Errorf("got %s deleting %v, want partially undone deleting c1, n1 and n3", res.Status, res.Deleted)
+	}
+	reasons := map[string]string{}
+	for _, s := range res.Skipped {
+		reasons[s.ID] = s.Reason
+	}
+	want := map[string]string{"n2": "modified since import", "c2": "connected notes kept", "i1": "notes kept", "a1": "notes left in anchor"}
+	for id, reason := range want {
+		if reasons[id] != reason {
+			t.Errorf("%s skipped as %q, want %q", id, reasons[id], reason)
+		}
+	}
+
+	// Forcing deletes the rest, including the connector to the note deleted before
+	res = undo("?force=true", http.StatusOK)
+	if res.Status != "undone" || strings.Join(res.Deleted, ",") != "a1,c2,i1,n2" {
+		t.Errorf("got %s deleting %v, want undone deleting a1, c2, i1 and n2", res.Status, res.Deleted)
+	}
+	if len(widgets) != 0 {
+		t.Errorf("widgets left on the canvas: %v", widgets)
+	}
+
+	undo("", http.StatusConflict)
+}
+
+func TestUndoImportRefused(t *testing.T) {
+	previous := config.GetConfig()
+	config.SetConfig(&config.Config{MCSServer: "http://mcs.invalid", APIKey: "test-key"})
+	t.Cleanup(func() { config.SetConfig(previous) })
+	previousLog := importStore()
+	SetImportStore(imports.NewStore(0))
+	t.Cleanup(func() { SetImportStore(previousLog) })
+	imp := importStore().Record(imports.Import{CanvasID: "canvas-1", Widgets: []imports.Widget{{ID: "n1"}}})
+
+	undo := func(id string) (int, map[string]string) {
+		req := httptest.NewRequest(http.MethodPost, "/api/imports/"+id+"/undo", nil)
+		req.SetPathValue("id", id)
+		rec := httptest.NewRecorder()
+		UndoImportHandler(rec, req)
+		var body map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Errorf("response is not JSON: %s", rec.Body.String())
+		}
+		return rec.Code, body
+	}
+
+	if status, body := undo(`no"such`); status != http.StatusNotFound || body["error"] != imports.ErrNotFound.Error() {
+		t.Errorf("got %d %v for an unknown import", status, body)
+	}
+	// Another request is already undoing the import
+	if _, err := importStore().BeginUndo(imp.ID); err != nil {
+		t.Fatal(err)
+	}
+	if status, body := undo(imp.ID); status != http.StatusConflict || body["error"] != imports.ErrUndoInProgress.Error() {
+		t.Errorf("got %d %v during another undo", status, body)
+	}
+	importStore().MarkUndone(imp.ID)
+	if status, body := undo(imp.ID); status != http.StatusConflict || body["error"] != imports.ErrUndone.Error() {
+		t.Errorf("got %d %v after the undo", status, body)
+	}
+}
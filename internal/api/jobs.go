@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/jobs"
+)
+
+// manager runs upload and scan jobs in the background
+var (
+	managerMu sync.Mutex
+	manager   *jobs.Manager
+)
+
+// SetJobManager sets the job manager used by the upload and scan handlers
+func SetJobManager(m *jobs.Manager) {
+	managerMu.Lock()
+	defer managerMu.Unlock()
+	manager = m
+}
+
+// jobManager returns the configured job manager, starting a default one if needed
+func jobManager() *jobs.Manager {
+	managerMu.Lock()
+	defer managerMu.Unlock()
+	if manager == nil {
+		manager = jobs.NewManager(jobs.DefaultWorkers)
+	}
+	return manager
+}
+
+// writeJobAccepted responds 202 with the job and scan IDs and where to follow the job
+func writeJobAccepted(w http.ResponseWriter, job jobs.Snapshot, scanID string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    job.Status,
+		"jobID":     job.ID,
+		"scanID":    scanID,
+		"jobURL":    "/api/jobs/" + job.ID,
+		"eventsURL": "/api/jobs/" + job.ID + "/events",
+	})
+}
+
+// writeJobError maps job manager errors to HTTP responses
+func writeJobError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if errors.Is(err, jobs.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// GET /api/jobs/{id}
+func JobHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := jobManager().Get(r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// POST /api/jobs/{id}/cancel
+func CancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := jobManager().Cancel(r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	log.Printf("[CancelJobHandler] Job %s is now %s", job.ID, job.Status)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// GET /api/jobs/{id}/events
+// Streams job snapshots as Server-Sent Events. The current state is sent on
+// connect, so clients can simply reconnect after a dropped connection.
+func JobEventsHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"Streaming not supported"}`))
+		return
+	}
+	updates, unsubscribe, err := jobManager().Subscribe(id)
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	log.Printf("[JobEventsHandler] Client %s following job %s", r.RemoteAddr, id)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Printf("[JobEventsHandler] Client %s disconnected from job %s", r.RemoteAddr, id)
+			return
+		case snap, ok := <-updates:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(snap)
+			if err != nil {
+				log.Printf("[JobEventsHandler] Failed to encode job %s: %v", id, err)
+				return
+			}
+			event := "progress"
+			if snap.Status.Terminal() {
+				event = string(snap.Status)
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+			flusher.Flush()
+		}
+	}
+}
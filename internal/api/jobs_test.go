@@ -0,0 +1,34 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/jobs"
+)
+
+func TestWriteJobError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{jobs.ErrNotFound, http.StatusNotFound},
+		{fmt.Errorf("job %q: %w", `a"b`, jobs.ErrNotFound), http.StatusNotFound},
+		{fmt.Errorf("queue %q is full\n", "scan"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeJobError(rec, tt.err)
+		var body map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Errorf("%v: response is not JSON: %s", tt.err, rec.Body.String())
+			continue
+		}
+		if rec.Code != tt.status || body["error"] != tt.err.Error() {
+			t.Errorf("got %d %q, want %d %q", rec.Code, body["error"], tt.status, tt.err.Error())
+		}
+	}
+}
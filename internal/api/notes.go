@@ -0,0 +1,225 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/config"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/llm"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/mapping"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/mcs"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/scans"
+)
+
+// notesRequest is the body of /api/create-notes, /api/create-anchor and /api/sync-notes
+type notesRequest struct {
+	ScanID      string            `json:"scanID"`
+	CanvasID    string            `json:"canvasID"`
+	Notes       []canvusapi.Note  `json:"notes"`
+	Edges       []llm.Edge        `json:"edges"`     // lines between notes, by index into Notes
+	OnFailure   mcs.FailurePolicy `json:"onFailure"` // "rollback" (default) or "report"
+	Placement   string            `json:"placement"` // "absolute" or "parent"; defaults to the configured placement
+	ZoneID      string            `json:"zoneID"`
+	ImageWidth  float64           `json:"imageWidth"`
+	ImageHeight float64           `json:"imageHeight"`
+
+	// Create only
+	SourceImage *sourceImageOptions `json:"sourceImage"` // also place the scan's photo on the canvas
+
+	// Sync only
+	DryRun        bool `json:"dryRun"`
+	DeleteMissing bool `json:"deleteMissing"`
+
+	// Create anchor only
+	AnchorName  string  `json:"anchorName"`  // defaults to "Notes <date>"
+	AnchorWidth float64 `json:"anchorWidth"` // defaults to the photo width; the height follows the photo's aspect ratio
+}
+
+// placedNotes is a notes request with its notes positioned in the anchor
+type placedNotes struct {
+	notesRequest
+	client   *mcs.MCSClient
+	anchor   *mcs.AnchorInfo
+	frame    mcs.Frame // frame the note locations are in: the canvas, or the anchor with "parent" placement
+	parentID string    // parent of the created notes
+
+	// How the image was fitted into the anchor, and the frame that maps anchor units to note locations
+	placeFrame                    mcs.Frame
+	scaleFactor, offsetX, offsetY float64
+}
+
+// prepareNotes decodes a notes request and maps its notes from image pixels
+// into the anchor zone. On failure it writes the error response and returns false.
+func prepareNotes(w http.ResponseWriter, r *http.Request, caller string) (*placedNotes, bool) {
+	req, ok := decodeNotes(w, r, caller)
+	if !ok {
+		return nil, false
+	}
+	return placeNotes(w, r, caller, req)
+}
+
+// decodeNotes decodes a notes request, taking the notes and image size from
+// the scan when the request leaves them out. On failure it writes the error
+// response and returns false.
+func decodeNotes(w http.ResponseWriter, r *http.Request, caller string) (*notesRequest, bool) {
+	// --- Scaling Logic ---
+	// Require imageWidth and imageHeight in the request, or a scan to take them from
+	req := &notesRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		log.Printf("[%s] Error decoding request body: %v\n", caller, err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"Invalid JSON: "` + err.Error() + `}`))
+		return nil, false
+	}
+	// The scan cookie is only a fallback for requests that need a scan's data:
+	// a complete request is not tied to whatever the browser scanned last
+	complete := len(req.Notes) > 0 && req.ImageWidth != 0 && req.ImageHeight != 0
+	fromCookie := false
+	if req.ScanID == "" && (!complete || req.SourceImage != nil) {
+		if c, err := r.Cookie(scanCookie); err == nil {
+			req.ScanID = c.Value
+			fromCookie = true
+		}
+	}
+	if req.ScanID != "" {
+		scan, err := scanStore().Get(req.ScanID)
+		switch {
+		case err != nil && fromCookie && complete:
+			// The remembered scan has expired, but the request does not need it
+			log.Printf("[%s] Ignoring scan cookie %s: %v", caller, req.ScanID, err)
+			req.ScanID = ""
+		case err != nil:
+			log.Printf("[%s] Scan %s: %v", caller, req.ScanID, err)
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"` + err.Error() + `"}`))
+			return nil, false
+		default:
+			// Notes sent with their own image size keep it
+			if scan.Image != nil && !complete {
+				req.ImageWidth = float64(scan.Image.Width)
+				req.ImageHeight = float64(scan.Image.Height)
+			}
+			if len(req.Notes) == 0 {
+				for _, n := range scan.Notes {
+					req.Notes = append(req.Notes, copyNote(n))
+				}
+				req.Edges = scan.Edges
+			}
+			log.Printf("[%s] Using scan %s (image %.0fx%.0f, %d notes)", caller, req.ScanID, req.ImageWidth, req.ImageHeight, len(req.Notes))
+		}
+	}
+	if req.ImageWidth == 0.0 || req.ImageHeight == 0.0 {
+		log.Printf("[%s] imageWidth or imageHeight missing or zero, cannot scale notes correctly.", caller)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"imageWidth and imageHeight required"}`))
+		return nil, false
+	}
+	return req, true
+}
+
+// placeNotes maps the notes of a decoded request from image pixels into the
+// anchor zone. On failure it writes the error response and returns false.
+func placeNotes(w http.ResponseWriter, r *http.Request, caller string, req *notesRequest) (*placedNotes, bool) {
+	cfg := config.GetConfig()
+	client := mcs.NewClient(cfg.MCSServer, cfg.APIKey, req.CanvasID)
+
+	// Fetch anchor info for the selected zone
+	anchor, err := client.GetAnchorInfo(r.Context(), req.CanvasID, req.ZoneID)
+	if err != nil {
+		log.Printf("[%s] Failed to fetch anchor info: %v\n", caller, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"Failed to fetch anchor info: "` + err.Error() + `}`))
+		return nil, false
+	}
+	anchorJson, _ := json.MarshalIndent(anchor, "", "  ")
+	log.Printf("[%s] Anchor zone details: %s", caller, string(anchorJson))
+	if req.ScanID != "" {
+		scanStore().Update(req.ScanID, func(s *scans.Scan) {
+			s.Zone = scans.Zone{CanvasID: req.CanvasID, AnchorID: req.ZoneID}
+		})
+	}
+
+	placement := req.Placement
+	if placement == "" {
+		placement = cfg.Mapping.Placement
+	}
+	if placement == "" {
+		placement = config.PlacementAbsolute
+	}
+	if placement != config.PlacementAbsolute && placement != config.PlacementParent {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"placement must be \"absolute\" or \"parent\""}`))
+		return nil, false
+	}
+
+	// Fit the image into the anchor zone (maintaining aspect ratio, centred),
+	// in the anchor's own units
+	scaleFactor, offsetX, offsetY := mapping.FitToZone(req.ImageWidth, req.ImageHeight, anchor.Width, anchor.Height)
+	log.Printf("[%s] Calculated scaleFactor: %.4f, offset: (%.2f, %.2f) (imageWidth=%.2f, imageHeight=%.2f, anchor.Width=%.2f, anchor.Height=%.2f)", caller, scaleFactor, offsetX, offsetY, req.ImageWidth, req.ImageHeight, anchor.Width, anchor.Height)
+	// --- End Scaling Logic ---
+
+	// The anchor's frame on the canvas, resolved through its parents
+	anchorFrame := mcs.CanvasFrame.Child(&canvusapi.Location{X: anchor.X, Y: anchor.Y}, anchor.Scale)
+	if anchor.Absolute != nil {
+		anchorFrame = anchor.Absolute.Frame()
+	} else {
+		// Fall back to treating the anchor as top-level
+		log.Printf("[%s] Anchor parents unresolved, assuming top-level anchor", caller)
+	}
+
+	// The frame notes are positioned in. With "parent" placement notes are
+	// children of the anchor, so their locations are in the anchor's units and
+	// they move with it. With "absolute" placement they are top-level widgets.
+	frame := anchorFrame
+	parentID := ""
+	if placement == config.PlacementParent {
+		frame = mcs.CanvasFrame
+		parentID = anchor.ID
+	}
+	log.Printf("[%s] Placement %s, frame %+v", caller, placement, frame)
+
+	for i := range req.Notes {
+		note := &req.Notes[i]
+		noteJson, _ := json.MarshalIndent(note, "", "  ")
+		log.Printf("[%s][Note %d] Source: %s", caller, i+1, string(noteJson))
+
+		// 1. Scale location and size by scaleFactor into the anchor's units, offset by the centring offset
+		// 2. Map into the placement frame
+		// 3. Set note.scale = 1
+		if note.Location != nil {
+			x, y := frame.Apply(offsetX+note.Location.X*scaleFactor, offsetY+note.Location.Y*scaleFactor)
+			note.Location = &canvusapi.Location{X: x, Y: y}
+		}
+		if note.Size != nil {
+			note.Size = &canvusapi.Size{
+				Width:  note.Size.Width * scaleFactor * frame.Scale,
+				Height: note.Size.Height * scaleFactor * frame.Scale,
+			}
+		}
+		// Set the note's scale to 1 (all scaling handled in math above)
+		note.Scale = canvusapi.Float(1)
+		note.ParentID = parentID
+
+		noteJson, _ = json.MarshalIndent(note, "", "  ")
+		log.Printf("[%s][Note %d] Target (to MCS): %s", caller, i+1, string(noteJson))
+	}
+
+	// Note locations are relative to their parent
+	notesFrame := mcs.CanvasFrame
+	if parentID != "" {
+		notesFrame = anchorFrame
+	}
+	return &placedNotes{
+		notesRequest: *req,
+		client:       client,
+		anchor:       anchor,
+		frame:        notesFrame,
+		parentID:     parentID,
+		placeFrame:   frame,
+		scaleFactor:  scaleFactor,
+		offsetX:      offsetX,
+		offsetY:      offsetY,
+	}, true
+}
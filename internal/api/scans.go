@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/scans"
+)
+
+// scanCookie holds the caller's current scan ID when none is passed explicitly
+const scanCookie = "scan_id"
+
+// store holds per-session scan state
+var (
+	storeMu sync.Mutex
+	store   *scans.Store
+)
+
+// SetScanStore sets the store used to keep scans between requests
+func SetScanStore(s *scans.Store) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	store = s
+}
+
+// scanStore returns the configured scan store, creating a default one if needed
+func scanStore() *scans.Store {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	if store == nil {
+		store = scans.NewStore(scans.DefaultTTL)
+	}
+	return store
+}
+
+// scanIDFromRequest returns the explicit scanID (query or form value),
+// falling back to the scan cookie
+func scanIDFromRequest(r *http.Request) string {
+	if id := r.FormValue("scanID"); id != "" {
+		return id
+	}
+	if c, err := r.Cookie(scanCookie); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// setScanCookie remembers the scan for later requests from the same browser,
+// for as long as the store keeps an idle scan
+func setScanCookie(w http.ResponseWriter, id string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     scanCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(scanStore().TTL().Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/scans"
+)
+
+// useScanStore swaps in a scan store for the duration of a test
+func useScanStore(t *testing.T, s *scans.Store) {
+	previous := scanStore()
+	SetScanStore(s)
+	t.Cleanup(func() { SetScanStore(previous) })
+}
+
+func TestSetScanCookieMaxAge(t *testing.T) {
+	useScanStore(t, scans.NewStore(10*time.Minute))
+	rec := httptest.NewRecorder()
+	setScanCookie(rec, "scan-1")
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].MaxAge != 600 {
+		t.Errorf("got cookies %+v, want one with MaxAge 600", cookies)
+	}
+}
+
+func TestDecodeNotesScanCookie(t *testing.T) {
+	store := scans.NewStore(time.Hour)
+	useScanStore(t, store)
+	scan := store.Create(scans.Zone{})
+	store.Update(scan.ID, func(s *scans.Scan) {
+		s.Notes = []canvusapi.Note{{Text: "from scan"}}
+	})
+
+	const complete = `{"notes":[{"text":"sent"}],"imageWidth":640,"imageHeight":480}`
+	tests := []struct {
+		name   string
+		body   string
+		cookie string
+		status int
+		text   string
+		scanID string
+	}{
+		{"complete request ignores an expired cookie scan", complete, "expired", http.StatusOK, "sent", ""},
+		{"complete request ignores a live cookie scan", complete, scan.ID, http.StatusOK, "sent", ""},
+		{"notes taken from the cookie scan", `{"imageWidth":640,"imageHeight":480}`, scan.ID, http.StatusOK, "from scan", scan.ID},
+		{"incomplete request with an expired cookie scan", `{"imageWidth":640,"imageHeight":480}`, "expired", http.StatusNotFound, "", ""},
+		{"explicit scan that has expired", `{"scanID":"expired","notes":[{"text":"sent"}],"imageWidth":640,"imageHeight":480}`, "", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/create-notes", strings.NewReader(tt.body))
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: scanCookie, Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+			got, ok := decodeNotes(rec, req, "test")
+			if !ok {
+				if tt.status == http.StatusOK {
+					t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+				}
+				if rec.Code != tt.status {
+					t.Errorf("got status %d, want %d", rec.Code, tt.status)
+				}
+				return
+			}
+			if tt.status != http.StatusOK {
+				t.Fatalf("request accepted, want status %d", tt.status)
+			}
+			if len(got.Notes) != 1 || got.Notes[0].Text != tt.text || got.ScanID != tt.scanID {
+				t.Errorf("got notes %+v from scan %q, want %q from %q", got.Notes, got.ScanID, tt.text, tt.scanID)
+			}
+		})
+	}
+}
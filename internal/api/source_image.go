@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/mcs"
+)
+
+// Where the source photo goes relative to the notes
+const (
+	sourceImageBehind = "behind" // under the notes, covering the same area
+	sourceImageBeside = "beside" // to the right of the anchor
+)
+
+// besideGap is the space between the anchor and a photo placed beside it, as a fraction of the anchor width
+const besideGap = 0.05
+
+// sourceImageOptions places the scan's photo on the canvas with the notes
+type sourceImageOptions struct {
+	Position   string `json:"position"`   // "behind" (default) or "beside"
+	Pinned     bool   `json:"pinned"`     // pin the photo so it is not moved by accident
+	SendToBack *bool  `json:"sendToBack"` // put the photo below the notes; default true behind them, false beside
+}
+
+// validate checks the options, which may be nil, for a scan
+func (o *sourceImageOptions) validate(scanID string) error {
+	if o == nil {
+		return nil
+	}
+	if o.Position == "" {
+		o.Position = sourceImageBehind
+	}
+	if o.Position != sourceImageBehind && o.Position != sourceImageBeside {
+		return fmt.Errorf(`sourceImage.position must be "behind" or "beside"`)
+	}
+	if o.SendToBack == nil {
+		o.SendToBack = canvusapi.Bool(o.Position == sourceImageBehind)
+	}
+	if scanID == "" {
+		return errors.New("sourceImage needs a scan: the photo is taken from the scan")
+	}
+	return nil
+}
+
+// uploadSourceImage places the scan's processed photo where the notes were
+// mapped from it, or beside the anchor, with the same parent as the notes
+func uploadSourceImage(ctx context.Context, req *placedNotes, batch *mcs.BatchResult) (*canvusapi.Image, error) {
+	opts := req.SourceImage
+	scan, err := scanStore().Get(req.ScanID)
+	if err != nil {
+		return nil, err
+	}
+	if scan.Image == nil {
+		return nil, errors.New("no photo stored for this scan")
+	}
+
+	// The photo covers exactly the area the notes were mapped into
+	x, y := req.offsetX, req.offsetY
+	if opts.Position == sourceImageBeside {
+		x = req.anchor.Width * (1 + besideGap)
+	}
+	x, y = req.placeFrame.Apply(x, y)
+	scale := req.scaleFactor * req.placeFrame.Scale
+	meta := &canvusapi.Image{
+		WidgetBase: canvusapi.WidgetBase{
+			WidgetType: canvusapi.TypeImage,
+			ParentID:   req.parentID,
+			Location:   &canvusapi.Location{X: x, Y: y},
+			Size:       &canvusapi.Size{Width: req.ImageWidth * scale, Height: req.ImageHeight * scale},
+			Scale:      canvusapi.Float(1),
+			State:      "normal",
+			Pinned:     canvusapi.Bool(opts.Pinned),
+		},
+		Title: "Source photo",
+	}
+	if *opts.SendToBack {
+		meta.Depth = canvusapi.Float(belowNotes(batch))
+	}
+
+	filename := "scan-" + scan.ID + imageExtension(scan.Image.MimeType)
+	client := canvusapi.NewClient(req.client.Server, req.CanvasID, req.client.APIKey)
+	img, err := client.CreateImageDataTyped(ctx, filename, scan.Image.Data, meta)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[uploadSourceImage] Uploaded %s as image %s (%s, %.0fx%.0f at %.0f,%.0f)", filename, img.ID, opts.Position, meta.Size.Width, meta.Size.Height, x, y)
+	return img, nil
+}
+
+// belowNotes returns a depth below every note the batch created
+func belowNotes(batch *mcs.BatchResult) float64 {
+	depth, found := 0.0, false
+	for _, res := range batch.Results {
+		if res.Created != nil && (!found || res.Created.DepthValue() < depth) {
+			depth, found = res.Created.DepthValue(), true
+		}
+	}
+	depth--
+	return depth
+}
+
+// imageExtension returns the file extension MCS uses to recognise the image format
+func imageExtension(mimeType string) string {
+	switch mimeType {
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".jpg"
+	}
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/mcs"
+)
+
+func TestBelowNotes(t *testing.T) {
+	created := func(depth *float64) mcs.NoteResult {
+		n := &canvusapi.Note{}
+		n.Depth = depth
+		return mcs.NoteResult{Created: n}
+	}
+	tests := []struct {
+		name    string
+		results []mcs.NoteResult
+		want    float64
+	}{
+		{"mixed depths", []mcs.NoteResult{created(canvusapi.Float(3)), created(canvusapi.Float(-2)), created(canvusapi.Float(5))}, -3},
+		{"lowest note at depth 1", []mcs.NoteResult{created(canvusapi.Float(4)), created(canvusapi.Float(1))}, 0},
+		{"failed notes are ignored", []mcs.NoteResult{{Error: "failed"}, created(canvusapi.Float(2)), {Skipped: true}}, 1},
+		{"unset depth counts as 0", []mcs.NoteResult{created(nil), created(canvusapi.Float(2))}, -1},
+		{"nothing created", []mcs.NoteResult{{Error: "failed"}}, -1},
+		{"empty batch", nil, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := belowNotes(&mcs.BatchResult{Results: tt.results}); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/image"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/jobs"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/llm"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/scans"
+)
+
+// uploadStitched handles an upload of several overlapping photos of one
+// board, in order. They are stitched into one scan.
+func uploadStitched(w http.ResponseWriter, r *http.Request, photos []*multipart.FileHeader) {
+	if r.FormValue("corners") != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"corners cannot be used with several photos"}`))
+		return
+	}
+	opts, err := stitchOptionsFromRequest(r, len(photos))
+	if err != nil {
+		log.Printf("[UploadImageHandler] Invalid stitching options: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	data := make([][]byte, len(photos))
+	for i, fh := range photos {
+		if data[i], err = readUpload(fh); err != nil {
+			log.Printf("[UploadImageHandler] Failed to read photo %d: %v", i+1, err)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"Failed to read photo ` + strconv.Itoa(i+1) + `"}`))
+			return
+		}
+		log.Printf("[UploadImageHandler] Received photo %d: name=%s, size=%d bytes", i+1, fh.Filename, len(data[i]))
+	}
+
+	ext, err := currentExtractor(r.FormValue("mode"))
+	if err != nil {
+		writeExtractorError(w, "UploadImageHandler", err)
+		return
+	}
+
+	scan := scanStore().Create(scans.Zone{
+		CanvasID: r.FormValue("canvasID"),
+		AnchorID: r.FormValue("zoneID"),
+	})
+	log.Printf("[UploadImageHandler] Created scan %s from %d photos", scan.ID, len(photos))
+
+	job, err := jobManager().Submit("upload", stitchPipeline("UploadImageHandler", ext, scan.ID, data, opts,
+		"Photos stitched and processed successfully. Notes extracted."))
+	if err != nil {
+		log.Printf("[UploadImageHandler] Failed to queue job: %v", err)
+		scanStore().Delete(scan.ID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to queue scan: " + err.Error()})
+		return
+	}
+	setScanCookie(w, scan.ID)
+	writeJobAccepted(w, job, scan.ID)
+	log.Printf("[UploadImageHandler] Queued job %s for scan %s", job.ID, scan.ID)
+}
+
+// stitchOptionsFromRequest reads the optional "overlap" (fraction of each
+// photo shared with the next), "direction" ("horizontal" or "vertical") and
+// "offsets" (the [x,y] top-left of each photo relative to the first, in
+// photo pixels) form fields
+func stitchOptionsFromRequest(r *http.Request, photos int) (image.StitchOptions, error) {
+	var opts image.StitchOptions
+	if v := r.FormValue("overlap"); v != "" {
+		overlap, err := strconv.ParseFloat(v, 64)
+		if err != nil || overlap <= 0 || overlap >= 1 {
+			return opts, fmt.Errorf("overlap must be a fraction between 0 and 1")
+		}
+		opts.Overlap = overlap
+	}
+	switch strings.ToLower(r.FormValue("direction")) {
+	case "", "horizontal":
+	case "vertical":
+		opts.Vertical = true
+	default:
+		return opts, fmt.Errorf("direction must be horizontal or vertical")
+	}
+	if v := r.FormValue("offsets"); v != "" {
+		var pts [][2]float64
+		if err := json.Unmarshal([]byte(v), &pts); err != nil || len(pts) != photos {
+			return opts, fmt.Errorf("offsets must be one [x,y] point per photo")
+		}
+		for _, p := range pts {
+			opts.Offsets = append(opts.Offsets, image.Point{X: p[0], Y: p[1]})
+		}
+	}
+	return opts, nil
+}
+
+// readUpload reads an uploaded file
+func readUpload(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// stitchPipeline returns a job that stitches the photos into one frame,
+// extracts each photo, and merges the notes captured twice. The stitched
+// image and the notes are saved on the scan.
+func stitchPipeline(tag string, ext llm.Extractor, scanID string, photos [][]byte, opts image.StitchOptions, message string) jobs.Func {
+	return func(ctx context.Context, report jobs.Reporter) (interface{}, error) {
+		report("stitching", 0.1)
+		stitched, err := image.StitchImages(photos, opts)
+		if err != nil {
+			log.Printf("[%s] Failed to stitch photos: %v", tag, err)
+			return nil, fmt.Errorf("failed to stitch photos: %w", err)
+		}
+		processed := &stitched.ProcessedImage
+		if _, err := scanStore().Update(scanID, func(s *scans.Scan) { s.Image = processed }); err != nil {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		report("extracting", 0.3)
+		log.Printf("[%s] Using extractor: %s", tag, ext.Name())
+		extraction, err := extractMerged(ctx, ext, stitched.Photos, stitched.OriginalWidth, stitched.OriginalHeight, processed.ResizeRatio, report)
+		if err != nil {
+			log.Printf("[%s] LLM extraction failed: %v", tag, err)
+			return nil, fmt.Errorf("failed to extract notes: %w", err)
+		}
+		log.Printf("[%s] Extracted %d photos into %d notes and %d edges", tag, len(photos), len(extraction.Notes), len(extraction.Edges))
+
+		report("mapping", 0.9)
+		resp, err := saveScanNotes(tag, scanID, extraction, processed, message)
+		if err != nil {
+			return nil, err
+		}
+		resp["photos"] = stitched.Photos
+		if len(stitched.Unaligned) > 0 {
+			warning := fmt.Sprintf("Could not align photo(s) %s with the previous photo, so they were placed by the declared overlap", photoList(stitched.Unaligned))
+			if w, ok := resp["warning"].(string); ok {
+				warning = w + ". " + warning
+			}
+			resp["warning"] = warning
+		}
+		return resp, nil
+	}
+}
+
+// photoList formats photo indexes, counted from 0, as "2, 3"
+func photoList(indexes []int) string {
+	names := make([]string, len(indexes))
+	for i, idx := range indexes {
+		names[i] = strconv.Itoa(idx + 1)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/imports"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/mcs"
+)
+
+// POST /api/sync-notes
+// Takes the same request as /api/create-notes, but matches the notes against
+// those previously imported into the anchor: matched notes that moved or
+// changed are updated, new ones are created, and with deleteMissing notes no
+// longer in the photo are deleted. With dryRun the plan is returned without
+// changing the canvas.
+func SyncNotesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("[SyncNotesHandler] Called /api/sync-notes")
+	req, ok := prepareNotes(w, r, "SyncNotesHandler")
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only notes this app imported are candidates, so notes added to the
+	// anchor by hand are never updated or deleted
+	var ids []string
+	seen := map[string]bool{}
+	for _, imp := range importStore().List(req.CanvasID) {
+		if imp.AnchorID != req.ZoneID || imp.UndoneAt != nil {
+			continue
+		}
+		for _, id := range imp.WidgetIDs() {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	existing, err := req.client.GetExistingNotes(r.Context(), req.CanvasID, ids)
+	if err != nil {
+		log.Printf("[SyncNotesHandler] Failed to fetch imported notes: %v", err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch imported notes: " + err.Error()})
+		return
+	}
+
+	// Position stops counting towards a match at half the anchor's diagonal
+	opts := mcs.SyncOptions{DeleteMissing: req.DeleteMissing}
+	if a := req.anchor.Absolute; a != nil {
+		opts.MaxDistance = math.Hypot(a.Width, a.Height) / 2
+	}
+	plan := mcs.PlanSync(req.Notes, req.frame, existing, opts)
+	log.Printf("[SyncNotesHandler] Plan against %d imported notes: %d create, %d update, %d unchanged, %d delete, %d missing",
+		len(existing), plan.Create, plan.Update, plan.Unchanged, plan.Delete, plan.Missing)
+	if req.DryRun {
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "dry run", "plan": plan})
+		return
+	}
+
+	batch, err := req.client.ApplySync(r.Context(), req.CanvasID, plan)
+	if err != nil {
+		log.Printf("[SyncNotesHandler] Failed to sync notes: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	resp := map[string]interface{}{"plan": plan}
+	// New notes form an import of their own; updated notes keep belonging to
+	// their import, with the snapshot refreshed so undo still recognises them
+	if batch != nil {
+		if imp, ok := recordImport(imports.Import{CanvasID: req.CanvasID, AnchorID: req.ZoneID, ScanID: req.ScanID}, batch); ok {
+			resp["importID"] = imp.ID
+		}
+	}
+	for _, change := range plan.Changes {
+		if change.Action == mcs.SyncUpdate && change.Result != nil {
+			importStore().UpdateSnapshot(change.ID, *change.Result)
+		}
+	}
+	resp["status"] = "synced"
+	if plan.Failed > 0 {
+		resp["status"] = fmt.Sprintf("synced with %d failures", plan.Failed)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // Core types and interfaces at the top
@@ -19,6 +20,8 @@ type Client struct {
 	CanvasID string
 	ApiKey   string
 	HTTP     *http.Client
+	Timeout  time.Duration // per call, including retries; 0 means no limit beyond ctx
+	Retry    RetryPolicy
 }
 
 // CRITICAL NOTE:
@@ -43,7 +46,9 @@ func NewClient(server, canvasID, apiKey string) *Client {
 		Server:   server,
 		CanvasID: canvasID,
 		ApiKey:   apiKey,
-		HTTP:     &http.Client{},
+		HTTP:     &http.Client{Transport: currentTransport()},
+		Timeout:  DefaultTimeout,
+		Retry:    DefaultRetryPolicy,
 	}
 }
 
@@ -66,7 +71,7 @@ func (c *Client) buildURL(endpoint string) string {
 		endpoint)
 }
 
-func (c *Client) Request(method, endpoint string, payload interface{}, out interface{}, subscribe bool) error {
+func (c *Client) Request(ctx context.Context, method, endpoint string, payload interface{}, out interface{}, subscribe bool) error {
 	url := c.buildURL(endpoint)
 	if subscribe {
 		if strings.Contains(url, "?") {
@@ -76,39 +81,24 @@ func (c *Client) Request(method, endpoint string, payload interface{}, out inter
 		}
 	}
 
-	var body io.Reader
+	var body []byte
+	var contentType string
 	if payload != nil {
 		jsonData, err := json.Marshal(payload)
 		if err != nil {
 			return fmt.Errorf("failed to marshal payload: %w", err)
 		}
-		body = bytes.NewReader(jsonData)
+		body, contentType = jsonData, "application/json"
 	}
 
-	req, err := http.NewRequest(method, url, body)
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	resp, err := c.do(ctx, method, url, body, contentType)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Private-Token", c.ApiKey)
-	if payload != nil {
-		req.Header.Set("Content-Type", "application/json")
-	}
-
-	resp, err := c.HTTP.Do(req)
-	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return &APIError{
-			StatusCode: resp.StatusCode,
-			Message:    string(bodyBytes),
-		}
-	}
-
 	if out != nil {
 		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
 			return fmt.Errorf("failed to decode response: %w", err)
@@ -118,13 +108,25 @@ func (c *Client) Request(method, endpoint string, payload interface{}, out inter
 	return nil
 }
 
-func (c *Client) uploadFile(endpoint, filePath string, metadata map[string]interface{}) (map[string]interface{}, error) {
+// withTimeout applies c.Timeout to ctx
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.Timeout)
+}
+
+func (c *Client) uploadFile(ctx context.Context, endpoint, filePath string, metadata map[string]interface{}) (map[string]interface{}, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
+	return c.upload(ctx, endpoint, filepath.Base(filePath), file, metadata)
+}
 
+// upload posts data as a multipart "data" part named filename, with the metadata as the "json" part
+func (c *Client) upload(ctx context.Context, endpoint, filename string, data io.Reader, metadata map[string]interface{}) (map[string]interface{}, error) {
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 
@@ -138,11 +140,11 @@ func (c *Client) uploadFile(endpoint, filePath string, metadata map[string]inter
 	}
 
 	// Add file as "data" part
-	part, err := writer.CreateFormFile("data", filepath.Base(filePath))
+	part, err := writer.CreateFormFile("data", filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create form file: %w", err)
 	}
-	if _, err := io.Copy(part, file); err != nil {
+	if _, err := io.Copy(part, data); err != nil {
 		return nil, fmt.Errorf("failed to copy file: %w", err)
 	}
 
@@ -150,29 +152,14 @@ func (c *Client) uploadFile(endpoint, filePath string, metadata map[string]inter
 		return nil, fmt.Errorf("failed to close writer: %w", err)
 	}
 
-	url := c.buildURL(endpoint)
-	req, err := http.NewRequest("POST", url, body)
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	resp, err := c.do(ctx, "POST", c.buildURL(endpoint), body.Bytes(), writer.FormDataContentType())
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Private-Token", c.ApiKey)
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-
-	resp, err := c.HTTP.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("upload request failed: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, &APIError{
-			StatusCode: resp.StatusCode,
-			Message:    string(bodyBytes),
-		}
-	}
-
 	var response map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
@@ -182,274 +169,432 @@ func (c *Client) uploadFile(endpoint, filePath string, metadata map[string]inter
 }
 
 // Canvas-level operations
-func (c *Client) GetCanvasInfo() (map[string]interface{}, error) {
+
+// GetCanvases lists the canvases on the server; it is not scoped to c.CanvasID
+func (c *Client) GetCanvases(ctx context.Context) ([]map[string]interface{}, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	resp, err := c.do(ctx, "GET", strings.TrimRight(c.Server, "/")+"/api/v1/canvases", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var response []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return response, nil
+}
+
+func (c *Client) GetCanvasInfo(ctx context.Context) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("GET", "", nil, &response, false)
+	err := c.Request(ctx, "GET", "", nil, &response, false)
 	return response, err
 }
 
-func (c *Client) Subscribe(widgetType, id string) (map[string]interface{}, error) {
+func (c *Client) Subscribe(ctx context.Context, widgetType, id string) (map[string]interface{}, error) {
 	var response map[string]interface{}
 	endpoint := fmt.Sprintf("/%s/%s", widgetType, id)
-	err := c.Request("GET", endpoint, nil, &response, true)
+	err := c.Request(ctx, "GET", endpoint, nil, &response, true)
 	return response, err
 }
 
 // Widget methods grouped by type
 // Note methods
-func (c *Client) CreateNote(payload map[string]interface{}) (map[string]interface{}, error) {
+func (c *Client) CreateNote(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("POST", "/notes", payload, &response, false)
+	err := c.Request(ctx, "POST", "/notes", payload, &response, false)
 	return response, err
 }
 
-func (c *Client) GetNote(id string, subscribe bool) (map[string]interface{}, error) {
+func (c *Client) GetNote(ctx context.Context, id string, subscribe bool) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("GET", fmt.Sprintf("/notes/%s", id), nil, &response, subscribe)
+	err := c.Request(ctx, "GET", fmt.Sprintf("/notes/%s", id), nil, &response, subscribe)
 	return response, err
 }
 
-func (c *Client) UpdateNote(id string, payload map[string]interface{}) (map[string]interface{}, error) {
+func (c *Client) UpdateNote(ctx context.Context, id string, payload map[string]interface{}) (map[string]interface{}, error) {
 	var response map[string]interface{}
 	if _, hasColor := payload["background_color"]; hasColor {
 		// Try update with current payload
-		err := c.Request("PATCH", fmt.Sprintf("/notes/%s", id), payload, &response, false)
+		err := c.Request(ctx, "PATCH", fmt.Sprintf("/notes/%s", id), payload, &response, false)
 		if apiErr, ok := err.(*APIError); ok && apiErr.StatusCode == 409 {
 			// If color update failed, disable auto_text_color and retry
 			disableAuto := map[string]interface{}{"auto_text_color": false}
-			if err := c.Request("PATCH", fmt.Sprintf("/notes/%s", id), disableAuto, nil, false); err != nil {
+			if err := c.Request(ctx, "PATCH", fmt.Sprintf("/notes/%s", id), disableAuto, nil, false); err != nil {
 				return nil, err
 			}
 			// Retry original update
-			err = c.Request("PATCH", fmt.Sprintf("/notes/%s", id), payload, &response, false)
+			err = c.Request(ctx, "PATCH", fmt.Sprintf("/notes/%s", id), payload, &response, false)
 		}
 		return response, err
 	}
-	err := c.Request("PATCH", fmt.Sprintf("/notes/%s", id), payload, &response, false)
+	err := c.Request(ctx, "PATCH", fmt.Sprintf("/notes/%s", id), payload, &response, false)
 	return response, err
 }
 
-func (c *Client) DeleteNote(id string) error {
-	return c.Request("DELETE", fmt.Sprintf("/notes/%s", id), nil, nil, false)
+func (c *Client) DeleteNote(ctx context.Context, id string) error {
+	return c.Request(ctx, "DELETE", fmt.Sprintf("/notes/%s", id), nil, nil, false)
+}
+
+// CreateNoteTyped creates a note from a typed payload
+func (c *Client) CreateNoteTyped(ctx context.Context, note *Note) (*Note, error) {
+	var response Note
+	if err := c.Request(ctx, "POST", "/notes", note, &response, false); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetNoteTyped gets a note by ID
+func (c *Client) GetNoteTyped(ctx context.Context, id string) (*Note, error) {
+	var response Note
+	if err := c.Request(ctx, "GET", fmt.Sprintf("/notes/%s", id), nil, &response, false); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// UpdateNoteTyped patches the fields set in note, with the same colour retry as UpdateNote
+func (c *Client) UpdateNoteTyped(ctx context.Context, id string, note *Note) (*Note, error) {
+	payload, err := toMap(note)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := c.UpdateNote(ctx, id, payload)
+	if err != nil {
+		return nil, err
+	}
+	var response Note
+	if err := fromMap(raw, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
 }
 
 // PDF methods
-func (c *Client) CreatePDF(filePath string, metadata map[string]interface{}) (map[string]interface{}, error) {
-	return c.uploadFile("/pdfs", filePath, metadata)
+func (c *Client) CreatePDF(ctx context.Context, filePath string, metadata map[string]interface{}) (map[string]interface{}, error) {
+	return c.uploadFile(ctx, "/pdfs", filePath, metadata)
 }
 
-func (c *Client) GetPDF(id string, subscribe bool) (map[string]interface{}, error) {
+func (c *Client) GetPDF(ctx context.Context, id string, subscribe bool) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("GET", fmt.Sprintf("/pdfs/%s", id), nil, &response, subscribe)
+	err := c.Request(ctx, "GET", fmt.Sprintf("/pdfs/%s", id), nil, &response, subscribe)
 	return response, err
 }
 
-func (c *Client) UpdatePDF(id string, payload map[string]interface{}) (map[string]interface{}, error) {
+func (c *Client) UpdatePDF(ctx context.Context, id string, payload map[string]interface{}) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("PATCH", fmt.Sprintf("/pdfs/%s", id), payload, &response, false)
+	err := c.Request(ctx, "PATCH", fmt.Sprintf("/pdfs/%s", id), payload, &response, false)
 	return response, err
 }
 
-func (c *Client) DeletePDF(id string) error {
-	return c.Request("DELETE", fmt.Sprintf("/pdfs/%s", id), nil, nil, false)
+func (c *Client) DeletePDF(ctx context.Context, id string) error {
+	return c.Request(ctx, "DELETE", fmt.Sprintf("/pdfs/%s", id), nil, nil, false)
 }
 
 // Image methods
-func (c *Client) CreateImage(filePath string, metadata map[string]interface{}) (map[string]interface{}, error) {
-	return c.uploadFile("/images", filePath, metadata)
+func (c *Client) CreateImage(ctx context.Context, filePath string, metadata map[string]interface{}) (map[string]interface{}, error) {
+	return c.uploadFile(ctx, "/images", filePath, metadata)
+}
+
+// CreateImageData uploads in-memory image data as an image widget; filename
+// tells MCS the image format
+func (c *Client) CreateImageData(ctx context.Context, filename string, data []byte, metadata map[string]interface{}) (map[string]interface{}, error) {
+	return c.upload(ctx, "/images", filename, bytes.NewReader(data), metadata)
 }
 
-func (c *Client) GetImage(id string, subscribe bool) (map[string]interface{}, error) {
+func (c *Client) GetImage(ctx context.Context, id string, subscribe bool) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("GET", fmt.Sprintf("/images/%s", id), nil, &response, subscribe)
+	err := c.Request(ctx, "GET", fmt.Sprintf("/images/%s", id), nil, &response, subscribe)
 	return response, err
 }
 
-func (c *Client) UpdateImage(id string, payload map[string]interface{}) (map[string]interface{}, error) {
+func (c *Client) UpdateImage(ctx context.Context, id string, payload map[string]interface{}) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("PATCH", fmt.Sprintf("/images/%s", id), payload, &response, false)
+	err := c.Request(ctx, "PATCH", fmt.Sprintf("/images/%s", id), payload, &response, false)
 	return response, err
 }
 
-func (c *Client) DeleteImage(id string) error {
-	return c.Request("DELETE", fmt.Sprintf("/images/%s", id), nil, nil, false)
+func (c *Client) DeleteImage(ctx context.Context, id string) error {
+	return c.Request(ctx, "DELETE", fmt.Sprintf("/images/%s", id), nil, nil, false)
+}
+
+// CreateImageTyped uploads filePath as an image widget with the given metadata
+func (c *Client) CreateImageTyped(ctx context.Context, filePath string, metadata *Image) (*Image, error) {
+	meta, err := toMap(metadata)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := c.uploadFile(ctx, "/images", filePath, meta)
+	if err != nil {
+		return nil, err
+	}
+	var response Image
+	if err := fromMap(raw, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// CreateImageDataTyped uploads in-memory image data as an image widget with the given metadata
+func (c *Client) CreateImageDataTyped(ctx context.Context, filename string, data []byte, metadata *Image) (*Image, error) {
+	meta, err := toMap(metadata)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := c.CreateImageData(ctx, filename, data, meta)
+	if err != nil {
+		return nil, err
+	}
+	var response Image
+	if err := fromMap(raw, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetImageTyped gets an image widget by ID
+func (c *Client) GetImageTyped(ctx context.Context, id string) (*Image, error) {
+	var response Image
+	if err := c.Request(ctx, "GET", fmt.Sprintf("/images/%s", id), nil, &response, false); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// UpdateImageTyped patches the fields set in image
+func (c *Client) UpdateImageTyped(ctx context.Context, id string, image *Image) (*Image, error) {
+	var response Image
+	if err := c.Request(ctx, "PATCH", fmt.Sprintf("/images/%s", id), image, &response, false); err != nil {
+		return nil, err
+	}
+	return &response, nil
 }
 
 // Video methods
-func (c *Client) CreateVideo(filePath string, metadata map[string]interface{}) (map[string]interface{}, error) {
-	return c.uploadFile("/videos", filePath, metadata)
+func (c *Client) CreateVideo(ctx context.Context, filePath string, metadata map[string]interface{}) (map[string]interface{}, error) {
+	return c.uploadFile(ctx, "/videos", filePath, metadata)
 }
 
-func (c *Client) GetVideo(id string, subscribe bool) (map[string]interface{}, error) {
+func (c *Client) GetVideo(ctx context.Context, id string, subscribe bool) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("GET", fmt.Sprintf("/videos/%s", id), nil, &response, subscribe)
+	err := c.Request(ctx, "GET", fmt.Sprintf("/videos/%s", id), nil, &response, subscribe)
 	return response, err
 }
 
-func (c *Client) UpdateVideo(id string, payload map[string]interface{}) (map[string]interface{}, error) {
+func (c *Client) UpdateVideo(ctx context.Context, id string, payload map[string]interface{}) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("PATCH", fmt.Sprintf("/videos/%s", id), payload, &response, false)
+	err := c.Request(ctx, "PATCH", fmt.Sprintf("/videos/%s", id), payload, &response, false)
 	return response, err
 }
 
-func (c *Client) DeleteVideo(id string) error {
-	return c.Request("DELETE", fmt.Sprintf("/videos/%s", id), nil, nil, false)
+func (c *Client) DeleteVideo(ctx context.Context, id string) error {
+	return c.Request(ctx, "DELETE", fmt.Sprintf("/videos/%s", id), nil, nil, false)
 }
 
 // Browser methods
-func (c *Client) CreateBrowser(payload map[string]interface{}) (map[string]interface{}, error) {
+func (c *Client) CreateBrowser(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("POST", "/browsers", payload, &response, false)
+	err := c.Request(ctx, "POST", "/browsers", payload, &response, false)
 	return response, err
 }
 
-func (c *Client) GetBrowser(id string, subscribe bool) (map[string]interface{}, error) {
+func (c *Client) GetBrowser(ctx context.Context, id string, subscribe bool) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("GET", fmt.Sprintf("/browsers/%s", id), nil, &response, subscribe)
+	err := c.Request(ctx, "GET", fmt.Sprintf("/browsers/%s", id), nil, &response, subscribe)
 	return response, err
 }
 
-func (c *Client) UpdateBrowser(id string, payload map[string]interface{}) (map[string]interface{}, error) {
+func (c *Client) UpdateBrowser(ctx context.Context, id string, payload map[string]interface{}) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("PATCH", fmt.Sprintf("/browsers/%s", id), payload, &response, false)
+	err := c.Request(ctx, "PATCH", fmt.Sprintf("/browsers/%s", id), payload, &response, false)
 	return response, err
 }
 
-func (c *Client) DeleteBrowser(id string) error {
-	return c.Request("DELETE", fmt.Sprintf("/browsers/%s", id), nil, nil, false)
+func (c *Client) DeleteBrowser(ctx context.Context, id string) error {
+	return c.Request(ctx, "DELETE", fmt.Sprintf("/browsers/%s", id), nil, nil, false)
 }
 
-func (c *Client) DownloadBrowser(id string) ([]byte, error) {
-	url := c.buildURL(fmt.Sprintf("/browsers/%s/download", id))
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Private-Token", c.ApiKey)
-	resp, err := c.HTTP.Do(req)
+func (c *Client) DownloadBrowser(ctx context.Context, id string) ([]byte, error) {
+	resp, err := c.do(ctx, "GET", c.buildURL(fmt.Sprintf("/browsers/%s/download", id)), nil, "")
 	if err != nil {
 		return nil, fmt.Errorf("download request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, &APIError{
-			StatusCode: resp.StatusCode,
-			Message:    "download failed",
-		}
-	}
-
 	return io.ReadAll(resp.Body)
 }
 
 // Connector methods
-func (c *Client) CreateConnector(payload map[string]interface{}) (map[string]interface{}, error) {
+func (c *Client) CreateConnector(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("POST", "/connectors", payload, &response, false)
+	err := c.Request(ctx, "POST", "/connectors", payload, &response, false)
 	return response, err
 }
 
-func (c *Client) GetConnector(id string, subscribe bool) (map[string]interface{}, error) {
+func (c *Client) GetConnector(ctx context.Context, id string, subscribe bool) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("GET", fmt.Sprintf("/connectors/%s", id), nil, &response, subscribe)
+	err := c.Request(ctx, "GET", fmt.Sprintf("/connectors/%s", id), nil, &response, subscribe)
 	return response, err
 }
 
-func (c *Client) UpdateConnector(id string, payload map[string]interface{}) (map[string]interface{}, error) {
+func (c *Client) UpdateConnector(ctx context.Context, id string, payload map[string]interface{}) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("PATCH", fmt.Sprintf("/connectors/%s", id), payload, &response, false)
+	err := c.Request(ctx, "PATCH", fmt.Sprintf("/connectors/%s", id), payload, &response, false)
 	return response, err
 }
 
-func (c *Client) DeleteConnector(id string) error {
-	return c.Request("DELETE", fmt.Sprintf("/connectors/%s", id), nil, nil, false)
+func (c *Client) DeleteConnector(ctx context.Context, id string) error {
+	return c.Request(ctx, "DELETE", fmt.Sprintf("/connectors/%s", id), nil, nil, false)
+}
+
+// CreateConnectorTyped creates a connector from a typed payload
+func (c *Client) CreateConnectorTyped(ctx context.Context, connector *Connector) (*Connector, error) {
+	var response Connector
+	if err := c.Request(ctx, "POST", "/connectors", connector, &response, false); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetConnectorTyped gets a connector by ID
+func (c *Client) GetConnectorTyped(ctx context.Context, id string) (*Connector, error) {
+	var response Connector
+	if err := c.Request(ctx, "GET", fmt.Sprintf("/connectors/%s", id), nil, &response, false); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// UpdateConnectorTyped patches the fields set in connector
+func (c *Client) UpdateConnectorTyped(ctx context.Context, id string, connector *Connector) (*Connector, error) {
+	var response Connector
+	if err := c.Request(ctx, "PATCH", fmt.Sprintf("/connectors/%s", id), connector, &response, false); err != nil {
+		return nil, err
+	}
+	return &response, nil
 }
 
 // Anchor methods
-func (c *Client) CreateAnchor(payload map[string]interface{}) (map[string]interface{}, error) {
+func (c *Client) CreateAnchor(ctx context.Context, payload map[string]interface{}) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("POST", "/anchors", payload, &response, false)
+	err := c.Request(ctx, "POST", "/anchors", payload, &response, false)
 	return response, err
 }
 
-func (c *Client) GetAnchor(id string, subscribe bool) (map[string]interface{}, error) {
+func (c *Client) GetAnchor(ctx context.Context, id string, subscribe bool) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("GET", fmt.Sprintf("/anchors/%s", id), nil, &response, subscribe)
+	err := c.Request(ctx, "GET", fmt.Sprintf("/anchors/%s", id), nil, &response, subscribe)
 	return response, err
 }
 
-func (c *Client) UpdateAnchor(id string, payload map[string]interface{}) (map[string]interface{}, error) {
+func (c *Client) UpdateAnchor(ctx context.Context, id string, payload map[string]interface{}) (map[string]interface{}, error) {
 	var response map[string]interface{}
-	err := c.Request("PATCH", fmt.Sprintf("/anchors/%s", id), payload, &response, false)
+	err := c.Request(ctx, "PATCH", fmt.Sprintf("/anchors/%s", id), payload, &response, false)
+	return response, err
+}
+
+func (c *Client) DeleteAnchor(ctx context.Context, id string) error {
+	return c.Request(ctx, "DELETE", fmt.Sprintf("/anchors/%s", id), nil, nil, false)
+}
+
+// CreateAnchorTyped creates an anchor from a typed payload
+func (c *Client) CreateAnchorTyped(ctx context.Context, anchor *Anchor) (*Anchor, error) {
+	var response Anchor
+	if err := c.Request(ctx, "POST", "/anchors", anchor, &response, false); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetAnchorTyped gets an anchor by ID
+func (c *Client) GetAnchorTyped(ctx context.Context, id string) (*Anchor, error) {
+	var response Anchor
+	if err := c.Request(ctx, "GET", fmt.Sprintf("/anchors/%s", id), nil, &response, false); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetAnchorsTyped lists all anchors in the canvas
+func (c *Client) GetAnchorsTyped(ctx context.Context) ([]Anchor, error) {
+	var response []Anchor
+	err := c.Request(ctx, "GET", "/anchors", nil, &response, false)
 	return response, err
 }
 
-func (c *Client) DeleteAnchor(id string) error {
-	return c.Request("DELETE", fmt.Sprintf("/anchors/%s", id), nil, nil, false)
+// UpdateAnchorTyped patches the fields set in anchor
+func (c *Client) UpdateAnchorTyped(ctx context.Context, id string, anchor *Anchor) (*Anchor, error) {
+	var response Anchor
+	if err := c.Request(ctx, "PATCH", fmt.Sprintf("/anchors/%s", id), anchor, &response, false); err != nil {
+		return nil, err
+	}
+	return &response, nil
 }
 
 // GetWidgets gets all widgets in the canvas
-func (c *Client) GetWidgets(subscribe bool) ([]map[string]interface{}, error) {
+func (c *Client) GetWidgets(ctx context.Context, subscribe bool) ([]map[string]interface{}, error) {
 	var response []map[string]interface{}
 	url := fmt.Sprintf("/widgets")
 	if subscribe {
 		url += "?subscribe=true"
 	}
-	err := c.Request("GET", url, nil, &response, false)
+	err := c.Request(ctx, "GET", url, nil, &response, false)
 	return response, err
 }
 
 // GetWidget gets a single widget by ID
-func (c *Client) GetWidget(widgetID string, subscribe bool) (map[string]interface{}, error) {
+func (c *Client) GetWidget(ctx context.Context, widgetID string, subscribe bool) (map[string]interface{}, error) {
 	var response map[string]interface{}
 	url := fmt.Sprintf("/widgets/%s", widgetID)
 	if subscribe {
 		url += "?subscribe=true"
 	}
-	err := c.Request("GET", url, nil, &response, false)
+	err := c.Request(ctx, "GET", url, nil, &response, false)
+	return response, err
+}
+
+// GetWidgetsTyped gets all widgets in the canvas; use Widget.Decode for type-specific fields
+func (c *Client) GetWidgetsTyped(ctx context.Context) ([]Widget, error) {
+	var response []Widget
+	err := c.Request(ctx, "GET", "/widgets", nil, &response, false)
 	return response, err
 }
 
+// GetWidgetTyped gets a single widget by ID; use Widget.Decode for type-specific fields
+func (c *Client) GetWidgetTyped(ctx context.Context, widgetID string) (*Widget, error) {
+	var response Widget
+	if err := c.Request(ctx, "GET", fmt.Sprintf("/widgets/%s", widgetID), nil, &response, false); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
 // DownloadPDF downloads a PDF file
-func (c *Client) DownloadPDF(pdfID string, outputPath string) error {
-	return c.downloadFile(fmt.Sprintf("/pdfs/%s", pdfID), outputPath)
+func (c *Client) DownloadPDF(ctx context.Context, pdfID string, outputPath string) error {
+	return c.downloadFile(ctx, fmt.Sprintf("/pdfs/%s", pdfID), outputPath)
 }
 
 // DownloadImage downloads an image file
-func (c *Client) DownloadImage(imageID string, localPath string) error {
-	return c.downloadFile(fmt.Sprintf("/images/%s", imageID), localPath)
+func (c *Client) DownloadImage(ctx context.Context, imageID string, localPath string) error {
+	return c.downloadFile(ctx, fmt.Sprintf("/images/%s", imageID), localPath)
 }
 
 // DownloadVideo downloads a video file
-func (c *Client) DownloadVideo(videoID string, outputPath string) error {
-	return c.downloadFile(fmt.Sprintf("/videos/%s", videoID), outputPath)
+func (c *Client) DownloadVideo(ctx context.Context, videoID string, outputPath string) error {
+	return c.downloadFile(ctx, fmt.Sprintf("/videos/%s", videoID), outputPath)
 }
 
-// downloadFile is a helper function to download files
-func (c *Client) downloadFile(endpoint string, outputPath string) error {
-	url := fmt.Sprintf("%s/api/v1/canvases/%s%s/download",
-		c.Server,
-		c.CanvasID,
-		endpoint)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
-	}
-
-	req.Header.Set("Private-Token", c.ApiKey)
-
-	resp, err := http.DefaultClient.Do(req)
+// downloadFile is a helper function to download files. Downloads are only
+// bounded by ctx, since large files can legitimately take longer than c.Timeout.
+func (c *Client) downloadFile(ctx context.Context, endpoint string, outputPath string) error {
+	resp, err := c.do(ctx, "GET", c.buildURL(endpoint+"/download"), nil, "")
 	if err != nil {
-		return fmt.Errorf("failed to download file: %v", err)
+		return fmt.Errorf("failed to download file: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned status %d", resp.StatusCode)
-	}
-
 	out, err := os.Create(outputPath)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %v", err)
@@ -460,31 +605,42 @@ func (c *Client) downloadFile(endpoint string, outputPath string) error {
 	return err
 }
 
-// SubscribeToWidgets creates a subscription to the widgets stream
+// SubscribeToWidgets creates a subscription to the widgets stream. The stream
+// stays open until ctx is cancelled or the body is closed.
 func (c *Client) SubscribeToWidgets(ctx context.Context) (io.ReadCloser, error) {
-	url := fmt.Sprintf("%s/api/v1/canvases/%s/widgets?subscribe", c.Server, c.CanvasID)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	resp, err := c.do(ctx, "GET", c.buildURL("/widgets?subscribe"), nil, "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to connect to stream: %w", err)
 	}
+	return resp.Body, nil
+}
 
-	req.Header.Set("Private-Token", c.ApiKey)
+func (c *Client) DeleteWidget(ctx context.Context, widgetID string) error {
+	endpoint := fmt.Sprintf("/widgets/%s", widgetID)
+	return c.Request(ctx, "DELETE", endpoint, nil, nil, false)
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// toMap converts a typed payload to the map form used by the raw methods
+func toMap(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to stream: %w", err)
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
 	}
-
-	return resp.Body, nil
+	return out, nil
 }
 
-func (c *Client) DeleteWidget(widgetID string) error {
-	endpoint := fmt.Sprintf("/widgets/%s", widgetID)
-	return c.Request("DELETE", endpoint, nil, nil, false)
+// fromMap decodes a raw response map into a typed value
+func fromMap(m map[string]interface{}, out interface{}) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
 }
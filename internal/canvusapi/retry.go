@@ -0,0 +1,154 @@
+package canvusapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultTimeout bounds a single API call, including its retries
+const DefaultTimeout = 60 * time.Second
+
+// RetryPolicy controls how failed requests are retried. Idempotent requests
+// (GET, PUT, PATCH, DELETE) are retried on network errors and 5xx responses;
+// every request is retried on 429, which means the server did not act on it.
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts, including the first; 1 disables retries
+	BaseDelay   time.Duration // delay before the first retry, doubled on each attempt
+	MaxDelay    time.Duration // upper bound for the backoff delay
+}
+
+// DefaultRetryPolicy is used by clients created with NewClient
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 4, BaseDelay: 500 * time.Millisecond, MaxDelay: 10 * time.Second}
+
+// newTransport returns the base transport; one is shared by all clients
+// created with NewClient, so connections to MCS are pooled across requests
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ResponseHeaderTimeout = 30 * time.Second
+	t.MaxIdleConnsPerHost = 16
+	return t
+}
+
+// idempotent reports whether a request can safely be sent again. PATCH is
+// included because this client only ever sets fields to absolute values.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryableStatus reports whether a response status is worth retrying for method
+func retryableStatus(status int, method string) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent(method)
+	}
+	return false
+}
+
+// do sends a request with the API key, retrying according to c.Retry. It
+// returns the response for 2xx statuses (the caller closes the body) and an
+// *APIError otherwise.
+func (c *Client) do(ctx context.Context, method, url string, body []byte, contentType string) (*http.Response, error) {
+	policy := c.Retry
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Private-Token", c.ApiKey)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		var delay time.Duration
+		var lastErr error
+		resp, err := c.HTTP.Do(req)
+		switch {
+		case err != nil:
+			lastErr = fmt.Errorf("request failed: %w", err)
+			if ctx.Err() != nil || !idempotent(method) || attempt >= policy.MaxAttempts {
+				return nil, lastErr
+			}
+			delay = policy.backoff(attempt)
+			log.Printf("[canvusapi] %s %s failed (attempt %d/%d), retrying in %v: %v", method, url, attempt, policy.MaxAttempts, delay, err)
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			return resp, nil
+		default:
+			bodyBytes, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			lastErr = &APIError{StatusCode: resp.StatusCode, Message: string(bodyBytes)}
+			if !retryableStatus(resp.StatusCode, method) || attempt >= policy.MaxAttempts {
+				return nil, lastErr
+			}
+			var ok bool
+			if delay, ok = retryAfter(resp.Header.Get("Retry-After")); !ok {
+				delay = policy.backoff(attempt)
+			}
+			log.Printf("[canvusapi] %s %s returned %d (attempt %d/%d), retrying in %v", method, url, resp.StatusCode, attempt, policy.MaxAttempts, delay)
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return nil, fmt.Errorf("%w (retry cancelled: %v)", lastErr, err)
+		}
+	}
+}
+
+// backoff returns the jittered exponential delay before retry number attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Full delay with up to 50% jitter removed, so concurrent clients spread out
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
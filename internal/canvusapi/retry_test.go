@@ -0,0 +1,191 @@
+package canvusapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedServer answers each request with the next of its statuses; 0
+// drops the connection. Once the script runs out it answers 200.
+type scriptedServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	attempts int
+}
+
+func newScriptedServer(t *testing.T, statuses ...int) *scriptedServer {
+	s := &scriptedServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.attempts++
+		attempt := s.attempts
+		status := http.StatusOK
+		if attempt <= len(s.statuses) {
+			status = s.statuses[attempt-1]
+		}
+		s.mu.Unlock()
+		if r.Header.Get("Private-Token") != "test-key" {
+			t.Errorf("attempt %d: missing API key", attempt)
+		}
+		switch status {
+		case 0:
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		case http.StatusTooManyRequests:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+		default:
+			w.WriteHeader(status)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *scriptedServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts
+}
+
+func retryClient(url string, policy RetryPolicy) *Client {
+	c := NewClient(url, "canvas-1", "test-key")
+	c.Retry = policy
+	return c
+}
+
+func TestDoRetries(t *testing.T) {
+	fast := RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	tests := []struct {
+		name       string
+		method     string
+		statuses   []int
+		wantTries  int
+		wantStatus int // of the APIError; 0 for success, -1 for a network error
+	}{
+		{"GET through 503 and 429", http.MethodGet, []int{503, 429}, 3, 0},
+		{"POST not retried on 503", http.MethodPost, []int{503}, 1, 503},
+		{"POST retried on 429", http.MethodPost, []int{429, 429}, 3, 0},
+		{"PATCH retried on 502", http.MethodPatch, []int{502}, 2, 0},
+		{"DELETE gives up after MaxAttempts", http.MethodDelete, []int{500, 500, 500, 500, 500}, 4, 500},
+		{"client errors not retried", http.MethodGet, []int{404}, 1, 404},
+		{"GET retried on a dropped connection", http.MethodGet, []int{0}, 2, 0},
+		{"POST not retried on a dropped connection", http.MethodPost, []int{0}, 1, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newScriptedServer(t, tt.statuses...)
+			c := retryClient(srv.URL, fast)
+			var body []byte
+			if tt.method != http.MethodGet && tt.method != http.MethodDelete {
+				body = []byte(`{"text":"hi"}`)
+			}
+			resp, err := c.do(context.Background(), tt.method, srv.URL+"/widgets", body, "application/json")
+			if resp != nil {
+				resp.Body.Close()
+			}
+			if got := srv.count(); got != tt.wantTries {
+				t.Errorf("got %d attempts, want %d", got, tt.wantTries)
+			}
+			var apiErr *APIError
+			switch {
+			case tt.wantStatus == 0 && err != nil:
+				t.Errorf("got error %v", err)
+			case tt.wantStatus > 0 && (!errors.As(err, &apiErr) || apiErr.StatusCode != tt.wantStatus):
+				t.Errorf("got error %v, want API error %d", err, tt.wantStatus)
+			case tt.wantStatus < 0 && (err == nil || errors.As(err, &apiErr)):
+				t.Errorf("got error %v, want a network error", err)
+			}
+		})
+	}
+}
+
+func TestDoHonoursRetryAfter(t *testing.T) {
+	// The backoff would wait an hour; Retry-After: 0 retries at once
+	srv := newScriptedServer(t, 429)
+	c := retryClient(srv.URL, RetryPolicy{MaxAttempts: 2, BaseDelay: time.Hour, MaxDelay: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := c.do(ctx, http.MethodPost, srv.URL+"/widgets", []byte(`{}`), "application/json")
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	resp.Body.Close()
+	if srv.count() != 2 {
+		t.Errorf("got %d attempts, want 2", srv.count())
+	}
+}
+
+func TestDoCancelStopsRetries(t *testing.T) {
+	srv := newScriptedServer(t, 503, 503, 503)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Cancel while do waits an hour to retry the first 503
+	time.AfterFunc(100*time.Millisecond, cancel)
+	c := retryClient(srv.URL, RetryPolicy{MaxAttempts: 4, BaseDelay: time.Hour, MaxDelay: time.Hour})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.do(ctx, http.MethodGet, srv.URL+"/widgets", nil, "")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != 503 || !strings.Contains(err.Error(), "retry cancelled") {
+			t.Errorf("got error %v, want the 503 with the retry cancelled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("do kept waiting after the context was cancelled")
+	}
+	if srv.count() != 1 {
+		t.Errorf("got %d attempts, want 1", srv.count())
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.value)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("retryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got, ok := retryAfter(future); !ok || got <= 58*time.Second || got > time.Minute {
+		t.Errorf("retryAfter(%q) = %v, %v, want about a minute", future, got, ok)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for attempt, full := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 300 * time.Millisecond, 10: 300 * time.Millisecond} {
+		for i := 0; i < 20; i++ {
+			if d := p.backoff(attempt); d < full/2 || d > full {
+				t.Errorf("backoff(%d) = %v, want between %v and %v", attempt, d, full/2, full)
+			}
+		}
+	}
+	if d := (RetryPolicy{}).backoff(1); d != 0 {
+		t.Errorf("got %v with no delays set, want 0", d)
+	}
+}
@@ -0,0 +1,265 @@
+package canvusapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// EventType says what happened to a widget in a widget stream
+type EventType string
+
+const (
+	EventAdded   EventType = "added"
+	EventUpdated EventType = "updated"
+	EventDeleted EventType = "deleted"
+)
+
+// DefaultIdleTimeout is how long a widget stream may stay silent before it is
+// treated as dropped and reconnected
+const DefaultIdleTimeout = 2 * time.Minute
+
+// WidgetEvent is one change observed on the canvas
+type WidgetEvent struct {
+	Type   EventType `json:"type"`
+	Widget Widget    `json:"widget"`
+	// Resync is set for events derived from the full widget list the server
+	// sends on (re)connect, rather than from a live update
+	Resync bool `json:"resync,omitempty"`
+}
+
+// StreamOptions configures WatchWidgets
+type StreamOptions struct {
+	Buffer      int           // event channel capacity (default 64)
+	IdleTimeout time.Duration // reconnect after this long without data (default DefaultIdleTimeout, <0 disables)
+	MinBackoff  time.Duration // first reconnect delay (default 1s)
+	MaxBackoff  time.Duration // maximum reconnect delay (default 30s)
+	MaxRetries  int           // consecutive failed connects before giving up; 0 retries forever
+}
+
+// WidgetStream delivers live widget events for a canvas. It reconnects when
+// the connection drops and, on reconnect, compares the server's current
+// widgets with what it has seen, so changes made while disconnected are still
+// reported.
+type WidgetStream struct {
+	client *Client
+	opts   StreamOptions
+	events chan WidgetEvent
+
+	mu    sync.Mutex
+	known map[string]json.RawMessage // last seen encoding of each live widget, by ID
+	err   error
+}
+
+// WatchWidgets subscribes to the canvas widget stream. Events are delivered
+// until ctx is cancelled or reconnecting fails MaxRetries times in a row, after
+// which the channel is closed and Err reports why.
+func (c *Client) WatchWidgets(ctx context.Context, opts StreamOptions) *WidgetStream {
+	if opts.Buffer <= 0 {
+		opts.Buffer = 64
+	}
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 30 * time.Second
+	}
+	s := &WidgetStream{
+		client: c,
+		opts:   opts,
+		events: make(chan WidgetEvent, opts.Buffer),
+		known:  map[string]json.RawMessage{},
+	}
+	go s.run(ctx)
+	return s
+}
+
+// Events returns the channel events are delivered on
+func (s *WidgetStream) Events() <-chan WidgetEvent {
+	return s.events
+}
+
+// Err returns why the stream stopped, once Events has been closed
+func (s *WidgetStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *WidgetStream) run(ctx context.Context) {
+	defer close(s.events)
+	policy := RetryPolicy{BaseDelay: s.opts.MinBackoff, MaxDelay: s.opts.MaxBackoff}
+	failures := 0
+	for {
+		received, err := s.connect(ctx)
+		if ctx.Err() != nil {
+			s.setErr(ctx.Err())
+			return
+		}
+		if received {
+			failures = 0
+		}
+		failures++
+		if s.opts.MaxRetries > 0 && failures > s.opts.MaxRetries {
+			s.setErr(fmt.Errorf("widget stream gave up after %d attempts: %w", failures, err))
+			return
+		}
+		delay := policy.backoff(failures)
+		log.Printf("[WatchWidgets] Stream for canvas %s dropped (%v), reconnecting in %v", s.client.CanvasID, err, delay)
+		if sleep(ctx, delay) != nil {
+			s.setErr(ctx.Err())
+			return
+		}
+	}
+}
+
+func (s *WidgetStream) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// connect reads one subscription until it ends, reporting whether any data arrived
+func (s *WidgetStream) connect(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	body, err := s.client.SubscribeToWidgets(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer body.Close()
+
+	// Closing the body unblocks the reader when the connection goes quiet
+	var idle *time.Timer
+	if s.opts.IdleTimeout > 0 {
+		idle = time.AfterFunc(s.opts.IdleTimeout, func() { body.Close() })
+		defer idle.Stop()
+	}
+
+	reader := bufio.NewReader(body)
+	first, received := true, false
+	for {
+		line, err := reader.ReadBytes('\n')
+		// Pause the idle timer while events are delivered to a slow consumer
+		if idle != nil && !idle.Stop() {
+			return received, errors.New("stream idle timeout")
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			received = true
+			widgets, perr := parseWidgetLine(line)
+			if perr != nil {
+				log.Printf("[WatchWidgets] Skipping unparseable line: %v", perr)
+			} else if first {
+				// The server starts every subscription with the full widget list
+				s.resync(ctx, widgets)
+				first = false
+			} else {
+				s.apply(ctx, widgets)
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("stream closed by server")
+			}
+			return received, err
+		}
+		if idle != nil {
+			idle.Reset(s.opts.IdleTimeout)
+		}
+	}
+}
+
+// parseWidgetLine parses one line of the stream: a JSON array of widgets or a single widget
+func parseWidgetLine(line []byte) ([]Widget, error) {
+	if line[0] == '{' {
+		var w Widget
+		if err := json.Unmarshal(line, &w); err != nil {
+			return nil, err
+		}
+		return []Widget{w}, nil
+	}
+	var widgets []Widget
+	if err := json.Unmarshal(line, &widgets); err != nil {
+		return nil, err
+	}
+	return widgets, nil
+}
+
+// apply turns live updates into events
+func (s *WidgetStream) apply(ctx context.Context, widgets []Widget) {
+	for _, w := range widgets {
+		if w.ID == "" {
+			continue
+		}
+		raw, _ := json.Marshal(w)
+		s.mu.Lock()
+		_, seen := s.known[w.ID]
+		event := EventAdded
+		switch {
+		case w.State == "deleted":
+			delete(s.known, w.ID)
+			event = EventDeleted
+		case seen:
+			s.known[w.ID] = raw
+			event = EventUpdated
+		default:
+			s.known[w.ID] = raw
+		}
+		s.mu.Unlock()
+		s.send(ctx, WidgetEvent{Type: event, Widget: w})
+	}
+}
+
+// resync compares a full widget list with the known state and reports the differences
+func (s *WidgetStream) resync(ctx context.Context, widgets []Widget) {
+	var events []WidgetEvent
+	s.mu.Lock()
+	current := make(map[string]bool, len(widgets))
+	for _, w := range widgets {
+		if w.ID == "" || w.State == "deleted" {
+			continue
+		}
+		current[w.ID] = true
+		raw, _ := json.Marshal(w)
+		old, seen := s.known[w.ID]
+		switch {
+		case !seen:
+			events = append(events, WidgetEvent{Type: EventAdded, Widget: w, Resync: true})
+		case !bytes.Equal(old, raw):
+			events = append(events, WidgetEvent{Type: EventUpdated, Widget: w, Resync: true})
+		}
+		s.known[w.ID] = raw
+	}
+	for id, raw := range s.known {
+		if current[id] {
+			continue
+		}
+		var w Widget
+		json.Unmarshal(raw, &w)
+		w.State = "deleted"
+		events = append(events, WidgetEvent{Type: EventDeleted, Widget: w, Resync: true})
+		delete(s.known, id)
+	}
+	s.mu.Unlock()
+	for _, e := range events {
+		s.send(ctx, e)
+	}
+}
+
+// send delivers an event, giving up if the stream is cancelled
+func (s *WidgetStream) send(ctx context.Context, e WidgetEvent) {
+	select {
+	case s.events <- e:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,186 @@
+package canvusapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// streamServer serves one scripted session per subscription: its lines are
+// sent one by one, then the connection is dropped, or held open silently if
+// the session ends with hold. Later subscriptions get 404.
+type streamServer struct {
+	*httptest.Server
+	mu          sync.Mutex
+	sessions    [][]string
+	connections int
+	done        chan struct{}
+}
+
+const hold = "<hold>"
+
+func newStreamServer(t *testing.T, sessions ...[]string) *streamServer {
+	s := &streamServer{sessions: sessions, done: make(chan struct{})}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/canvases/canvas-1/widgets" || !r.URL.Query().Has("subscribe") {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		s.mu.Lock()
+		n := s.connections
+		s.connections++
+		s.mu.Unlock()
+		if n >= len(s.sessions) {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		for _, line := range s.sessions[n] {
+			if line == hold {
+				select {
+				case <-r.Context().Done():
+				case <-s.done:
+				}
+				return
+			}
+			fmt.Fprintln(w, line)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(s.Close)
+	t.Cleanup(func() { close(s.done) })
+	return s
+}
+
+func (s *streamServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections
+}
+
+func streamClient(url string) *Client {
+	c := NewClient(url, "canvas-1", "test-key")
+	c.Retry = RetryPolicy{MaxAttempts: 1}
+	return c
+}
+
+// nextEvents reads n events and formats each as "type id", prefixed with
+// "resync " for resync events
+func nextEvents(t *testing.T, s *WidgetStream, n int) []string {
+	t.Helper()
+	var got []string
+	for len(got) < n {
+		select {
+		case e, ok := <-s.Events():
+			if !ok {
+				t.Fatalf("stream closed after %v: %v", got, s.Err())
+			}
+			desc := fmt.Sprintf("%s %s", e.Type, e.Widget.ID)
+			if e.Resync {
+				desc = "resync " + desc
+			}
+			if e.Type == EventDeleted && e.Widget.State != "deleted" {
+				t.Errorf("%s: state is %q", desc, e.Widget.State)
+			}
+			got = append(got, desc)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %v, timed out waiting for %d events", got, n)
+		}
+	}
+	return got
+}
+
+func expectEvents(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("got events\n  %s\nwant\n  %s", strings.Join(got, ", "), strings.Join(want, ", "))
+	}
+}
+
+func TestWatchWidgetsReconnects(t *testing.T) {
+	srv := newStreamServer(t,
+		[]string{
+			`[{"id":"n1","widget_type":"Note","text":"one"},{"id":"n2","widget_type":"Note","text":"two"},{"id":"n3","widget_type":"Note"}]`,
+			`{"id":"n1","widget_type":"Note","text":"one, edited"}`,
+			`not json`,
+			``,
+			`{"id":"n3","widget_type":"Note","state":"deleted"}`,
+			`[{"id":"n4","widget_type":"Note"}]`,
+			// dropped here
+		},
+		[]string{
+			// While disconnected n2 was edited, n4 deleted and n5 added;
+			// n1 is unchanged and the deleted n6 was never seen
+			`[{"id":"n1","widget_type":"Note","text":"one, edited"},{"id":"n2","widget_type":"Note","text":"two, edited"},` +
+				`{"id":"n5","widget_type":"Note"},{"id":"n6","widget_type":"Note","state":"deleted"}]`,
+			`{"id":"n5","widget_type":"Note","state":"deleted"}`,
+			hold,
+		},
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := streamClient(srv.URL).WatchWidgets(ctx, StreamOptions{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+	expectEvents(t, nextEvents(t, stream, 6),
+		"resync added n1", "resync added n2", "resync added n3",
+		"updated n1", "deleted n3", "added n4")
+	expectEvents(t, nextEvents(t, stream, 4),
+		"resync updated n2", "resync added n5", "resync deleted n4", "deleted n5")
+	if srv.count() != 2 {
+		t.Errorf("got %d connections, want 2", srv.count())
+	}
+
+	cancel()
+	for range stream.Events() {
+		t.Error("got an event after cancelling")
+	}
+	if stream.Err() != context.Canceled {
+		t.Errorf("got Err %v, want context.Canceled", stream.Err())
+	}
+}
+
+func TestWatchWidgetsIdleTimeout(t *testing.T) {
+	srv := newStreamServer(t,
+		[]string{`[{"id":"n1","widget_type":"Note"}]`, hold},
+		[]string{`[{"id":"n1","widget_type":"Note"},{"id":"n2","widget_type":"Note"}]`, hold},
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := streamClient(srv.URL).WatchWidgets(ctx, StreamOptions{
+		IdleTimeout: 100 * time.Millisecond,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+	})
+	// The silent first connection is dropped and the second resyncs
+	expectEvents(t, nextEvents(t, stream, 2), "resync added n1", "resync added n2")
+	if srv.count() != 2 {
+		t.Errorf("got %d connections, want 2", srv.count())
+	}
+}
+
+func TestWatchWidgetsGivesUp(t *testing.T) {
+	srv := newStreamServer(t)
+	stream := streamClient(srv.URL).WatchWidgets(context.Background(), StreamOptions{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: time.Millisecond,
+		MaxRetries: 2,
+	})
+	select {
+	case _, ok := <-stream.Events():
+		if ok {
+			t.Fatal("got an event from a server that refuses every subscription")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not give up")
+	}
+	if err := stream.Err(); err == nil || !strings.Contains(err.Error(), "gave up after 3 attempts") {
+		t.Errorf("got Err %v", err)
+	}
+	if srv.count() != 3 {
+		t.Errorf("got %d connections, want 3", srv.count())
+	}
+}
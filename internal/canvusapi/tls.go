@@ -0,0 +1,210 @@
+package canvusapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TLSOptions configures how MCS server certificates are verified. With no
+// options set the system trust store is used.
+type TLSOptions struct {
+	CAFile      string // PEM bundle trusted in addition to the system roots
+	Fingerprint string // SHA-256 of the server's leaf certificate (hex, colons optional); replaces chain verification
+	Insecure    bool   // skip verification entirely; only for testing
+}
+
+// ErrFingerprintMismatch is returned when the server certificate does not match the pinned fingerprint
+var ErrFingerprintMismatch = errors.New("server certificate does not match the pinned fingerprint")
+
+var (
+	transportMu     sync.RWMutex
+	sharedTransport http.RoundTripper = newTransport()
+)
+
+// SetTLSOptions replaces the transport shared by clients created with
+// NewClient. Existing clients keep the transport they were created with.
+func SetTLSOptions(opts TLSOptions) error {
+	t, err := NewTransport(opts)
+	if err != nil {
+		return err
+	}
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	sharedTransport = t
+	return nil
+}
+
+// currentTransport returns the shared transport
+func currentTransport() http.RoundTripper {
+	transportMu.RLock()
+	defer transportMu.RUnlock()
+	return sharedTransport
+}
+
+// NewTransport returns a transport that verifies servers according to opts
+func NewTransport(opts TLSOptions) (*http.Transport, error) {
+	t := newTransport()
+	cfg, err := opts.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	t.TLSClientConfig = cfg
+	return t, nil
+}
+
+func (o TLSOptions) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", o.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if o.Fingerprint != "" {
+		want, err := parseFingerprint(o.Fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		// The pin replaces chain and hostname verification, which is what makes
+		// it usable with self-signed certificates
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return ErrFingerprintMismatch
+			}
+			if sum := sha256.Sum256(rawCerts[0]); hex.EncodeToString(sum[:]) != want {
+				return ErrFingerprintMismatch
+			}
+			return nil
+		}
+	}
+	if o.Insecure {
+		log.Printf("[canvusapi] WARNING: TLS certificate verification is DISABLED for MCS connections. Anyone on the network can intercept the API key. Use a CA file or a pinned fingerprint instead.")
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyPeerCertificate = nil
+	}
+	return cfg, nil
+}
+
+// parseFingerprint normalises a SHA-256 fingerprint to lowercase hex without separators
+func parseFingerprint(fp string) (string, error) {
+	fp = strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(strings.TrimSpace(fp)))
+	fp = strings.TrimPrefix(fp, "sha256/")
+	if b, err := hex.DecodeString(fp); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid certificate fingerprint: expected a SHA-256 hex digest")
+	}
+	return fp, nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a certificate as colon-separated hex
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// IsTLSError reports whether err was caused by certificate verification
+func IsTLSError(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var verification *tls.CertificateVerificationError
+	return errors.Is(err, ErrFingerprintMismatch) ||
+		errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostname) ||
+		errors.As(err, &invalid) ||
+		errors.As(err, &verification)
+}
+
+// FetchCertificate connects to server without verification and returns the
+// leaf certificate it presents, so a user can decide whether to pin it
+func FetchCertificate(ctx context.Context, server string) (*x509.Certificate, error) {
+	u, err := url.Parse(server)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q", server)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("server %s does not use https", server)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "443")
+	}
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: 10 * time.Second},
+		Config:    &tls.Config{InsecureSkipVerify: true, ServerName: u.Hostname()},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, errors.New("server presented no certificate")
+	}
+	return certs[0], nil
+}
+
+// ConnectionInfo describes a successful test connection
+type ConnectionInfo struct {
+	Canvases    int    `json:"canvases"`
+	TLSVersion  string `json:"tlsVersion,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Subject     string `json:"subject,omitempty"`
+	Issuer      string `json:"issuer,omitempty"`
+	NotAfter    string `json:"notAfter,omitempty"`
+}
+
+// TestConnection checks that the server is reachable, trusted and accepts the
+// API key, by listing canvases once without retries
+func (c *Client) TestConnection(ctx context.Context) (*ConnectionInfo, error) {
+	probe := *c
+	probe.Retry = RetryPolicy{MaxAttempts: 1}
+	ctx, cancel := probe.withTimeout(ctx)
+	defer cancel()
+	resp, err := probe.do(ctx, "GET", strings.TrimRight(c.Server, "/")+"/api/v1/canvases", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var canvases []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&canvases); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	info := &ConnectionInfo{Canvases: len(canvases)}
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		leaf := resp.TLS.PeerCertificates[0]
+		info.TLSVersion = tls.VersionName(resp.TLS.Version)
+		info.Fingerprint = Fingerprint(leaf)
+		info.Subject = leaf.Subject.String()
+		info.Issuer = leaf.Issuer.String()
+		info.NotAfter = leaf.NotAfter.Format(time.RFC3339)
+	}
+	return info, nil
+}
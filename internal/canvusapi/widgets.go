@@ -0,0 +1,271 @@
+package canvusapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Widget type discriminators, as returned in "widget_type"
+const (
+	TypeNote         = "Note"
+	TypeAnchor       = "Anchor"
+	TypeImage        = "Image"
+	TypeConnector    = "Connector"
+	TypePDF          = "Pdf"
+	TypeVideo        = "Video"
+	TypeBrowser      = "Browser"
+	TypeSharedCanvas = "SharedCanvas"
+)
+
+// Location is a widget position, relative to its parent widget
+type Location struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Size is a widget size in the widget's own (unscaled) units
+type Size struct {
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// WidgetBase holds the fields shared by all widget types. Pointer and
+// omitempty fields are left out of payloads when unset, so the same types
+// work for create, update (PATCH) and responses.
+type WidgetBase struct {
+	ID         string    `json:"id,omitempty"`
+	WidgetType string    `json:"widget_type,omitempty"`
+	ParentID   string    `json:"parent_id,omitempty"`
+	Location   *Location `json:"location,omitempty"`
+	Size       *Size     `json:"size,omitempty"`
+	Scale      *float64  `json:"scale,omitempty"`
+	Depth      *float64  `json:"depth,omitempty"`
+	State      string    `json:"state,omitempty"`
+	Pinned     *bool     `json:"pinned,omitempty"`
+}
+
+// Widget is any widget. Fields specific to its type are kept in Extra; use
+// Decode to get the typed value.
+type Widget struct {
+	WidgetBase
+	Extra map[string]json.RawMessage `json:"-"` // fields not modelled above, kept for round-tripping
+}
+
+// Note is a sticky note widget
+type Note struct {
+	WidgetBase
+	Text            string                     `json:"text,omitempty"`
+	Title           string                     `json:"title,omitempty"`
+	BackgroundColor string                     `json:"background_color,omitempty"`
+	TextColor       string                     `json:"text_color,omitempty"`
+	AutoTextColor   *bool                      `json:"auto_text_color,omitempty"`
+	Extra           map[string]json.RawMessage `json:"-"`
+}
+
+// Anchor is a named zone on the canvas
+type Anchor struct {
+	WidgetBase
+	AnchorName string                     `json:"anchor_name,omitempty"`
+	Extra      map[string]json.RawMessage `json:"-"`
+}
+
+// Image is an image widget
+type Image struct {
+	WidgetBase
+	Title            string                     `json:"title,omitempty"`
+	OriginalFilename string                     `json:"original_filename,omitempty"`
+	Hash             string                     `json:"hash,omitempty"`
+	Extra            map[string]json.RawMessage `json:"-"`
+}
+
+// ConnectorEnd is one end of a connector
+type ConnectorEnd struct {
+	ID           string    `json:"id"`
+	RelLocation  *Location `json:"rel_location,omitempty"`
+	Tip          string    `json:"tip,omitempty"`
+	AutoLocation *bool     `json:"auto_location,omitempty"`
+}
+
+// Connector is a line between two widgets
+type Connector struct {
+	WidgetBase
+	Src       *ConnectorEnd              `json:"src,omitempty"`
+	Dst       *ConnectorEnd              `json:"dst,omitempty"`
+	LineColor string                     `json:"line_color,omitempty"`
+	LineWidth float64                    `json:"line_width,omitempty"`
+	Type      string                     `json:"type,omitempty"`
+	Extra     map[string]json.RawMessage `json:"-"`
+}
+
+// Bool returns a pointer to b, for the optional bool fields
+func Bool(b bool) *bool {
+	return &b
+}
+
+// Float returns a pointer to f, for the optional number fields
+func Float(f float64) *float64 {
+	return &f
+}
+
+// ScaleValue returns the widget's scale, or 1 if it is not set
+func (w WidgetBase) ScaleValue() float64 {
+	if w.Scale == nil || *w.Scale == 0 {
+		return 1
+	}
+	return *w.Scale
+}
+
+// DepthValue returns the widget's depth, or 0 if it is not set
+func (w WidgetBase) DepthValue() float64 {
+	if w.Depth == nil {
+		return 0
+	}
+	return *w.Depth
+}
+
+func (w Widget) MarshalJSON() ([]byte, error) {
+	type plain Widget
+	return marshalWithExtra(plain(w), w.Extra)
+}
+
+func (w *Widget) UnmarshalJSON(data []byte) error {
+	type plain Widget
+	return unmarshalWithExtra(data, (*plain)(w), &w.Extra)
+}
+
+func (n Note) MarshalJSON() ([]byte, error) {
+	type plain Note
+	return marshalWithExtra(plain(n), n.Extra)
+}
+
+func (n *Note) UnmarshalJSON(data []byte) error {
+	type plain Note
+	return unmarshalWithExtra(data, (*plain)(n), &n.Extra)
+}
+
+func (a Anchor) MarshalJSON() ([]byte, error) {
+	type plain Anchor
+	return marshalWithExtra(plain(a), a.Extra)
+}
+
+func (a *Anchor) UnmarshalJSON(data []byte) error {
+	type plain Anchor
+	return unmarshalWithExtra(data, (*plain)(a), &a.Extra)
+}
+
+func (i Image) MarshalJSON() ([]byte, error) {
+	type plain Image
+	return marshalWithExtra(plain(i), i.Extra)
+}
+
+func (i *Image) UnmarshalJSON(data []byte) error {
+	type plain Image
+	return unmarshalWithExtra(data, (*plain)(i), &i.Extra)
+}
+
+func (c Connector) MarshalJSON() ([]byte, error) {
+	type plain Connector
+	return marshalWithExtra(plain(c), c.Extra)
+}
+
+func (c *Connector) UnmarshalJSON(data []byte) error {
+	type plain Connector
+	return unmarshalWithExtra(data, (*plain)(c), &c.Extra)
+}
+
+// Decode returns the widget as *Note, *Anchor, *Image or *Connector according
+// to its widget_type, or the *Widget itself for other types
+func (w *Widget) Decode() (interface{}, error) {
+	var out interface{}
+	switch w.WidgetType {
+	case TypeNote:
+		out = &Note{}
+	case TypeAnchor:
+		out = &Anchor{}
+	case TypeImage:
+		out = &Image{}
+	case TypeConnector:
+		out = &Connector{}
+	default:
+		return w, nil
+	}
+	data, err := json.Marshal(w)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, fmt.Errorf("failed to decode %s widget %s: %w", w.WidgetType, w.ID, err)
+	}
+	return out, nil
+}
+
+// marshalWithExtra encodes v and adds the extra fields it does not set itself
+func marshalWithExtra(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	known := jsonFieldNames(reflect.TypeOf(v))
+	for k, raw := range extra {
+		if _, set := fields[k]; !set && !known[k] {
+			fields[k] = raw
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// unmarshalWithExtra decodes data into v and stores the fields v does not model in extra
+func unmarshalWithExtra(data []byte, v interface{}, extra *map[string]json.RawMessage) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	known := jsonFieldNames(reflect.TypeOf(v))
+	*extra = nil
+	for k, raw := range fields {
+		if known[k] {
+			continue
+		}
+		if *extra == nil {
+			*extra = map[string]json.RawMessage{}
+		}
+		(*extra)[k] = raw
+	}
+	return nil
+}
+
+// jsonFieldNames returns the JSON names of t's fields, including embedded structs
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for k := range jsonFieldNames(f.Type) {
+				names[k] = true
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names[name] = true
+	}
+	return names
+}
@@ -0,0 +1,168 @@
+package canvusapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// Server responses with every modelled field set, plus fields this package
+// does not know about
+var widgetFixtures = map[string]string{
+	TypeNote: `{"id":"n1","widget_type":"Note","parent_id":"a1","location":{"x":10.5,"y":-20},"size":{"width":300,"height":200},
+		"scale":1.5,"depth":0,"state":"normal","pinned":false,"text":"Hello","title":"T","background_color":"#FFFF88FF",
+		"text_color":"#000000FF","auto_text_color":false,"z_custom":{"nested":[1,"two",null]},"text_scale":0.75}`,
+	TypeAnchor: `{"id":"a1","widget_type":"Anchor","location":{"x":0,"y":0},"size":{"width":1000,"height":500},
+		"scale":1,"depth":-3,"state":"normal","pinned":true,"anchor_name":"Board","anchor_index":7}`,
+	TypeImage: `{"id":"i1","widget_type":"Image","parent_id":"a1","location":{"x":1,"y":2},"size":{"width":640,"height":480},
+		"scale":0.5,"state":"normal","title":"scan","original_filename":"scan.jpg","hash":"abc123","mime":"image/jpeg"}`,
+	TypeConnector: `{"id":"c1","widget_type":"Connector","state":"normal","line_color":"#FF0000FF","line_width":4,"type":"curve",
+		"src":{"id":"n1","rel_location":{"x":0.5,"y":1},"tip":"none","auto_location":true},
+		"dst":{"id":"n2","rel_location":{"x":0,"y":0.5},"tip":"solid-equilateral-triangle","auto_location":false},
+		"curve_points":[{"x":1,"y":2}]}`,
+}
+
+// roundTrip decodes fixture into v and encodes it again
+func roundTrip(t *testing.T, fixture string, v interface{}) map[string]interface{} {
+	t.Helper()
+	if err := json.Unmarshal([]byte(fixture), v); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestWidgetRoundTrip(t *testing.T) {
+	tests := []struct {
+		widgetType string
+		value      func() interface{}
+		unknown    []string
+	}{
+		{TypeNote, func() interface{} { return &Note{} }, []string{"z_custom", "text_scale"}},
+		{TypeAnchor, func() interface{} { return &Anchor{} }, []string{"anchor_index"}},
+		{TypeImage, func() interface{} { return &Image{} }, []string{"mime"}},
+		{TypeConnector, func() interface{} { return &Connector{} }, []string{"curve_points"}},
+		{"Widget", func() interface{} { return &Widget{} }, []string{"text", "title", "background_color", "text_color", "auto_text_color", "z_custom", "text_scale"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.widgetType, func(t *testing.T) {
+			fixture := widgetFixtures[tt.widgetType]
+			if tt.widgetType == "Widget" {
+				fixture = widgetFixtures[TypeNote]
+			}
+			v := tt.value()
+			got := roundTrip(t, fixture, v)
+			var want map[string]interface{}
+			json.Unmarshal([]byte(fixture), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip changed the widget\ngot  %v\nwant %v", got, want)
+			}
+
+			// Only fields the type does not model end up in Extra
+			extra := reflect.ValueOf(v).Elem().FieldByName("Extra").Interface().(map[string]json.RawMessage)
+			if len(extra) != len(tt.unknown) {
+				t.Errorf("got Extra %v, want only %v", extra, tt.unknown)
+			}
+			for _, k := range tt.unknown {
+				if _, ok := extra[k]; !ok {
+					t.Errorf("Extra is missing %q", k)
+				}
+			}
+		})
+	}
+}
+
+func TestWidgetTypedFieldsBeatExtra(t *testing.T) {
+	var n Note
+	if err := json.Unmarshal([]byte(widgetFixtures[TypeNote]), &n); err != nil {
+		t.Fatal(err)
+	}
+	// Stale entries for modelled fields, as if copied from an older response
+	n.Extra["text"] = json.RawMessage(`"stale"`)
+	n.Extra["scale"] = json.RawMessage(`9`)
+	n.Extra["title"] = json.RawMessage(`"stale title"`)
+	n.Text = "Updated"
+	n.Title = ""
+	data, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	json.Unmarshal(data, &got)
+	if got["text"] != "Updated" || got["scale"] != 1.5 {
+		t.Errorf("got text %v scale %v, want the typed values", got["text"], got["scale"])
+	}
+	if _, ok := got["title"]; ok {
+		t.Errorf("a cleared typed field came back from Extra as %v", got["title"])
+	}
+	if got["text_scale"] != 0.75 {
+		t.Errorf("unknown field text_scale was lost: %v", got["text_scale"])
+	}
+}
+
+func TestWidgetOptionalNumbers(t *testing.T) {
+	// A zero depth or scale that is set is sent; unset ones are left out
+	var n Note
+	n.Depth = Float(0)
+	data, _ := json.Marshal(n)
+	if string(data) != `{"depth":0}` {
+		t.Errorf("got %s, want only depth 0", data)
+	}
+	if n.ScaleValue() != 1 || n.DepthValue() != 0 {
+		t.Errorf("got scale %v depth %v for unset fields, want 1 and 0", n.ScaleValue(), n.DepthValue())
+	}
+	n.Scale, n.Depth = Float(2), Float(-1)
+	if n.ScaleValue() != 2 || n.DepthValue() != -1 {
+		t.Errorf("got scale %v depth %v, want 2 and -1", n.ScaleValue(), n.DepthValue())
+	}
+}
+
+func TestWidgetDecode(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    interface{}
+	}{
+		{widgetFixtures[TypeNote], &Note{}},
+		{widgetFixtures[TypeAnchor], &Anchor{}},
+		{widgetFixtures[TypeImage], &Image{}},
+		{widgetFixtures[TypeConnector], &Connector{}},
+		{`{"id":"p1","widget_type":"Pdf","title":"doc.pdf"}`, &Widget{}},
+	}
+	for _, tt := range tests {
+		var w Widget
+		if err := json.Unmarshal([]byte(tt.fixture), &w); err != nil {
+			t.Fatal(err)
+		}
+		got, err := w.Decode()
+		if err != nil {
+			t.Fatalf("%s: %v", w.WidgetType, err)
+		}
+		if reflect.TypeOf(got) != reflect.TypeOf(tt.want) {
+			t.Errorf("%s decoded to %T, want %T", w.WidgetType, got, tt.want)
+			continue
+		}
+		// The typed value encodes back to the same widget
+		data, _ := json.Marshal(got)
+		var gotFields, wantFields map[string]interface{}
+		json.Unmarshal(data, &gotFields)
+		json.Unmarshal([]byte(tt.fixture), &wantFields)
+		if !reflect.DeepEqual(gotFields, wantFields) {
+			t.Errorf("%s: decoded widget encodes as %v, want %v", w.WidgetType, gotFields, wantFields)
+		}
+	}
+
+	if note, _ := (&Widget{WidgetBase: WidgetBase{ID: "n1", WidgetType: TypeNote}, Extra: map[string]json.RawMessage{"text": json.RawMessage(`"Hi"`)}}).Decode(); note.(*Note).Text != "Hi" {
+		t.Errorf("got %+v, want the text from Extra on the typed note", note)
+	}
+	bad := &Widget{WidgetBase: WidgetBase{ID: "n1", WidgetType: TypeNote}, Extra: map[string]json.RawMessage{"text": json.RawMessage(`42`)}}
+	if _, err := bad.Decode(); err == nil {
+		t.Error("got no error decoding a note whose text is a number")
+	}
+}
@@ -1,38 +1,231 @@
 package config
 
-import "sync"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/fsutil"
+)
+
+const (
+	DefaultConfigFile = "config.json" // Used when CONFIG_FILE is not set
+	DefaultKeyFile    = "config.key"  // Used when CONFIG_KEY_FILE is not set
+)
+
+// LLMConfig selects and configures the note extraction backend
+type LLMConfig struct {
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	APIKey   string `json:"apiKey,omitempty"`
+	BaseURL  string `json:"baseURL,omitempty"`
+	Mode     string `json:"mode,omitempty"` // "full" (default) or "hybrid"
+}
+
+// Note placement modes
+const (
+	PlacementAbsolute = "absolute" // top-level notes at absolute canvas coordinates
+	PlacementParent   = "parent"   // notes parented to the anchor, so they move with it
+)
+
+// MappingConfig holds defaults for preprocessing and mapping notes into anchors
+type MappingConfig struct {
+	AutoPerspective bool              `json:"autoPerspective"`
+	Placement       string            `json:"placement,omitempty"`
+	Palette         map[string]string `json:"palette,omitempty"` // note colors by name, as hex; empty for the Canvus defaults
+	Tiled           bool              `json:"tiled"`             // read large photos in overlapping tiles at full resolution
+}
+
+// TLSConfig controls how the MCS server certificate is verified
+type TLSConfig struct {
+	CAFile             string `json:"caFile,omitempty"`             // extra PEM CA bundle
+	Fingerprint        string `json:"fingerprint,omitempty"`        // pinned SHA-256 of the server certificate
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"` // disables verification; testing only
+}
 
 // Config holds application configuration
 type Config struct {
-	MCSServer string
-	APIKey    string
+	MCSServer       string        `json:"mcsServer,omitempty"`
+	APIKey          string        `json:"apiKey,omitempty"`
+	DefaultCanvasID string        `json:"defaultCanvasID,omitempty"`
+	DefaultAnchorID string        `json:"defaultAnchorID,omitempty"`
+	TLS             TLSConfig     `json:"tls"`
+	LLM             LLMConfig     `json:"llm"`
+	Mapping         MappingConfig `json:"mapping"`
 }
 
 var (
 	currentConfig = &Config{}
 	mu            sync.RWMutex
+
+	// Where SetConfig persists the config; empty until LoadConfig/Load is called
+	configPath string
+	keyPath    string
+	fileConfig = &Config{} // config as stored in the file, without env overrides
 )
 
 // GetConfig returns the current config
 func GetConfig() *Config {
 	mu.RLock()
 	defer mu.RUnlock()
-	return &Config{
-		MCSServer: currentConfig.MCSServer,
-		APIKey:    currentConfig.APIKey,
+	cfg := *currentConfig
+	return &cfg
+}
+
+// Path returns the file the config is saved to, or "" if none is loaded
+func Path() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return configPath
+}
+
+// SetConfig updates the current config and saves it to the config file, if one is loaded
+func SetConfig(cfg *Config) error {
+	mu.Lock()
+	defer mu.Unlock()
+	*currentConfig = *cfg
+	if configPath == "" {
+		return nil
+	}
+	stored := *cfg
+	stripEnv(&stored, fileConfig)
+	if err := save(configPath, keyPath, &stored); err != nil {
+		return err
+	}
+	fileConfig = &stored
+	return nil
+}
+
+// LoadConfig loads configuration from CONFIG_FILE (default config.json),
+// decrypting secrets with CONFIG_KEY_FILE (default config.key), then applies
+// environment overrides
+func LoadConfig() (*Config, error) {
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		path = DefaultConfigFile
 	}
+	key := os.Getenv("CONFIG_KEY_FILE")
+	if key == "" {
+		key = DefaultKeyFile
+	}
+	return Load(path, key)
 }
 
-// SetConfig updates the current config
-func SetConfig(cfg *Config) {
+// Load reads the config file at path (a missing file is fine), applies
+// environment overrides and makes the result the current config. Later
+// SetConfig calls save back to path.
+func Load(path, keyFile string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if err := decryptSecrets(keyFile, cfg); err != nil {
+			return nil, err
+		}
+		log.Printf("[config] Loaded configuration from %s", path)
+	case errors.Is(err, os.ErrNotExist):
+		log.Printf("[config] No config file at %s, using defaults", path)
+	default:
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	stored := *cfg
+	applyEnv(cfg)
+
 	mu.Lock()
 	defer mu.Unlock()
-	currentConfig.MCSServer = cfg.MCSServer
-	currentConfig.APIKey = cfg.APIKey
+	currentConfig = cfg
+	fileConfig = &stored
+	configPath = path
+	keyPath = keyFile
+	out := *cfg
+	return &out, nil
 }
 
-// LoadConfig loads configuration (stub)
-func LoadConfig() *Config {
-	// TODO: Load from env or file
-	return GetConfig()
+// envField ties a config field to the environment variable that overrides it
+type envField struct {
+	value *string
+	name  string
+}
+
+func envFields(cfg *Config) []envField {
+	return []envField{
+		{&cfg.MCSServer, "CANVUS_SERVER"},
+		{&cfg.APIKey, "CANVUS_API_KEY"},
+		{&cfg.DefaultCanvasID, "CANVAS_ID"},
+		{&cfg.TLS.CAFile, "CANVUS_CA_FILE"},
+		{&cfg.TLS.Fingerprint, "CANVUS_CERT_FINGERPRINT"},
+		{&cfg.LLM.Provider, "LLM_PROVIDER"},
+		{&cfg.LLM.Model, "LLM_MODEL"},
+		{&cfg.LLM.APIKey, "LLM_API_KEY"},
+		{&cfg.LLM.BaseURL, "LLM_BASE_URL"},
+		{&cfg.LLM.Mode, "LLM_MODE"},
+	}
+}
+
+// applyEnv overrides file settings with environment variables when set
+func applyEnv(cfg *Config) {
+	for _, f := range envFields(cfg) {
+		if v := os.Getenv(f.name); v != "" {
+			*f.value = v
+		}
+	}
+}
+
+// stripEnv restores the file's values for fields that still hold their
+// environment override, so saving never copies env settings into the file
+func stripEnv(cfg, file *Config) {
+	fileFields := envFields(file)
+	for i, f := range envFields(cfg) {
+		if v := os.Getenv(f.name); v != "" && *f.value == v {
+			*f.value = *fileFields[i].value
+		}
+	}
+}
+
+// decryptSecrets decrypts the API keys read from the config file
+func decryptSecrets(keyFile string, cfg *Config) error {
+	if cfg.APIKey == "" && cfg.LLM.APIKey == "" {
+		return nil
+	}
+	key, err := loadOrCreateKey(keyFile)
+	if err != nil {
+		return err
+	}
+	if cfg.APIKey, err = decryptSecret(key, cfg.APIKey); err != nil {
+		return fmt.Errorf("apiKey: %w", err)
+	}
+	if cfg.LLM.APIKey, err = decryptSecret(key, cfg.LLM.APIKey); err != nil {
+		return fmt.Errorf("llm.apiKey: %w", err)
+	}
+	return nil
+}
+
+// save writes cfg to path atomically with its API keys encrypted
+func save(path, keyFile string, cfg *Config) error {
+	key, err := loadOrCreateKey(keyFile)
+	if err != nil {
+		return err
+	}
+	out := *cfg
+	if out.APIKey, err = encryptSecret(key, cfg.APIKey); err != nil {
+		return err
+	}
+	if out.LLM.APIKey, err = encryptSecret(key, cfg.LLM.APIKey); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(&out, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFileAtomic(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	log.Printf("[config] Saved configuration to %s", path)
+	return nil
 }
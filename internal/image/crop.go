@@ -0,0 +1,59 @@
+package image
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"math"
+
+	"github.com/nfnt/resize"
+)
+
+const (
+	cropMargin       = 0.08 // border kept around a note crop, as a fraction of its size
+	maxCropDimension = 512  // crops are downsized to fit, which is plenty to read a note
+)
+
+// NoteCrop is one detected note cut out of the image and straightened
+type NoteCrop struct {
+	Note     DetectedNote
+	Data     []byte // JPEG
+	MimeType string
+}
+
+// DetectNoteCrops decodes an image, detects its notes and crops each one
+func DetectNoteCrops(data []byte, opts DetectOptions) ([]NoteCrop, error) {
+	img, _, _, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	return CropNotes(img, DetectNotes(img, opts))
+}
+
+// CropNotes cuts each note out of img with a small margin, rotated upright
+func CropNotes(img image.Image, notes []DetectedNote) ([]NoteCrop, error) {
+	crops := make([]NoteCrop, 0, len(notes))
+	for _, n := range notes {
+		cx, cy := float64(n.X)+float64(n.Width)/2, float64(n.Y)+float64(n.Height)/2
+		hw, hh := float64(n.Width)*(0.5+cropMargin), float64(n.Height)*(0.5+cropMargin)
+		rad := n.Angle * math.Pi / 180
+		cos, sin := math.Cos(rad), math.Sin(rad)
+		corner := func(u, v float64) Point {
+			return Point{X: cx + u*cos - v*sin, Y: cy + u*sin + v*cos}
+		}
+		quad := Quad{corner(-hw, -hh), corner(hw, -hh), corner(hw, hh), corner(-hw, hh)}
+		crop, _, err := Rectify(img, quad)
+		if err != nil {
+			return nil, err
+		}
+		if b := crop.Bounds(); b.Dx() > maxCropDimension || b.Dy() > maxCropDimension {
+			crop = resize.Thumbnail(maxCropDimension, maxCropDimension, crop, resize.Lanczos3)
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, crop, &jpeg.Options{Quality: Quality}); err != nil {
+			return nil, err
+		}
+		crops = append(crops, NoteCrop{Note: n, Data: buf.Bytes(), MimeType: "image/jpeg"})
+	}
+	return crops, nil
+}
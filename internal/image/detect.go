@@ -0,0 +1,377 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"sort"
+)
+
+// maxDetectGrid is the most samples per side DetectNotes looks at
+const maxDetectGrid = 800
+
+// DetectOptions tunes DetectNotes. Zero fields use the defaults.
+type DetectOptions struct {
+	MinSaturation float64 // HSV saturation (0-1) a note pixel needs; default 0.25
+	MinValue      float64 // HSV value (0-1) a note pixel needs; default 0.35
+	MinArea       float64 // smallest note, as a fraction of the image area; default 0.0005
+	MaxArea       float64 // largest note, as a fraction of the image area; default 0.25
+	MinFill       float64 // fraction of the fitted rectangle a note must cover; default 0.6
+	MaxAspect     float64 // longest over shortest side of the fitted rectangle; default 4
+	HueTolerance  float64 // degrees a pixel's hue may differ from its region's; default 25
+}
+
+func (o DetectOptions) withDefaults() DetectOptions {
+	def := func(v *float64, d float64) {
+		if *v <= 0 {
+			*v = d
+		}
+	}
+	def(&o.MinSaturation, 0.25)
+	def(&o.MinValue, 0.35)
+	def(&o.MinArea, 0.0005)
+	def(&o.MaxArea, 0.25)
+	def(&o.MinFill, 0.6)
+	def(&o.MaxAspect, 4)
+	def(&o.HueTolerance, 25)
+	return o
+}
+
+// DetectedNote is a note found by DetectNotes, in image pixel coordinates.
+// X, Y, Width and Height describe the note as if it were straightened:
+// the fitted rectangle rotated by -Angle about its centre.
+type DetectedNote struct {
+	X      int     `json:"x"`
+	Y      int     `json:"y"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+	Angle  float64 `json:"angle"` // rotation of the note in degrees, in (-45, 45]
+	Color  string  `json:"color"` // mean colour of the note, #RRGGBB
+	Fill   float64 `json:"fill"`  // fraction of the fitted rectangle covered by note colour
+}
+
+// DetectNotesData decodes an image and runs DetectNotes on it
+func DetectNotesData(data []byte, opts DetectOptions) ([]DetectedNote, error) {
+	img, _, _, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	return DetectNotes(img, opts), nil
+}
+
+// hsvCell is one sample of the detection grid
+type hsvCell struct {
+	h, s, v float64 // hue in degrees, saturation and value in 0-1
+	r, g, b uint8
+}
+
+// DetectNotes finds Post-it notes by their colour: saturated, bright pixels
+// are grouped into regions of similar hue, small gaps such as handwriting are
+// closed, and a minimum-area rectangle is fitted to each region. Regions that
+// are too small, too large, too elongated or too ragged to be a note are
+// dropped. Notes are returned in reading order. White or grey notes are not
+// found, as they cannot be told apart from the board by colour.
+func DetectNotes(img image.Image, opts DetectOptions) []DetectedNote {
+	opts = opts.withDefaults()
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w < 8 || h < 8 {
+		return nil
+	}
+	step := max(1, (max(w, h)+maxDetectGrid-1)/maxDetectGrid)
+	gw, gh := (w+step-1)/step, (h+step-1)/step
+
+	cells := make([]hsvCell, gw*gh)
+	mask := make([]bool, gw*gh)
+	for gy := 0; gy < gh; gy++ {
+		for gx := 0; gx < gw; gx++ {
+			r, g, bl, _ := img.At(b.Min.X+gx*step, b.Min.Y+gy*step).RGBA()
+			c := toHSV(uint8(r>>8), uint8(g>>8), uint8(bl>>8))
+			cells[gy*gw+gx] = c
+			mask[gy*gw+gx] = c.s >= opts.MinSaturation && c.v >= opts.MinValue
+		}
+	}
+	closed, hues := closeMask(mask, cells, gw, gh)
+
+	minCells := opts.MinArea * float64(w*h) / float64(step*step)
+	maxCells := opts.MaxArea * float64(w*h) / float64(step*step)
+	var notes []DetectedNote
+	for _, region := range hueRegions(closed, hues, gw, gh, opts.HueTolerance) {
+		if float64(len(region)) < minCells || float64(len(region)) > maxCells {
+			continue
+		}
+		rect := fitRectangle(region, gw)
+		fill := float64(len(region)) / (rect.width * rect.height)
+		aspect := max(rect.width, rect.height) / min(rect.width, rect.height)
+		if fill < opts.MinFill || aspect > opts.MaxAspect {
+			continue
+		}
+		// Average colour over the pixels that really are note coloured
+		var sr, sg, sb, n int
+		for _, i := range region {
+			if mask[i] {
+				sr += int(cells[i].r)
+				sg += int(cells[i].g)
+				sb += int(cells[i].b)
+				n++
+			}
+		}
+		if n == 0 {
+			continue
+		}
+		s := float64(step)
+		width, height := rect.width*s, rect.height*s
+		notes = append(notes, DetectedNote{
+			X:      b.Min.X + int(math.Round(rect.cx*s-width/2)),
+			Y:      b.Min.Y + int(math.Round(rect.cy*s-height/2)),
+			Width:  int(math.Round(width)),
+			Height: int(math.Round(height)),
+			Angle:  math.Round(rect.angle*10) / 10,
+			Color:  fmt.Sprintf("#%02X%02X%02X", sr/n, sg/n, sb/n),
+			Fill:   math.Round(fill*100) / 100,
+		})
+	}
+
+	// Reading order: rows of notes top to bottom, each left to right. A note
+	// whose centre is above the bottom of a row's first note is in that row.
+	sort.Slice(notes, func(i, j int) bool { return notes[i].Y < notes[j].Y })
+	rowStart := 0
+	for i := range notes {
+		first := notes[rowStart]
+		if notes[i].Y+notes[i].Height/2 > first.Y+first.Height {
+			sortRow(notes[rowStart:i])
+			rowStart = i
+		}
+	}
+	sortRow(notes[rowStart:])
+	log.Printf("[DetectNotes] Found %d notes in %dx%d image (grid step %d)", len(notes), w, h, step)
+	return notes
+}
+
+func sortRow(row []DetectedNote) {
+	sort.Slice(row, func(i, j int) bool { return row[i].X < row[j].X })
+}
+
+// toHSV converts an 8-bit RGB colour to hue, saturation and value
+func toHSV(r, g, b uint8) hsvCell {
+	c := hsvCell{r: r, g: g, b: b}
+	rf, gf, bf := float64(r)/255, float64(g)/255, float64(b)/255
+	mx, mn := max(rf, gf, bf), min(rf, gf, bf)
+	c.v = mx
+	if mx == 0 {
+		return c
+	}
+	d := mx - mn
+	c.s = d / mx
+	if d == 0 {
+		return c
+	}
+	switch mx {
+	case rf:
+		c.h = 60 * math.Mod((gf-bf)/d, 6)
+	case gf:
+		c.h = 60 * ((bf-rf)/d + 2)
+	default:
+		c.h = 60 * ((rf-gf)/d + 4)
+	}
+	if c.h < 0 {
+		c.h += 360
+	}
+	return c
+}
+
+// hueDistance is the angle between two hues in degrees
+func hueDistance(a, b float64) float64 {
+	d := math.Abs(a - b)
+	return math.Min(d, 360-d)
+}
+
+// closeMask fills gaps one cell wide (handwriting, glare) with a 3x3
+// morphological closing. Filled cells take the hue of a neighbouring note
+// cell; the returned hues are valid wherever the closed mask is set.
+func closeMask(mask []bool, cells []hsvCell, w, h int) ([]bool, []float64) {
+	hues := make([]float64, len(mask))
+	dilated := make([]bool, len(mask))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			if mask[i] {
+				dilated[i], hues[i] = true, cells[i].h
+				continue
+			}
+			for dy := -1; dy <= 1 && !dilated[i]; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx >= 0 && ny >= 0 && nx < w && ny < h && mask[ny*w+nx] {
+						dilated[i], hues[i] = true, cells[ny*w+nx].h
+						break
+					}
+				}
+			}
+		}
+	}
+	closed := make([]bool, len(mask))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			if mask[i] {
+				closed[i] = true
+				continue
+			}
+			if !dilated[i] {
+				continue
+			}
+			keep := true
+			for dy := -1; dy <= 1 && keep; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= w || ny >= h || !dilated[ny*w+nx] {
+						keep = false
+						break
+					}
+				}
+			}
+			closed[i] = keep
+		}
+	}
+	return closed, hues
+}
+
+// hueRegions returns the 4-connected regions of set cells whose hue stays
+// within tolerance of the region's mean hue, so touching notes of different
+// colours are kept apart
+func hueRegions(mask []bool, hues []float64, w, h int, tolerance float64) [][]int {
+	seen := make([]bool, len(mask))
+	var regions [][]int
+	stack := make([]int, 0, 1024)
+	for start := range mask {
+		if !mask[start] || seen[start] {
+			continue
+		}
+		var region []int
+		// Mean hue as a unit vector, so hues either side of 0° average correctly
+		var sumX, sumY float64
+		mean := hues[start]
+		stack = append(stack[:0], start)
+		seen[start] = true
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			region = append(region, i)
+			rad := hues[i] * math.Pi / 180
+			sumX += math.Cos(rad)
+			sumY += math.Sin(rad)
+			if len(region)%64 == 0 {
+				mean = math.Mod(math.Atan2(sumY, sumX)*180/math.Pi+360, 360)
+			}
+			x, y := i%w, i/w
+			for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if n[0] < 0 || n[1] < 0 || n[0] >= w || n[1] >= h {
+					continue
+				}
+				j := n[1]*w + n[0]
+				if mask[j] && !seen[j] && hueDistance(hues[j], mean) <= tolerance {
+					seen[j] = true
+					stack = append(stack, j)
+				}
+			}
+		}
+		regions = append(regions, region)
+	}
+	return regions
+}
+
+// rotatedRect is a rectangle in grid cells, rotated by angle degrees about its centre
+type rotatedRect struct {
+	cx, cy        float64
+	width, height float64
+	angle         float64
+}
+
+// fitRectangle returns the minimum-area rectangle enclosing a region, found
+// by trying each edge of the region's convex hull as a side (rotating calipers)
+func fitRectangle(region []int, w int) rotatedRect {
+	// The outline is enough: the corners of the first and last cell of each row
+	rows := map[int][2]int{}
+	for _, i := range region {
+		x, y := i%w, i/w
+		r, ok := rows[y]
+		if !ok {
+			r = [2]int{x, x}
+		}
+		rows[y] = [2]int{min(r[0], x), max(r[1], x)}
+	}
+	pts := make([]Point, 0, len(rows)*4)
+	for y, r := range rows {
+		fy := float64(y)
+		left, right := float64(r[0]), float64(r[1]+1)
+		pts = append(pts, Point{left, fy}, Point{left, fy + 1}, Point{right, fy}, Point{right, fy + 1})
+	}
+	hull := convexHull(pts)
+
+	best := rotatedRect{width: math.Inf(1), height: 1}
+	for i := range hull {
+		p, q := hull[i], hull[(i+1)%len(hull)]
+		theta := math.Atan2(q.Y-p.Y, q.X-p.X)
+		cos, sin := math.Cos(theta), math.Sin(theta)
+		minU, maxU := math.Inf(1), math.Inf(-1)
+		minV, maxV := math.Inf(1), math.Inf(-1)
+		for _, pt := range hull {
+			u := pt.X*cos + pt.Y*sin
+			v := -pt.X*sin + pt.Y*cos
+			minU, maxU = math.Min(minU, u), math.Max(maxU, u)
+			minV, maxV = math.Min(minV, v), math.Max(maxV, v)
+		}
+		width, height := maxU-minU, maxV-minV
+		if width*height >= best.width*best.height {
+			continue
+		}
+		cu, cv := (minU+maxU)/2, (minV+maxV)/2
+		best = rotatedRect{
+			cx:     cu*cos - cv*sin,
+			cy:     cu*sin + cv*cos,
+			width:  width,
+			height: height,
+			angle:  theta * 180 / math.Pi,
+		}
+	}
+	// Report the smallest rotation, swapping sides for quarter turns
+	for best.angle > 45 {
+		best.angle -= 90
+		best.width, best.height = best.height, best.width
+	}
+	for best.angle <= -45 {
+		best.angle += 90
+		best.width, best.height = best.height, best.width
+	}
+	return best
+}
+
+// convexHull returns the convex hull of pts in counter-clockwise order (monotone chain)
+func convexHull(pts []Point) []Point {
+	sort.Slice(pts, func(i, j int) bool {
+		if pts[i].X != pts[j].X {
+			return pts[i].X < pts[j].X
+		}
+		return pts[i].Y < pts[j].Y
+	})
+	cross := func(o, a, b Point) float64 {
+		return (a.X-o.X)*(b.Y-o.Y) - (a.Y-o.Y)*(b.X-o.X)
+	}
+	hull := make([]Point, 0, 2*len(pts))
+	for _, p := range pts {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(pts) - 2; i >= 0; i-- {
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], pts[i]) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, pts[i])
+	}
+	return hull[:len(hull)-1]
+}
@@ -0,0 +1,91 @@
+package image
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+// drawNote fills a w x h rectangle centred on cx, cy and rotated by angle degrees
+func drawNote(img *image.RGBA, cx, cy, w, h, angle float64, c color.RGBA) {
+	rad := angle * math.Pi / 180
+	cos, sin := math.Cos(rad), math.Sin(rad)
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			u, v := dx*cos+dy*sin, -dx*sin+dy*cos
+			if math.Abs(u) <= w/2 && math.Abs(v) <= h/2 {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
+
+func TestDetectNotes(t *testing.T) {
+	board := image.NewRGBA(image.Rect(0, 0, 800, 600))
+	draw.Draw(board, board.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
+
+	type note struct {
+		cx, cy, w, h, angle float64
+		color               color.RGBA
+		hex                 string
+	}
+	// Listed in reading order, drawn in another; the pink note sits higher than
+	// the yellow one to its left, but they share a row
+	want := []note{
+		{140, 150, 100, 100, 0, color.RGBA{255, 235, 60, 255}, "#FFEB3C"},
+		{400, 140, 120, 80, 15, color.RGBA{255, 105, 180, 255}, "#FF69B4"},
+		{650, 165, 100, 100, -20, color.RGBA{70, 160, 255, 255}, "#46A0FF"},
+		{200, 430, 100, 100, 30, color.RGBA{110, 220, 90, 255}, "#6EDC5A"},
+		{560, 420, 140, 100, 0, color.RGBA{255, 150, 40, 255}, "#FF9628"},
+	}
+	for _, n := range []note{want[4], want[0], want[2], want[1], want[3]} {
+		drawNote(board, n.cx, n.cy, n.w, n.h, n.angle, n.color)
+	}
+	// Handwriting on the last note is closed over and left out of its colour
+	for _, y := range []int{400, 420, 440} {
+		draw.Draw(board, image.Rect(510, y, 610, y+1), &image.Uniform{color.Black}, image.Point{}, draw.Src)
+	}
+	// Not notes: a grey card, a speck, a thin strip and an outline
+	drawNote(board, 400, 450, 100, 100, 0, color.RGBA{150, 150, 150, 255})
+	drawNote(board, 300, 300, 5, 5, 0, color.RGBA{255, 0, 0, 255})
+	drawNote(board, 400, 560, 400, 20, 0, color.RGBA{0, 200, 0, 255})
+	draw.Draw(board, image.Rect(700, 300, 780, 380), &image.Uniform{color.RGBA{200, 0, 200, 255}}, image.Point{}, draw.Src)
+	draw.Draw(board, image.Rect(705, 305, 775, 375), &image.Uniform{color.White}, image.Point{}, draw.Src)
+
+	got := DetectNotes(board, DetectOptions{})
+	if len(got) != len(want) {
+		t.Fatalf("got %d notes, want %d: %+v", len(got), len(want), got)
+	}
+	for i, n := range want {
+		g := got[i]
+		if g.Color != n.hex {
+			t.Errorf("note %d: got colour %s, want %s", i+1, g.Color, n.hex)
+		}
+		if math.Abs(g.Angle-n.angle) > 1.5 {
+			t.Errorf("note %d: got angle %v, want %v", i+1, g.Angle, n.angle)
+		}
+		wantX, wantY := n.cx-n.w/2, n.cy-n.h/2
+		if math.Abs(float64(g.X)-wantX) > 3 || math.Abs(float64(g.Y)-wantY) > 3 ||
+			math.Abs(float64(g.Width)-n.w) > 4 || math.Abs(float64(g.Height)-n.h) > 4 {
+			t.Errorf("note %d: got %dx%d at (%d,%d), want %vx%v at (%v,%v)", i+1, g.Width, g.Height, g.X, g.Y, n.w, n.h, wantX, wantY)
+		}
+		if g.Fill < 0.9 {
+			t.Errorf("note %d: got fill %v", i+1, g.Fill)
+		}
+	}
+}
+
+func TestDetectNotesBlank(t *testing.T) {
+	board := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	draw.Draw(board, board.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
+	if got := DetectNotes(board, DetectOptions{}); len(got) != 0 {
+		t.Errorf("got %d notes on a blank board", len(got))
+	}
+	if got := DetectNotes(image.NewRGBA(image.Rect(0, 0, 4, 4)), DetectOptions{}); got != nil {
+		t.Errorf("got %v for a tiny image", got)
+	}
+}
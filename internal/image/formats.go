@@ -0,0 +1,195 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+
+	_ "golang.org/x/image/webp" // registers WebP with image.Decode
+)
+
+// UnsupportedFormatError is returned when the upload is in a format that cannot be decoded
+type UnsupportedFormatError struct {
+	Format string // detected format, e.g. "heic", or "unknown"
+	Reason string
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	return fmt.Sprintf("unsupported image format %s: %s", e.Format, e.Reason)
+}
+
+// sniffFormat recognises formats that image.Decode cannot handle, so they can
+// be reported with a useful error
+func sniffFormat(data []byte) string {
+	// HEIF/HEIC/AVIF: ISO BMFF "ftyp" box with a HEIF brand
+	if len(data) >= 12 && string(data[4:8]) == "ftyp" {
+		switch string(data[8:12]) {
+		case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1":
+			return "heic"
+		case "avif", "avis":
+			return "avif"
+		}
+	}
+	return "unknown"
+}
+
+// decodeImage decodes the upload (JPEG, PNG or WebP) and applies its EXIF
+// orientation, so pixels are upright as the camera intended
+func decodeImage(data []byte) (image.Image, string, int, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		if err == image.ErrFormat {
+			switch f := sniffFormat(data); f {
+			case "heic", "avif":
+				return nil, "", 0, &UnsupportedFormatError{
+					Format: f,
+					Reason: "no pure-Go decoder is available; convert to JPEG (e.g. set the camera to \"Most Compatible\") and retry",
+				}
+			default:
+				return nil, "", 0, &UnsupportedFormatError{Format: f, Reason: "supported formats are JPEG, PNG and WebP"}
+			}
+		}
+		return nil, "", 0, err
+	}
+	orientation := exifOrientation(data)
+	return applyOrientation(img, orientation), format, orientation, nil
+}
+
+// exifOrientation returns the EXIF orientation (1-8) of a JPEG or WebP file, or 1 if absent
+func exifOrientation(data []byte) int {
+	var tiff []byte
+	switch {
+	case len(data) > 4 && data[0] == 0xFF && data[1] == 0xD8:
+		tiff = jpegExif(data)
+	case len(data) > 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		tiff = webpExif(data)
+	}
+	if o := tiffOrientation(tiff); o >= 1 && o <= 8 {
+		return o
+	}
+	return 1
+}
+
+// jpegExif returns the TIFF payload of the JPEG APP1 Exif segment
+func jpegExif(data []byte) []byte {
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return nil
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan / end of image
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil
+		}
+		segment := data[i+4 : end]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return segment[6:]
+		}
+		i = end
+	}
+	return nil
+}
+
+// webpExif returns the payload of the WebP "EXIF" chunk
+func webpExif(data []byte) []byte {
+	i := 12
+	for i+8 <= len(data) {
+		id := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4 : i+8]))
+		end := i + 8 + size
+		if size < 0 || end > len(data) {
+			return nil
+		}
+		if id == "EXIF" {
+			payload := data[i+8 : end]
+			// Some writers keep the JPEG-style prefix
+			if len(payload) > 6 && string(payload[:6]) == "Exif\x00\x00" {
+				payload = payload[6:]
+			}
+			return payload
+		}
+		i = end + size%2 // chunks are padded to even sizes
+	}
+	return nil
+}
+
+// tiffOrientation reads tag 0x0112 from IFD0 of a TIFF structure
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+	for n := 0; n < count; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8 : entry+10]))
+		}
+	}
+	return 0
+}
+
+// applyOrientation rotates/flips img according to the EXIF orientation
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	// Orientations 5-8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // mirrored along the top-left diagonal
+				dx, dy = y, x
+			case 6: // rotated 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // mirrored along the top-right diagonal
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90 counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			si := src.PixOffset(x, y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
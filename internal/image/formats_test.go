@@ -0,0 +1,247 @@
+package image
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The orientation fixtures are a 24x16 JPEG, white with a red 8x8 block in
+// the top-left corner, stored with EXIF orientations 1-8. Even orientations
+// use a little-endian TIFF header, odd ones big-endian.
+func TestDecodeImageOrientation(t *testing.T) {
+	tests := []struct {
+		orientation   int
+		width, height int
+		corner        string // where the red block ends up once upright
+	}{
+		{1, 24, 16, "top-left"},
+		{2, 24, 16, "top-right"},
+		{3, 24, 16, "bottom-right"},
+		{4, 24, 16, "bottom-left"},
+		{5, 16, 24, "top-left"},
+		{6, 16, 24, "top-right"},
+		{7, 16, 24, "bottom-right"},
+		{8, 16, 24, "bottom-left"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.orientation), func(t *testing.T) {
+			data := readFixture(t, fmt.Sprintf("orientation-%d.jpg", tt.orientation))
+			img, format, orientation, err := decodeImage(data)
+			if err != nil {
+				t.Fatalf("decodeImage: %v", err)
+			}
+			if format != "jpeg" || orientation != tt.orientation {
+				t.Errorf("got format %q orientation %d, want jpeg %d", format, orientation, tt.orientation)
+			}
+			b := img.Bounds()
+			if b.Dx() != tt.width || b.Dy() != tt.height {
+				t.Fatalf("got %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.width, tt.height)
+			}
+			corners := map[string][2]int{
+				"top-left":     {b.Min.X + 3, b.Min.Y + 3},
+				"top-right":    {b.Max.X - 4, b.Min.Y + 3},
+				"bottom-right": {b.Max.X - 4, b.Max.Y - 4},
+				"bottom-left":  {b.Min.X + 3, b.Max.Y - 4},
+			}
+			for name, p := range corners {
+				if got, want := isRed(img.At(p[0], p[1]).RGBA()), name == tt.corner; got != want {
+					t.Errorf("%s corner red = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeImageWebPExif(t *testing.T) {
+	// A 75x100 lossless WebP with a VP8X header and an EXIF chunk holding orientation 6
+	img, format, orientation, err := decodeImage(readFixture(t, "exif-orientation-6.webp"))
+	if err != nil {
+		t.Fatalf("decodeImage: %v", err)
+	}
+	if format != "webp" || orientation != 6 {
+		t.Errorf("got format %q orientation %d, want webp 6", format, orientation)
+	}
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 75 {
+		t.Errorf("got %dx%d, want 100x75 after rotation", b.Dx(), b.Dy())
+	}
+}
+
+func TestDecodeImageUnsupported(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+	}{
+		{"heic", append([]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic"), make([]byte, 16)...), "heic"},
+		{"heif mif1", append([]byte("\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00mif1heic"), make([]byte, 16)...), "heic"},
+		{"avif", append([]byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00avifmif1"), make([]byte, 16)...), "avif"},
+		{"unknown", []byte("not an image at all"), "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := decodeImage(tt.data)
+			var unsupported *UnsupportedFormatError
+			if !errors.As(err, &unsupported) {
+				t.Fatalf("got %v, want *UnsupportedFormatError", err)
+			}
+			if unsupported.Format != tt.format {
+				t.Errorf("got format %q, want %q", unsupported.Format, tt.format)
+			}
+		})
+	}
+}
+
+// tiffHeader builds a TIFF header whose IFD0 holds count entries at offset
+// ifd, with the orientation tag as the last one
+func tiffHeader(order binary.ByteOrder, ifd uint32, count uint16, orientation uint16) []byte {
+	b := make([]byte, 8)
+	if order == binary.LittleEndian {
+		copy(b, "II")
+	} else {
+		copy(b, "MM")
+	}
+	order.PutUint16(b[2:], 42)
+	order.PutUint32(b[4:], ifd)
+	for len(b) < int(ifd) {
+		b = append(b, 0)
+	}
+	b = append(b, 0, 0)
+	order.PutUint16(b[len(b)-2:], count)
+	for i := uint16(0); i < count; i++ {
+		entry := make([]byte, 12)
+		if i == count-1 {
+			order.PutUint16(entry, 0x0112)
+			order.PutUint16(entry[2:], 3)
+			order.PutUint32(entry[4:], 1)
+			order.PutUint16(entry[8:], orientation)
+		} else {
+			order.PutUint16(entry, 0x0100+i)
+		}
+		b = append(b, entry...)
+	}
+	return b
+}
+
+func TestTiffOrientation(t *testing.T) {
+	valid := tiffHeader(binary.BigEndian, 8, 3, 6)
+	tests := []struct {
+		name string
+		tiff []byte
+		want int
+	}{
+		{"big-endian", valid, 6},
+		{"little-endian", tiffHeader(binary.LittleEndian, 8, 1, 3), 3},
+		{"IFD after padding", tiffHeader(binary.LittleEndian, 20, 2, 8), 8},
+		{"nil", nil, 0},
+		{"too short", []byte("MM\x00*"), 0},
+		{"bad byte order", append([]byte("XX"), valid[2:]...), 0},
+		{"IFD offset past the end", tiffHeader(binary.BigEndian, 8, 1, 6)[:9], 0},
+		{"IFD offset huge", append([]byte("MM\x00*\xff\xff\xff\xff"), make([]byte, 16)...), 0},
+		{"entries truncated", valid[:len(valid)-5], 0},
+		{"entry count larger than the data", func() []byte {
+			b := append([]byte{}, valid...)
+			binary.BigEndian.PutUint16(b[8:], 0xffff)
+			return b
+		}(), 6},
+		{"no orientation tag", tiffHeader(binary.BigEndian, 8, 1, 6)[:10], 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tiffOrientation(tt.tiff); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJpegExif(t *testing.T) {
+	payload := tiffHeader(binary.BigEndian, 8, 1, 6)
+	segment := func(marker byte, length int, body []byte) []byte {
+		return append([]byte{0xFF, marker, byte(length >> 8), byte(length)}, body...)
+	}
+	exif := append([]byte("Exif\x00\x00"), payload...)
+	soi := []byte{0xFF, 0xD8}
+	join := func(parts ...[]byte) []byte {
+		var b []byte
+		for _, p := range parts {
+			b = append(b, p...)
+		}
+		return b
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{"APP1 first", join(soi, segment(0xE1, len(exif)+2, exif)), payload},
+		{"after APP0", join(soi, segment(0xE0, 6, []byte("JFIF")), segment(0xE1, len(exif)+2, exif)), payload},
+		{"XMP APP1 is skipped", join(soi, segment(0xE1, 7, []byte("http:")), segment(0xE1, len(exif)+2, exif)), payload},
+		{"no EXIF before start of scan", join(soi, segment(0xDA, 4, []byte{0, 0}), segment(0xE1, len(exif)+2, exif)), nil},
+		{"length past the end", join(soi, segment(0xE1, len(exif)+20, exif)), nil},
+		{"length too small", join(soi, segment(0xE1, 1, exif)), nil},
+		{"not a marker", join(soi, []byte{0x00, 0xE1, 0x00, 0x10}), nil},
+		{"truncated header", join(soi, []byte{0xFF, 0xE1, 0x00}), nil},
+		{"only SOI", soi, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegExif(tt.data); string(got) != string(tt.want) {
+				t.Errorf("got %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebpExif(t *testing.T) {
+	payload := tiffHeader(binary.LittleEndian, 8, 1, 6)
+	chunk := func(id string, size uint32, body []byte) []byte {
+		b := append([]byte(id), 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(b[4:], size)
+		return append(b, body...)
+	}
+	riff := func(chunks ...[]byte) []byte {
+		b := []byte("RIFF\x00\x00\x00\x00WEBP")
+		for _, c := range chunks {
+			b = append(b, c...)
+		}
+		return b
+	}
+	odd := []byte{1, 2, 3}
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{"EXIF chunk", riff(chunk("VP8X", 10, make([]byte, 10)), chunk("EXIF", uint32(len(payload)), payload)), payload},
+		{"after an odd-sized chunk", riff(chunk("ICCP", 3, append(odd, 0)), chunk("EXIF", uint32(len(payload)), payload)), payload},
+		{"JPEG-style prefix", riff(chunk("EXIF", uint32(len(payload)+6), append([]byte("Exif\x00\x00"), payload...))), payload},
+		{"no EXIF chunk", riff(chunk("VP8X", 10, make([]byte, 10))), nil},
+		{"size past the end", riff(chunk("EXIF", uint32(len(payload)+1), payload)), nil},
+		{"size huge", riff(chunk("VP8X", 0xffffffff, make([]byte, 10))), nil},
+		{"truncated chunk header", riff([]byte("EXI")), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := webpExif(tt.data); string(got) != string(tt.want) {
+				t.Errorf("got %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func isRed(r, g, b, _ uint32) bool {
+	return r > 0xc000 && g < 0x4000 && b < 0x4000
+}
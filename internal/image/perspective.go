@@ -0,0 +1,366 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"math"
+	"sort"
+)
+
+// Point is a position in image pixel coordinates
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Quad holds four corners ordered top-left, top-right, bottom-right, bottom-left
+type Quad [4]Point
+
+// Homography is a 3x3 projective transform in row-major order
+type Homography [9]float64
+
+// Apply maps a point through the homography
+func (h Homography) Apply(p Point) Point {
+	w := h[6]*p.X + h[7]*p.Y + h[8]
+	if w == 0 {
+		return Point{}
+	}
+	return Point{
+		X: (h[0]*p.X + h[1]*p.Y + h[2]) / w,
+		Y: (h[3]*p.X + h[4]*p.Y + h[5]) / w,
+	}
+}
+
+// Inverse returns the inverse transform
+func (h Homography) Inverse() (Homography, error) {
+	det := h[0]*(h[4]*h[8]-h[5]*h[7]) - h[1]*(h[3]*h[8]-h[5]*h[6]) + h[2]*(h[3]*h[7]-h[4]*h[6])
+	if math.Abs(det) < 1e-12 {
+		return Homography{}, errors.New("homography is singular")
+	}
+	inv := Homography{
+		h[4]*h[8] - h[5]*h[7], h[2]*h[7] - h[1]*h[8], h[1]*h[5] - h[2]*h[4],
+		h[5]*h[6] - h[3]*h[8], h[0]*h[8] - h[2]*h[6], h[2]*h[3] - h[0]*h[5],
+		h[3]*h[7] - h[4]*h[6], h[1]*h[6] - h[0]*h[7], h[0]*h[4] - h[1]*h[3],
+	}
+	for i := range inv {
+		inv[i] /= det
+	}
+	return inv, nil
+}
+
+// ComputeHomography returns the transform mapping each src corner to the matching dst corner
+func ComputeHomography(src, dst Quad) (Homography, error) {
+	// Solve the 8x8 system A·h = b for h[0..7], with h[8] = 1
+	var a [8][9]float64
+	for i := 0; i < 4; i++ {
+		x, y := src[i].X, src[i].Y
+		u, v := dst[i].X, dst[i].Y
+		a[2*i] = [9]float64{x, y, 1, 0, 0, 0, -u * x, -u * y, u}
+		a[2*i+1] = [9]float64{0, 0, 0, x, y, 1, -v * x, -v * y, v}
+	}
+	// Gaussian elimination with partial pivoting
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return Homography{}, errors.New("degenerate corner points")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			f := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+	var h Homography
+	for i := 0; i < 8; i++ {
+		h[i] = a[i][8] / a[i][i]
+	}
+	h[8] = 1
+	return h, nil
+}
+
+// OrderQuad sorts four arbitrary corner points into top-left, top-right,
+// bottom-right, bottom-left order
+func OrderQuad(pts [4]Point) Quad {
+	var cx, cy float64
+	for _, p := range pts {
+		cx += p.X / 4
+		cy += p.Y / 4
+	}
+	sorted := pts
+	sort.Slice(sorted[:], func(i, j int) bool {
+		return math.Atan2(sorted[i].Y-cy, sorted[i].X-cx) < math.Atan2(sorted[j].Y-cy, sorted[j].X-cx)
+	})
+	// Angles run clockwise in image coordinates starting from the left; find top-left
+	start := 0
+	for i := range sorted {
+		if sorted[i].X+sorted[i].Y < sorted[start].X+sorted[start].Y {
+			start = i
+		}
+	}
+	var q Quad
+	for i := 0; i < 4; i++ {
+		q[i] = sorted[(start+i)%4]
+	}
+	return q
+}
+
+// PerspectiveTransform records how a photo was rectified
+type PerspectiveTransform struct {
+	Corners Quad       `json:"corners"` // board corners in the original photo
+	Matrix  Homography `json:"matrix"`  // original photo pixels -> rectified pixels
+	Width   int        `json:"width"`   // rectified width
+	Height  int        `json:"height"`  // rectified height
+}
+
+// maxRectifyScale bounds the rectified image relative to the source, which
+// corners far outside the image would otherwise make arbitrarily large
+const maxRectifyScale = 2
+
+// cornerTolerance is how far outside the image, as a fraction of its size,
+// user-supplied corners may lie
+const cornerTolerance = 0.05
+
+// CheckCorners reports an error if a corner lies outside a width x height
+// image, beyond a small tolerance
+func CheckCorners(q Quad, width, height int) error {
+	tx, ty := cornerTolerance*float64(width), cornerTolerance*float64(height)
+	for _, p := range q {
+		if math.IsNaN(p.X) || math.IsNaN(p.Y) || p.X < -tx || p.Y < -ty || p.X > float64(width)+tx || p.Y > float64(height)+ty {
+			return fmt.Errorf("corner (%g,%g) is outside the %dx%d image", p.X, p.Y, width, height)
+		}
+	}
+	return nil
+}
+
+// Rectify warps the quadrilateral region of img to an upright rectangle whose
+// size matches the average lengths of the quad's opposite edges, capped at
+// maxRectifyScale times the size of img
+func Rectify(img image.Image, corners Quad) (image.Image, *PerspectiveTransform, error) {
+	dist := func(a, b Point) float64 { return math.Hypot(a.X-b.X, a.Y-b.Y) }
+	w := (dist(corners[0], corners[1]) + dist(corners[3], corners[2])) / 2
+	h := (dist(corners[0], corners[3]) + dist(corners[1], corners[2])) / 2
+	b := img.Bounds()
+	if limit := maxRectifyScale * float64(max(b.Dx(), b.Dy())); max(w, h) > limit {
+		w, h = w*limit/max(w, h), h*limit/max(w, h)
+	}
+	width, height := int(math.Round(w)), int(math.Round(h))
+	if width < 2 || height < 2 || math.IsNaN(w) || math.IsNaN(h) {
+		return nil, nil, errors.New("corner points enclose no area")
+	}
+	dst := Quad{{0, 0}, {float64(width), 0}, {float64(width), float64(height)}, {0, float64(height)}}
+	forward, err := ComputeHomography(corners, dst)
+	if err != nil {
+		return nil, nil, err
+	}
+	inverse, err := forward.Inverse()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			src := inverse.Apply(Point{X: float64(x) + 0.5, Y: float64(y) + 0.5})
+			out.SetRGBA(x, y, sampleBilinear(img, src.X-0.5, src.Y-0.5))
+		}
+	}
+	log.Printf("[Rectify] Warped quad %v to %dx%d", corners, width, height)
+	return out, &PerspectiveTransform{Corners: corners, Matrix: forward, Width: width, Height: height}, nil
+}
+
+// sampleBilinear returns the bilinearly interpolated colour at (x, y), clamped to the image bounds
+func sampleBilinear(img image.Image, x, y float64) color.RGBA {
+	b := img.Bounds()
+	clamp := func(v, lo, hi int) int {
+		if v < lo {
+			return lo
+		}
+		if v > hi {
+			return hi
+		}
+		return v
+	}
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+	px := func(xx, yy int) (float64, float64, float64, float64) {
+		r, g, bl, a := img.At(clamp(b.Min.X+xx, b.Min.X, b.Max.X-1), clamp(b.Min.Y+yy, b.Min.Y, b.Max.Y-1)).RGBA()
+		return float64(r), float64(g), float64(bl), float64(a)
+	}
+	r00, g00, b00, a00 := px(x0, y0)
+	r10, g10, b10, a10 := px(x0+1, y0)
+	r01, g01, b01, a01 := px(x0, y0+1)
+	r11, g11, b11, a11 := px(x0+1, y0+1)
+	lerp := func(v00, v10, v01, v11 float64) uint8 {
+		top := v00 + (v10-v00)*fx
+		bottom := v01 + (v11-v01)*fx
+		return uint8((top + (bottom-top)*fy) / 257)
+	}
+	return color.RGBA{
+		R: lerp(r00, r10, r01, r11),
+		G: lerp(g00, g10, g01, g11),
+		B: lerp(b00, b10, b01, b11),
+		A: lerp(a00, a10, a01, a11),
+	}
+}
+
+// DetectBoard looks for a large bright, low-saturation region (a whiteboard)
+// and returns its corners. ok is false if no plausible board was found or the
+// board already fills the frame.
+func DetectBoard(img image.Image) (Quad, bool) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w < 16 || h < 16 {
+		return Quad{}, false
+	}
+	// Work on a grid of at most ~400 samples per side
+	step := max(1, max(w, h)/400)
+	gw, gh := (w+step-1)/step, (h+step-1)/step
+
+	lum := make([]uint8, gw*gh)
+	sat := make([]uint8, gw*gh)
+	var hist [256]int
+	for gy := 0; gy < gh; gy++ {
+		for gx := 0; gx < gw; gx++ {
+			r, g, bl, _ := img.At(b.Min.X+gx*step, b.Min.Y+gy*step).RGBA()
+			r8, g8, b8 := r>>8, g>>8, bl>>8
+			l := uint8((299*r8 + 587*g8 + 114*b8) / 1000)
+			mx, mn := max(r8, g8, b8), min(r8, g8, b8)
+			var s uint8
+			if mx > 0 {
+				s = uint8((mx - mn) * 255 / mx)
+			}
+			lum[gy*gw+gx] = l
+			sat[gy*gw+gx] = s
+			hist[l]++
+		}
+	}
+	threshold := otsuThreshold(hist[:], gw*gh)
+
+	// Board pixels: bright and unsaturated. Saturated pixels (notes on the board)
+	// are included too so they do not punch holes in the region.
+	mask := make([]bool, gw*gh)
+	for i := range mask {
+		mask[i] = lum[i] > threshold || sat[i] > 90
+	}
+	component := largestComponent(mask, gw, gh)
+	if len(component) == 0 {
+		return Quad{}, false
+	}
+
+	// The extreme points along the diagonals approximate the corners
+	tl, tr, br, bl := component[0], component[0], component[0], component[0]
+	for _, i := range component {
+		x, y := i%gw, i/gw
+		if x+y < tl%gw+tl/gw {
+			tl = i
+		}
+		if x+y > br%gw+br/gw {
+			br = i
+		}
+		if x-y > tr%gw-tr/gw {
+			tr = i
+		}
+		if x-y < bl%gw-bl/gw {
+			bl = i
+		}
+	}
+	toPoint := func(i int) Point {
+		return Point{X: float64(b.Min.X + (i%gw)*step), Y: float64(b.Min.Y + (i/gw)*step)}
+	}
+	quad := Quad{toPoint(tl), toPoint(tr), toPoint(br), toPoint(bl)}
+
+	coverage := quadArea(quad) / float64(w*h)
+	log.Printf("[DetectBoard] Candidate quad %v covers %.0f%% of the image (threshold %d)", quad, coverage*100, threshold)
+	if coverage < 0.2 || coverage > 0.95 {
+		return Quad{}, false
+	}
+	return quad, true
+}
+
+// otsuThreshold returns the luminance threshold that best separates the histogram into two classes
+func otsuThreshold(hist []int, total int) uint8 {
+	var sum float64
+	for i, c := range hist {
+		sum += float64(i * c)
+	}
+	var sumB, best float64
+	var wB int
+	threshold := 127
+	for t, c := range hist {
+		wB += c
+		if wB == 0 {
+			continue
+		}
+		wF := total - wB
+		if wF == 0 {
+			break
+		}
+		sumB += float64(t * c)
+		mB := sumB / float64(wB)
+		mF := (sum - sumB) / float64(wF)
+		between := float64(wB) * float64(wF) * (mB - mF) * (mB - mF)
+		if between > best {
+			best = between
+			threshold = t
+		}
+	}
+	return uint8(threshold)
+}
+
+// largestComponent returns the indices of the largest 4-connected region of true cells
+func largestComponent(mask []bool, w, h int) []int {
+	seen := make([]bool, len(mask))
+	var best []int
+	stack := make([]int, 0, 1024)
+	for start := range mask {
+		if !mask[start] || seen[start] {
+			continue
+		}
+		var comp []int
+		stack = append(stack[:0], start)
+		seen[start] = true
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			comp = append(comp, i)
+			x, y := i%w, i/w
+			for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if n[0] < 0 || n[1] < 0 || n[0] >= w || n[1] >= h {
+					continue
+				}
+				j := n[1]*w + n[0]
+				if mask[j] && !seen[j] {
+					seen[j] = true
+					stack = append(stack, j)
+				}
+			}
+		}
+		if len(comp) > len(best) {
+			best = comp
+		}
+	}
+	return best
+}
+
+// quadArea returns the area of the quad using the shoelace formula
+func quadArea(q Quad) float64 {
+	var area float64
+	for i := 0; i < 4; i++ {
+		j := (i + 1) % 4
+		area += q[i].X*q[j].Y - q[j].X*q[i].Y
+	}
+	return math.Abs(area) / 2
+}
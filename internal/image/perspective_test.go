@@ -0,0 +1,129 @@
+package image
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func near(a, b Point) bool {
+	return math.Abs(a.X-b.X) < 1e-6 && math.Abs(a.Y-b.Y) < 1e-6
+}
+
+func TestComputeHomography(t *testing.T) {
+	unit := Quad{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	quad := Quad{{120, 80}, {610, 40}, {700, 450}, {90, 520}}
+	h, err := ComputeHomography(unit, quad)
+	if err != nil {
+		t.Fatalf("ComputeHomography: %v", err)
+	}
+	for i := range unit {
+		if got := h.Apply(unit[i]); !near(got, quad[i]) {
+			t.Errorf("corner %d maps to %v, want %v", i, got, quad[i])
+		}
+	}
+	inv, err := h.Inverse()
+	if err != nil {
+		t.Fatalf("Inverse: %v", err)
+	}
+	for _, p := range []Point{{0, 0}, {0.5, 0.5}, {0.25, 0.9}, {1, 0.3}} {
+		if got := inv.Apply(h.Apply(p)); !near(got, p) {
+			t.Errorf("%v maps back to %v", p, got)
+		}
+	}
+	// The transform the other way is the inverse
+	back, err := ComputeHomography(quad, unit)
+	if err != nil {
+		t.Fatalf("ComputeHomography: %v", err)
+	}
+	for _, p := range []Point{{300, 200}, {500, 400}} {
+		if got, want := back.Apply(p), inv.Apply(p); !near(got, want) {
+			t.Errorf("%v maps to %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestComputeHomographyDegenerate(t *testing.T) {
+	unit := Quad{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	for name, q := range map[string]Quad{
+		"collinear":        {{0, 0}, {1, 1}, {2, 2}, {3, 3}},
+		"repeated corner":  {{0, 0}, {10, 0}, {10, 0}, {0, 10}},
+		"all in one point": {{5, 5}, {5, 5}, {5, 5}, {5, 5}},
+	} {
+		if _, err := ComputeHomography(q, unit); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
+
+func TestOrderQuad(t *testing.T) {
+	want := Quad{{100, 60}, {520, 30}, {560, 400}, {40, 380}}
+	// Every order of the four corners sorts back to the same quad
+	perms := [][4]int{}
+	var permute func(p []int, k int)
+	permute = func(p []int, k int) {
+		if k == len(p) {
+			perms = append(perms, [4]int{p[0], p[1], p[2], p[3]})
+			return
+		}
+		for i := k; i < len(p); i++ {
+			p[k], p[i] = p[i], p[k]
+			permute(p, k+1)
+			p[k], p[i] = p[i], p[k]
+		}
+	}
+	permute([]int{0, 1, 2, 3}, 0)
+	for _, p := range perms {
+		pts := [4]Point{want[p[0]], want[p[1]], want[p[2]], want[p[3]]}
+		if got := OrderQuad(pts); got != want {
+			t.Errorf("OrderQuad(%v) = %v, want %v", pts, got, want)
+		}
+	}
+}
+
+func TestRectify(t *testing.T) {
+	// Left half red, right half blue
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	draw.Draw(img, image.Rect(0, 0, 100, 100), &image.Uniform{color.RGBA{255, 0, 0, 255}}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(100, 0, 200, 100), &image.Uniform{color.RGBA{0, 0, 255, 255}}, image.Point{}, draw.Src)
+
+	// A quad straddling the halves, narrower at the top
+	corners := Quad{{60, 10}, {140, 10}, {180, 90}, {20, 90}}
+	out, transform, err := Rectify(img, corners)
+	if err != nil {
+		t.Fatalf("Rectify: %v", err)
+	}
+	if transform.Width != 120 || transform.Height != 89 || out.Bounds().Dx() != 120 || out.Bounds().Dy() != 89 {
+		t.Errorf("got %dx%d, want 120x89", transform.Width, transform.Height)
+	}
+	for i, want := range []Point{{0, 0}, {120, 0}, {120, 89}, {0, 89}} {
+		if got := transform.Matrix.Apply(corners[i]); !near(got, want) {
+			t.Errorf("corner %d maps to %v, want %v", i, got, want)
+		}
+	}
+	if r, _, b, _ := out.At(10, 44).RGBA(); r>>8 != 255 || b != 0 {
+		t.Errorf("left of the rectified image is not red")
+	}
+	if r, _, b, _ := out.At(110, 44).RGBA(); r != 0 || b>>8 != 255 {
+		t.Errorf("right of the rectified image is not blue")
+	}
+}
+
+func TestRectifySizeCap(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	// Corners far outside the image would make a 1000x100 image; it is capped
+	// at twice the image's longest side, keeping the aspect ratio
+	_, transform, err := Rectify(img, Quad{{0, 0}, {1000, 0}, {1000, 100}, {0, 100}})
+	if err != nil {
+		t.Fatalf("Rectify: %v", err)
+	}
+	if transform.Width != 200 || transform.Height != 20 {
+		t.Errorf("got %dx%d, want 200x20", transform.Width, transform.Height)
+	}
+
+	if _, _, err := Rectify(img, Quad{{10, 10}, {90, 10}, {90, 10}, {10, 10}}); err == nil {
+		t.Error("got no error for a quad with no area")
+	}
+}
@@ -17,21 +17,76 @@ const (
 	Quality      = 85               // JPEG quality (0-100)
 )
 
+// ProcessedImage is the result of ProcessImage
+type ProcessedImage struct {
+	Data           []byte  // encoded image bytes
+	MimeType       string  // MIME type of Data
+	Width          int     // final width in pixels
+	Height         int     // final height in pixels
+	OriginalWidth  int     // width of the uploaded image
+	OriginalHeight int     // height of the uploaded image
+	ResizeRatio    float64 // Width / width before resizing (1 when not resized)
+
+	// Perspective is set when the board was rectified before resizing
+	Perspective *PerspectiveTransform
+
+	// Full is the rectified image before resizing, kept with Options.KeepFull
+	// when it was downsized, for tiled extraction
+	Full image.Image
+}
+
+// Options controls optional preprocessing steps
+type Options struct {
+	// Corners are user-supplied board corners in original image pixels
+	Corners *Quad
+	// AutoPerspective detects the board and rectifies it when Corners is nil
+	AutoPerspective bool
+	// KeepFull keeps the full-resolution image when it is downsized
+	KeepFull bool
+}
+
 // ProcessImage takes raw image bytes and returns optimized image bytes
 // that are within size limits while maintaining quality
-func ProcessImage(input []byte) ([]byte, string, error) {
-	// First try to decode the image
-	img, format, err := image.Decode(bytes.NewReader(input))
+func ProcessImage(input []byte) (*ProcessedImage, error) {
+	return ProcessImageWithOptions(input, Options{})
+}
+
+// ProcessImageWithOptions is ProcessImage with optional perspective correction
+func ProcessImageWithOptions(input []byte, opts Options) (*ProcessedImage, error) {
+	// First try to decode the image (EXIF orientation is applied here)
+	img, format, orientation, err := decodeImage(input)
 	if err != nil {
 		log.Printf("[ProcessImage] Failed to decode image: %v", err)
-		return nil, "", err
+		return nil, err
 	}
 
-	// Get original dimensions
+	// Get original dimensions (after orientation, i.e. as the photo is displayed)
 	bounds := img.Bounds()
-	width := bounds.Dx()
-	height := bounds.Dy()
-	log.Printf("[ProcessImage] Original image: %dx%d, format: %s", width, height, format)
+	originalWidth, originalHeight := bounds.Dx(), bounds.Dy()
+	log.Printf("[ProcessImage] Original image: %dx%d, format: %s, EXIF orientation: %d", originalWidth, originalHeight, format, orientation)
+
+	// Rectify the board before resizing, so the warp uses full resolution
+	var perspective *PerspectiveTransform
+	corners := opts.Corners
+	if corners == nil && opts.AutoPerspective {
+		if q, ok := DetectBoard(img); ok {
+			corners = &q
+		} else {
+			log.Printf("[ProcessImage] No board detected, skipping perspective correction")
+		}
+	}
+	if corners != nil {
+		if err := CheckCorners(*corners, originalWidth, originalHeight); err != nil {
+			log.Printf("[ProcessImage] Invalid corners: %v", err)
+			return nil, err
+		}
+		img, perspective, err = Rectify(img, OrderQuad(*corners))
+		if err != nil {
+			log.Printf("[ProcessImage] Perspective correction failed: %v", err)
+			return nil, err
+		}
+	}
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
 
 	// Calculate new dimensions if needed
 	newWidth, newHeight := width, height
@@ -47,14 +102,20 @@ func ProcessImage(input []byte) ([]byte, string, error) {
 	}
 
 	// Resize if needed
+	var full image.Image
 	if newWidth != width || newHeight != height {
+		if opts.KeepFull {
+			full = img
+		}
 		img = resize.Resize(uint(newWidth), uint(newHeight), img, resize.Lanczos3)
 	}
 
 	// Encode with appropriate format and quality
 	var output bytes.Buffer
 	var mimeType string
-	if strings.EqualFold(format, "jpeg") || strings.EqualFold(format, "jpg") {
+	// Photos (JPEG, WebP) are re-encoded as JPEG; everything else as PNG
+	lossy := strings.EqualFold(format, "jpeg") || strings.EqualFold(format, "jpg") || strings.EqualFold(format, "webp")
+	if lossy {
 		err = jpeg.Encode(&output, img, &jpeg.Options{Quality: Quality})
 		mimeType = "image/jpeg"
 		log.Printf("[ProcessImage] Set MIME type to: %s", mimeType)
@@ -66,7 +127,7 @@ func ProcessImage(input []byte) ([]byte, string, error) {
 	}
 	if err != nil {
 		log.Printf("[ProcessImage] Failed to encode image: %v", err)
-		return nil, "", err
+		return nil, err
 	}
 
 	// Check final size
@@ -74,7 +135,7 @@ func ProcessImage(input []byte) ([]byte, string, error) {
 	log.Printf("[ProcessImage] Final image size: %d bytes", len(outputBytes))
 
 	// If still too large, reduce quality further
-	if len(outputBytes) > MaxImageSize && strings.EqualFold(format, "jpeg") {
+	if len(outputBytes) > MaxImageSize && lossy {
 		log.Printf("[ProcessImage] Image still too large, reducing quality further")
 		quality := Quality
 		for len(outputBytes) > MaxImageSize && quality > 10 {
@@ -83,13 +144,27 @@ func ProcessImage(input []byte) ([]byte, string, error) {
 			err = jpeg.Encode(&output, img, &jpeg.Options{Quality: quality})
 			if err != nil {
 				log.Printf("[ProcessImage] Failed to encode with reduced quality: %v", err)
-				return nil, "", err
+				return nil, err
 			}
 			outputBytes = output.Bytes()
 			log.Printf("[ProcessImage] Reduced quality to %d, new size: %d bytes", quality, len(outputBytes))
 		}
 	}
 
-	log.Printf("[ProcessImage] Returning MIME type: %s", mimeType)
-	return outputBytes, mimeType, nil
+	ratio := 1.0
+	if width > 0 {
+		ratio = float64(newWidth) / float64(width)
+	}
+	log.Printf("[ProcessImage] Returning MIME type: %s, %dx%d (ratio %.4f)", mimeType, newWidth, newHeight, ratio)
+	return &ProcessedImage{
+		Data:           outputBytes,
+		MimeType:       mimeType,
+		Width:          newWidth,
+		Height:         newHeight,
+		OriginalWidth:  originalWidth,
+		OriginalHeight: originalHeight,
+		ResizeRatio:    ratio,
+		Perspective:    perspective,
+		Full:           full,
+	}, nil
 }
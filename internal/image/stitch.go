@@ -0,0 +1,283 @@
+package image
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"log"
+	"math"
+
+	"github.com/nfnt/resize"
+)
+
+const (
+	// DefaultStitchOverlap is the overlap between neighbouring photos when none is declared
+	DefaultStitchOverlap = 0.3
+	// minAlignScore is the lowest correlation accepted as an alignment;
+	// below it a photo is placed by the declared overlap instead
+	minAlignScore = 0.5
+	alignSearch   = 0.25 // how far from the declared overlap to search, as a fraction of the photo
+	alignDrift    = 0.2  // how far photos may drift across the direction of travel
+	minAlignArea  = 0.05 // smallest shared area tried, as a fraction of a photo
+	// minAlignContrast is the standard deviation, in grey levels out of
+	// 0xffff, below which a shared area is blank and cannot be aligned
+	minAlignContrast = 0.002 * 0xffff
+)
+
+// alignLevels are the longest sides, in pixels, at which photos are
+// compared: a full search at the first, then a refinement at each next one
+var alignLevels = []int{128, 512, MaxDimension}
+
+// StitchOptions controls StitchImages
+type StitchOptions struct {
+	Overlap  float64 // declared fraction of each photo shared with the next; default DefaultStitchOverlap
+	Vertical bool    // photos run top to bottom instead of left to right
+	Offsets  []Point // top-left of each photo relative to the first, in photo pixels; skips alignment
+}
+
+// StitchedImage is the result of StitchImages. The embedded image is the
+// photos composited into one frame and downsized; its OriginalWidth and
+// OriginalHeight are the size of the frame.
+type StitchedImage struct {
+	ProcessedImage
+	Photos    []Tile // each photo, downsized alike, placed in the frame
+	Unaligned []int  // photos placed by the declared overlap because alignment failed
+}
+
+// StitchImages places an ordered set of overlapping photos of a board in
+// one frame. Neighbouring photos are aligned by correlating their overlap,
+// searching around the declared overlap, unless offsets are given.
+func StitchImages(inputs [][]byte, opts StitchOptions) (*StitchedImage, error) {
+	if len(inputs) < 2 {
+		return nil, errors.New("stitching needs at least two photos")
+	}
+	if opts.Offsets != nil && len(opts.Offsets) != len(inputs) {
+		return nil, fmt.Errorf("got %d offsets for %d photos", len(opts.Offsets), len(inputs))
+	}
+	if opts.Overlap <= 0 || opts.Overlap >= 1 {
+		opts.Overlap = DefaultStitchOverlap
+	}
+
+	// Downsize every photo by the same ratio, so they keep a common scale
+	imgs := make([]image.Image, len(inputs))
+	ratio := 1.0
+	for i, data := range inputs {
+		img, format, _, err := decodeImage(data)
+		if err != nil {
+			return nil, fmt.Errorf("photo %d: %w", i+1, err)
+		}
+		b := img.Bounds()
+		log.Printf("[StitchImages] Photo %d: %dx%d, format: %s", i+1, b.Dx(), b.Dy(), format)
+		if longest := max(b.Dx(), b.Dy()); longest > MaxDimension {
+			ratio = math.Min(ratio, float64(MaxDimension)/float64(longest))
+		}
+		imgs[i] = img
+	}
+	if ratio < 1 {
+		for i, img := range imgs {
+			b := img.Bounds()
+			imgs[i] = resize.Resize(uint(math.Round(float64(b.Dx())*ratio)), uint(math.Round(float64(b.Dy())*ratio)), img, resize.Lanczos3)
+		}
+	}
+
+	stitched := &StitchedImage{}
+	positions := make([]image.Point, len(imgs))
+	for i := 1; i < len(imgs); i++ {
+		if opts.Offsets != nil {
+			positions[i] = image.Point{
+				X: int(math.Round((opts.Offsets[i].X - opts.Offsets[0].X) * ratio)),
+				Y: int(math.Round((opts.Offsets[i].Y - opts.Offsets[0].Y) * ratio)),
+			}
+			continue
+		}
+		offset, score := alignPair(imgs[i-1], imgs[i], opts.Overlap, opts.Vertical)
+		if score < minAlignScore {
+			offset = declaredOffset(imgs[i-1].Bounds(), opts.Overlap, opts.Vertical)
+			stitched.Unaligned = append(stitched.Unaligned, i)
+			log.Printf("[StitchImages] Could not align photo %d with photo %d (score %.2f), using the declared overlap", i+1, i, score)
+		} else {
+			log.Printf("[StitchImages] Photo %d is at %v from photo %d (score %.2f)", i+1, offset, i, score)
+		}
+		positions[i] = positions[i-1].Add(offset)
+	}
+
+	// Move the frame's origin to the top-left of the photos
+	frame := image.Rectangle{}
+	for i, img := range imgs {
+		r := image.Rectangle{Min: positions[i], Max: positions[i].Add(img.Bounds().Size())}
+		if i == 0 {
+			frame = r
+		} else {
+			frame = frame.Union(r)
+		}
+	}
+	for i := range positions {
+		positions[i] = positions[i].Sub(frame.Min)
+	}
+	frameW, frameH := frame.Dx(), frame.Dy()
+
+	for i, img := range imgs {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: Quality}); err != nil {
+			return nil, err
+		}
+		b := img.Bounds()
+		stitched.Photos = append(stitched.Photos, Tile{
+			X: positions[i].X, Y: positions[i].Y, Width: b.Dx(), Height: b.Dy(),
+			Data: buf.Bytes(), MimeType: "image/jpeg",
+		})
+	}
+
+	// Composite the photos, later ones on top, into a preview of the whole board
+	scale := math.Min(1, float64(MaxDimension)/float64(max(frameW, frameH)))
+	width, height := int(math.Round(float64(frameW)*scale)), int(math.Round(float64(frameH)*scale))
+	composite := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(composite, composite.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
+	for i, img := range imgs {
+		if scale < 1 {
+			b := img.Bounds()
+			img = resize.Resize(uint(math.Round(float64(b.Dx())*scale)), uint(math.Round(float64(b.Dy())*scale)), img, resize.Lanczos3)
+		}
+		at := image.Point{X: int(math.Round(float64(positions[i].X) * scale)), Y: int(math.Round(float64(positions[i].Y) * scale))}
+		draw.Draw(composite, img.Bounds().Sub(img.Bounds().Min).Add(at), img, img.Bounds().Min, draw.Src)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, composite, &jpeg.Options{Quality: Quality}); err != nil {
+		return nil, err
+	}
+	stitched.ProcessedImage = ProcessedImage{
+		Data:           buf.Bytes(),
+		MimeType:       "image/jpeg",
+		Width:          width,
+		Height:         height,
+		OriginalWidth:  frameW,
+		OriginalHeight: frameH,
+		ResizeRatio:    scale,
+	}
+	log.Printf("[StitchImages] Stitched %d photos into a %dx%d frame (preview %dx%d)", len(imgs), frameW, frameH, width, height)
+	return stitched, nil
+}
+
+// declaredOffset is where the next photo starts if the photos overlap exactly as declared
+func declaredOffset(b image.Rectangle, overlap float64, vertical bool) image.Point {
+	if vertical {
+		return image.Point{Y: int(math.Round(float64(b.Dy()) * (1 - overlap)))}
+	}
+	return image.Point{X: int(math.Round(float64(b.Dx()) * (1 - overlap)))}
+}
+
+// grayImage is a greyscale copy of an image for alignment
+type grayImage struct {
+	w, h int
+	pix  []float64
+}
+
+func toGray(img image.Image, scale float64) grayImage {
+	b := img.Bounds()
+	w, h := max(1, int(math.Round(float64(b.Dx())*scale))), max(1, int(math.Round(float64(b.Dy())*scale)))
+	if w != b.Dx() || h != b.Dy() {
+		img = resize.Resize(uint(w), uint(h), img, resize.Bilinear)
+		b = img.Bounds()
+	}
+	g := grayImage{w: w, h: h, pix: make([]float64, w*h)}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, gr, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			g.pix[y*w+x] = 0.299*float64(r) + 0.587*float64(gr) + 0.114*float64(bl)
+		}
+	}
+	return g
+}
+
+// alignPair finds where b's top-left lies in a's pixels, and how well the
+// photos then agree (normalised cross-correlation of the shared area, 1 at
+// best). The search starts at low resolution around the declared overlap
+// and is refined at each of alignLevels.
+func alignPair(a, b image.Image, overlap float64, vertical bool) (image.Point, float64) {
+	ab := a.Bounds()
+	longest := max(ab.Dx(), ab.Dy())
+	var best image.Point
+	score := -1.0
+	prevScale := 0.0
+	for _, level := range alignLevels {
+		scale := math.Min(1, float64(level)/float64(longest))
+		if scale <= prevScale {
+			break
+		}
+		ga, gb := toGray(a, scale), toGray(b, scale)
+		var lo, hi image.Point
+		if prevScale == 0 {
+			// Full search around the declared overlap
+			along, across := ga.w, ga.h
+			if vertical {
+				along, across = ga.h, ga.w
+			}
+			start := float64(along) * (1 - overlap)
+			from := max(int(float64(along)*minAlignArea), int(start-float64(along)*alignSearch))
+			to := min(int(float64(along)*(1-minAlignArea)), int(start+float64(along)*alignSearch))
+			drift := int(float64(across) * alignDrift)
+			lo, hi = image.Point{X: from, Y: -drift}, image.Point{X: to, Y: drift}
+			if vertical {
+				lo, hi = image.Point{X: -drift, Y: from}, image.Point{X: drift, Y: to}
+			}
+		} else {
+			// Refine around the previous level's best offset
+			f := scale / prevScale
+			r := int(math.Ceil(f)) + 1
+			c := image.Point{X: int(math.Round(float64(best.X) * f)), Y: int(math.Round(float64(best.Y) * f))}
+			lo, hi = c.Sub(image.Point{X: r, Y: r}), c.Add(image.Point{X: r, Y: r})
+		}
+		best, score = searchOffset(ga, gb, lo, hi)
+		prevScale = scale
+	}
+	return image.Point{X: int(math.Round(float64(best.X) / prevScale)), Y: int(math.Round(float64(best.Y) / prevScale))}, score
+}
+
+// searchOffset tries every offset of b within a from lo to hi inclusive
+func searchOffset(a, b grayImage, lo, hi image.Point) (image.Point, float64) {
+	var best image.Point
+	bestScore := -1.0
+	minArea := int(float64(min(a.w*a.h, b.w*b.h)) * minAlignArea)
+	for dy := lo.Y; dy <= hi.Y; dy++ {
+		for dx := lo.X; dx <= hi.X; dx++ {
+			x0, x1 := max(0, dx), min(a.w, dx+b.w)
+			y0, y1 := max(0, dy), min(a.h, dy+b.h)
+			if (x1-x0)*(y1-y0) < max(1, minArea) || x1 <= x0 || y1 <= y0 {
+				continue
+			}
+			if s := correlation(a, b, dx, dy, x0, x1, y0, y1); s > bestScore {
+				best, bestScore = image.Point{X: dx, Y: dy}, s
+			}
+		}
+	}
+	return best, bestScore
+}
+
+// correlation is the normalised cross-correlation of a and b over the
+// rectangle x0..x1, y0..y1 of a, with b offset by dx, dy. It is 0 where
+// either is blank, whose correlation is only rounding error.
+func correlation(a, b grayImage, dx, dy, x0, x1, y0, y1 int) float64 {
+	var sa, sb, saa, sbb, sab float64
+	for y := y0; y < y1; y++ {
+		ra := a.pix[y*a.w : (y+1)*a.w]
+		rb := b.pix[(y-dy)*b.w : (y-dy+1)*b.w]
+		for x := x0; x < x1; x++ {
+			va, vb := ra[x], rb[x-dx]
+			sa += va
+			sb += vb
+			saa += va * va
+			sbb += vb * vb
+			sab += va * vb
+		}
+	}
+	n := float64((x1 - x0) * (y1 - y0))
+	varA, varB := n*saa-sa*sa, n*sbb-sb*sb // n² times the variances
+	if minVar := n * n * minAlignContrast * minAlignContrast; varA < minVar || varB < minVar {
+		return 0
+	}
+	return (n*sab - sa*sb) / math.Sqrt(varA*varB)
+}
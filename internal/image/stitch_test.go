@@ -0,0 +1,146 @@
+package image
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+// syntheticBoard is a white board with coloured rectangles scattered over
+// it, so any two overlapping crops agree in only one place
+func syntheticBoard(width, height int) *image.RGBA {
+	board := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(board, board.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 120; i++ {
+		x, y := rng.Intn(width), rng.Intn(height)
+		r := image.Rect(x, y, x+10+rng.Intn(50), y+10+rng.Intn(50))
+		c := color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+		draw.Draw(board, r, &image.Uniform{c}, image.Point{}, draw.Src)
+	}
+	return board
+}
+
+// crop copies r out of src into an image whose origin is 0,0
+func crop(src image.Image, r image.Rectangle) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, r.Min, draw.Src)
+	return dst
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestAlignPair(t *testing.T) {
+	board := syntheticBoard(700, 700)
+	tests := []struct {
+		name     string
+		a, b     image.Rectangle
+		overlap  float64
+		vertical bool
+	}{
+		{"horizontal", image.Rect(0, 0, 360, 280), image.Rect(230, 12, 590, 292), 0.3, false},
+		{"horizontal drifting up", image.Rect(0, 40, 360, 320), image.Rect(260, 10, 620, 290), 0.3, false},
+		{"larger overlap than declared", image.Rect(0, 0, 360, 280), image.Rect(170, 0, 530, 280), 0.3, false},
+		{"vertical", image.Rect(0, 0, 280, 360), image.Rect(5, 250, 285, 610), 0.3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, score := alignPair(crop(board, tt.a), crop(board, tt.b), tt.overlap, tt.vertical)
+			if want := tt.b.Min.Sub(tt.a.Min); offset != want {
+				t.Errorf("got offset %v, want %v", offset, want)
+			}
+			if score < 0.99 {
+				t.Errorf("got score %.3f for an exact overlap", score)
+			}
+		})
+	}
+}
+
+func TestStitchImages(t *testing.T) {
+	board := syntheticBoard(700, 400)
+	a, b := image.Rect(0, 20, 360, 300), image.Rect(230, 0, 590, 280)
+	photos := [][]byte{encodePNG(t, crop(board, a)), encodePNG(t, crop(board, b))}
+
+	stitched, err := StitchImages(photos, StitchOptions{})
+	if err != nil {
+		t.Fatalf("StitchImages: %v", err)
+	}
+	if len(stitched.Unaligned) != 0 {
+		t.Errorf("photos %v were not aligned", stitched.Unaligned)
+	}
+	// The frame's origin is the top-left of the photos: photo 2 is higher
+	if p := stitched.Photos; p[0].X != 0 || p[0].Y != 20 || p[1].X != 230 || p[1].Y != 0 {
+		t.Errorf("got photos at (%d,%d) and (%d,%d), want (0,20) and (230,0)", p[0].X, p[0].Y, p[1].X, p[1].Y)
+	}
+	if stitched.OriginalWidth != 590 || stitched.OriginalHeight != 300 {
+		t.Errorf("got a %dx%d frame, want 590x300", stitched.OriginalWidth, stitched.OriginalHeight)
+	}
+	if stitched.Width != 590 || stitched.Height != 300 || stitched.ResizeRatio != 1 {
+		t.Errorf("got a %dx%d preview at %v, want 590x300 at 1", stitched.Width, stitched.Height, stitched.ResizeRatio)
+	}
+}
+
+func TestStitchImagesUnaligned(t *testing.T) {
+	// Blank photos agree everywhere, so they are placed by the declared overlap
+	blank := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	draw.Draw(blank, blank.Bounds(), &image.Uniform{color.Gray{200}}, image.Point{}, draw.Src)
+	data := encodePNG(t, blank)
+
+	stitched, err := StitchImages([][]byte{data, data, data}, StitchOptions{Overlap: 0.25, Vertical: true})
+	if err != nil {
+		t.Fatalf("StitchImages: %v", err)
+	}
+	if len(stitched.Unaligned) != 2 || stitched.Unaligned[0] != 1 || stitched.Unaligned[1] != 2 {
+		t.Errorf("got unaligned %v, want [1 2]", stitched.Unaligned)
+	}
+	for i, p := range stitched.Photos {
+		if p.X != 0 || p.Y != i*225 {
+			t.Errorf("photo %d at (%d,%d), want (0,%d)", i+1, p.X, p.Y, i*225)
+		}
+	}
+	if stitched.OriginalWidth != 400 || stitched.OriginalHeight != 750 {
+		t.Errorf("got a %dx%d frame, want 400x750", stitched.OriginalWidth, stitched.OriginalHeight)
+	}
+}
+
+func TestStitchImagesOffsets(t *testing.T) {
+	blank := encodePNG(t, image.NewRGBA(image.Rect(0, 0, 200, 100)))
+	photos := [][]byte{blank, blank, blank}
+	// Offsets are relative to the first photo and may be negative
+	offsets := []Point{{X: 50, Y: 50}, {X: 200, Y: 30}, {X: 0, Y: 120}}
+
+	stitched, err := StitchImages(photos, StitchOptions{Offsets: offsets})
+	if err != nil {
+		t.Fatalf("StitchImages: %v", err)
+	}
+	want := []image.Point{{50, 20}, {200, 0}, {0, 90}}
+	for i, p := range stitched.Photos {
+		if p.X != want[i].X || p.Y != want[i].Y {
+			t.Errorf("photo %d at (%d,%d), want %v", i+1, p.X, p.Y, want[i])
+		}
+	}
+	if len(stitched.Unaligned) != 0 {
+		t.Errorf("got unaligned %v with manual offsets", stitched.Unaligned)
+	}
+	if stitched.OriginalWidth != 400 || stitched.OriginalHeight != 190 {
+		t.Errorf("got a %dx%d frame, want 400x190", stitched.OriginalWidth, stitched.OriginalHeight)
+	}
+
+	if _, err := StitchImages(photos, StitchOptions{Offsets: offsets[:2]}); err == nil {
+		t.Error("got no error for two offsets and three photos")
+	}
+	if _, err := StitchImages(photos[:1], StitchOptions{}); err == nil {
+		t.Error("got no error stitching one photo")
+	}
+}
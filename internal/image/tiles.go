@@ -0,0 +1,95 @@
+package image
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"log"
+	"math"
+)
+
+// DefaultTileOverlap is the fraction of a tile shared with each neighbour.
+// It should be larger than a note, so every note is whole in some tile.
+const DefaultTileOverlap = 0.2
+
+// TileOptions controls SplitTiles. Zero fields use the defaults.
+type TileOptions struct {
+	Size    int     // longest tile side in pixels; default MaxDimension, so tiles are never downsized
+	Overlap float64 // fraction of a tile overlapping its neighbour; default DefaultTileOverlap
+}
+
+// Tile is a region of the full-resolution image, encoded for extraction
+type Tile struct {
+	X        int    `json:"x"` // position of the tile in the full image
+	Y        int    `json:"y"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Data     []byte `json:"-"` // JPEG
+	MimeType string `json:"-"`
+}
+
+// Interior reports how far a box lies from the tile's edges, ignoring edges
+// on the border of the full image, which cut nothing off. A note close to an
+// inner edge may be cut in two.
+func (t Tile) Interior(x, y, width, height, imageWidth, imageHeight int) int {
+	d := math.MaxInt
+	if t.X > 0 {
+		d = min(d, x-t.X)
+	}
+	if t.Y > 0 {
+		d = min(d, y-t.Y)
+	}
+	if t.X+t.Width < imageWidth {
+		d = min(d, t.X+t.Width-(x+width))
+	}
+	if t.Y+t.Height < imageHeight {
+		d = min(d, t.Y+t.Height-(y+height))
+	}
+	return d
+}
+
+// SplitTiles cuts img into a grid of overlapping tiles. An image that fits
+// in one tile gives a single tile.
+func SplitTiles(img image.Image, opts TileOptions) ([]Tile, error) {
+	if opts.Size <= 0 {
+		opts.Size = MaxDimension
+	}
+	if opts.Overlap <= 0 || opts.Overlap >= 1 {
+		opts.Overlap = DefaultTileOverlap
+	}
+	b := img.Bounds()
+	xs := tileStarts(b.Dx(), opts.Size, opts.Overlap)
+	ys := tileStarts(b.Dy(), opts.Size, opts.Overlap)
+
+	tiles := make([]Tile, 0, len(xs)*len(ys))
+	for _, y := range ys {
+		for _, x := range xs {
+			w, h := min(opts.Size, b.Dx()-x), min(opts.Size, b.Dy()-y)
+			tile := image.NewRGBA(image.Rect(0, 0, w, h))
+			draw.Draw(tile, tile.Bounds(), img, image.Point{X: b.Min.X + x, Y: b.Min.Y + y}, draw.Src)
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, tile, &jpeg.Options{Quality: Quality}); err != nil {
+				return nil, err
+			}
+			tiles = append(tiles, Tile{X: x, Y: y, Width: w, Height: h, Data: buf.Bytes(), MimeType: "image/jpeg"})
+		}
+	}
+	log.Printf("[SplitTiles] Split %dx%d image into %dx%d tiles of up to %dpx with %.0f%% overlap",
+		b.Dx(), b.Dy(), len(xs), len(ys), opts.Size, opts.Overlap*100)
+	return tiles, nil
+}
+
+// tileStarts spreads tiles of size evenly over length, overlapping by at least overlap
+func tileStarts(length, size int, overlap float64) []int {
+	if length <= size {
+		return []int{0}
+	}
+	stride := float64(size) * (1 - overlap)
+	n := int(math.Ceil(float64(length-size)/stride)) + 1
+	starts := make([]int, n)
+	for i := range starts {
+		starts[i] = int(math.Round(float64(i) * float64(length-size) / float64(n-1)))
+	}
+	return starts
+}
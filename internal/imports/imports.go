@@ -0,0 +1,262 @@
+package imports
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/fsutil"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/ids"
+)
+
+const (
+	DefaultMaxImports = 200            // how many imports are remembered before the oldest are dropped
+	DefaultFile       = "imports.json" // the import log's file name, kept next to the config file
+)
+
+var (
+	ErrNotFound       = errors.New("import not found")
+	ErrUndone         = errors.New("import already undone")
+	ErrUndoInProgress = errors.New("import is being undone")
+)
+
+// Widget is a widget created by an import, with its state right after creation
+type Widget struct {
+	ID       string         `json:"id"`
+	Snapshot canvusapi.Note `json:"snapshot"`
+}
+
+// Import records one /api/create-notes run
+type Import struct {
+	ID         string     `json:"id"`
+	CanvasID   string     `json:"canvasID"`
+	AnchorID   string     `json:"anchorID"`
+	ScanID     string     `json:"scanID,omitempty"`
+	Widgets    []Widget   `json:"widgets"`
+	Connectors []string   `json:"connectors,omitempty"` // connectors created between the widgets
+	Images     []string   `json:"images,omitempty"`     // source photos placed with the widgets
+	Anchors    []string   `json:"anchors,omitempty"`    // anchors created to hold the widgets
+	CreatedAt  time.Time  `json:"createdAt"`
+	UndoneAt   *time.Time `json:"undoneAt,omitempty"`
+}
+
+// WidgetIDs returns the IDs of the widgets the import created
+func (imp Import) WidgetIDs() []string {
+	ids := make([]string, len(imp.Widgets))
+	for i, w := range imp.Widgets {
+		ids[i] = w.ID
+	}
+	return ids
+}
+
+// Store keeps the most recent imports in memory and, if it was opened with
+// OpenStore, in a file, so undo and sync still work after a restart
+type Store struct {
+	mu      sync.Mutex
+	imports map[string]*Import
+	max     int
+	path    string          // where the imports are saved; empty to keep them in memory only
+	undoing map[string]bool // imports with an undo in progress
+}
+
+// NewStore creates a store that remembers up to max imports
+func NewStore(max int) *Store {
+	if max <= 0 {
+		max = DefaultMaxImports
+	}
+	return &Store{imports: make(map[string]*Import), max: max, undoing: make(map[string]bool)}
+}
+
+// OpenStore creates a store that saves its imports to path, starting with the
+// imports already saved there. A missing file is fine.
+func OpenStore(path string, max int) (*Store, error) {
+	s := NewStore(max)
+	s.path = path
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		var saved []*Import
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		for _, imp := range saved {
+			s.imports[imp.ID] = imp
+		}
+		s.trimLocked()
+		log.Printf("[imports] Loaded %d imports from %s", len(s.imports), path)
+	case errors.Is(err, os.ErrNotExist):
+	default:
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Record stores a new import and returns it with its ID and creation time set
+func (s *Store) Record(imp Import) Import {
+	imp.ID = ids.New()
+	imp.CreatedAt = time.Now()
+	imp.UndoneAt = nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.imports[imp.ID] = &imp
+	s.trimLocked()
+	s.saveLocked()
+	return imp
+}
+
+// Get returns the import with the given ID
+func (s *Store) Get(id string) (Import, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	imp, ok := s.imports[id]
+	if !ok {
+		return Import{}, ErrNotFound
+	}
+	return *imp, nil
+}
+
+// List returns the imports into a canvas (all canvases if canvasID is empty), newest first
+func (s *Store) List(canvasID string) []Import {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Import
+	for _, imp := range s.imports {
+		if canvasID == "" || imp.CanvasID == canvasID {
+			out = append(out, *imp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+// BeginUndo returns the import and marks an undo of it as in progress, so
+// concurrent undos of the same import are refused with ErrUndoInProgress.
+// Finish with MarkUndone, or EndUndo if the import was only partly undone.
+func (s *Store) BeginUndo(id string) (Import, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	imp, ok := s.imports[id]
+	switch {
+	case !ok:
+		return Import{}, ErrNotFound
+	case imp.UndoneAt != nil:
+		return Import{}, ErrUndone
+	case s.undoing[id]:
+		return Import{}, ErrUndoInProgress
+	}
+	s.undoing[id] = true
+	return *imp, nil
+}
+
+// EndUndo clears the in-progress mark set by BeginUndo
+func (s *Store) EndUndo(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.undoing, id)
+}
+
+// MarkUndone records that the import has been undone
+func (s *Store) MarkUndone(id string) (Import, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	imp, ok := s.imports[id]
+	if !ok {
+		return Import{}, ErrNotFound
+	}
+	delete(s.undoing, id)
+	now := time.Now()
+	imp.UndoneAt = &now
+	s.saveLocked()
+	return *imp, nil
+}
+
+// UpdateSnapshot replaces the snapshot of a widget after the app itself has
+// changed it, so the change does not count as a modification on undo
+func (s *Store) UpdateSnapshot(widgetID string, snapshot canvusapi.Note) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := false
+	for _, imp := range s.imports {
+		for i := range imp.Widgets {
+			if imp.Widgets[i].ID == widgetID {
+				imp.Widgets[i].Snapshot = snapshot
+				changed = true
+			}
+		}
+	}
+	if changed {
+		s.saveLocked()
+	}
+}
+
+// trimLocked drops the oldest imports beyond s.max, undone ones first, since
+// they no longer take part in undo or sync. s.mu must be held.
+func (s *Store) trimLocked() {
+	for len(s.imports) > s.max {
+		var oldest *Import
+		for _, imp := range s.imports {
+			switch {
+			case oldest == nil:
+				oldest = imp
+			case (imp.UndoneAt != nil) != (oldest.UndoneAt != nil):
+				if imp.UndoneAt != nil {
+					oldest = imp
+				}
+			case imp.CreatedAt.Before(oldest.CreatedAt):
+				oldest = imp
+			}
+		}
+		delete(s.imports, oldest.ID)
+	}
+}
+
+// saveLocked writes the imports to s.path, oldest first. A failed save is
+// logged rather than returned, since the canvas has already been changed.
+// s.mu must be held.
+func (s *Store) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	list := make([]*Import, 0, len(s.imports))
+	for _, imp := range s.imports {
+		list = append(list, imp)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err == nil {
+		err = fsutil.WriteFileAtomic(s.path, append(data, '\n'), 0600)
+	}
+	if err != nil {
+		log.Printf("[imports] Failed to save imports to %s: %v", s.path, err)
+	}
+}
+
+// Modified reports whether a widget differs from its snapshot in position,
+// size, scale, text, colour or parent, i.e. someone has worked with it since the import
+func Modified(snapshot, current *canvusapi.Note) bool {
+	const eps = 0.5 // MCS may round coordinates
+	near := func(a, b float64) bool { return math.Abs(a-b) <= eps }
+	if snapshot.Text != current.Text || snapshot.BackgroundColor != current.BackgroundColor || snapshot.ParentID != current.ParentID {
+		return true
+	}
+	if math.Abs(snapshot.ScaleValue()-current.ScaleValue()) > 1e-3 { // notes are resized on the wall by scaling
+		return true
+	}
+	if (snapshot.Location == nil) != (current.Location == nil) || (snapshot.Size == nil) != (current.Size == nil) {
+		return true
+	}
+	if snapshot.Location != nil && (!near(snapshot.Location.X, current.Location.X) || !near(snapshot.Location.Y, current.Location.Y)) {
+		return true
+	}
+	if snapshot.Size != nil && (!near(snapshot.Size.Width, current.Size.Width) || !near(snapshot.Size.Height, current.Size.Height)) {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package imports
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+)
+
+func TestOpenStoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFile)
+	s, err := OpenStore(path, 10)
+	if err != nil {
+		t.Fatalf("OpenStore on a missing file: %v", err)
+	}
+	kept := s.Record(Import{CanvasID: "c", AnchorID: "a", Widgets: []Widget{{ID: "n1", Snapshot: canvusapi.Note{Text: "old"}}}})
+	undone := s.Record(Import{CanvasID: "c", AnchorID: "a", Widgets: []Widget{{ID: "n2"}}})
+	if _, err := s.MarkUndone(undone.ID); err != nil {
+		t.Fatal(err)
+	}
+	s.UpdateSnapshot("n1", canvusapi.Note{Text: "new"})
+
+	reopened, err := OpenStore(path, 10)
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+	got, err := reopened.Get(kept.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AnchorID != "a" || len(got.Widgets) != 1 || got.Widgets[0].Snapshot.Text != "new" || got.UndoneAt != nil {
+		t.Errorf("got %+v after reload", got)
+	}
+	if got, err := reopened.Get(undone.ID); err != nil || got.UndoneAt == nil {
+		t.Errorf("undone import reloaded as %+v, %v", got, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("import log stat: %v, %v", info, err)
+	}
+}
+
+func TestOpenStoreInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFile)
+	os.WriteFile(path, []byte("not json"), 0600)
+	if _, err := OpenStore(path, 10); err == nil {
+		t.Error("got no error for a corrupt import log")
+	}
+}
+
+func TestTrimDropsUndoneFirst(t *testing.T) {
+	s := NewStore(2)
+	first := s.Record(Import{CanvasID: "c"})
+	time.Sleep(time.Millisecond)
+	second := s.Record(Import{CanvasID: "c"})
+	s.MarkUndone(second.ID)
+	time.Sleep(time.Millisecond)
+	third := s.Record(Import{CanvasID: "c"})
+	if _, err := s.Get(second.ID); err != ErrNotFound {
+		t.Errorf("undone import was kept")
+	}
+	for _, id := range []string{first.ID, third.ID} {
+		if _, err := s.Get(id); err != nil {
+			t.Errorf("import %s: %v", id, err)
+		}
+	}
+}
+
+func TestBeginUndo(t *testing.T) {
+	s := NewStore(10)
+	imp := s.Record(Import{CanvasID: "c"})
+	if _, err := s.BeginUndo("missing"); err != ErrNotFound {
+		t.Errorf("got %v for an unknown import, want ErrNotFound", err)
+	}
+	if _, err := s.BeginUndo(imp.ID); err != nil {
+		t.Fatalf("BeginUndo: %v", err)
+	}
+	if _, err := s.BeginUndo(imp.ID); err != ErrUndoInProgress {
+		t.Errorf("got %v during an undo, want ErrUndoInProgress", err)
+	}
+	// A partial undo leaves the import open for another attempt
+	s.EndUndo(imp.ID)
+	if _, err := s.BeginUndo(imp.ID); err != nil {
+		t.Fatalf("BeginUndo after EndUndo: %v", err)
+	}
+	if _, err := s.MarkUndone(imp.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.BeginUndo(imp.ID); err != ErrUndone {
+		t.Errorf("got %v after the undo, want ErrUndone", err)
+	}
+}
@@ -0,0 +1,291 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/ids"
+)
+
+// Status is the lifecycle state of a job
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusComplete  Status = "complete"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// Terminal reports whether the job has finished
+func (s Status) Terminal() bool {
+	return s == StatusComplete || s == StatusFailed || s == StatusCancelled
+}
+
+const (
+	DefaultWorkers   = 2                // Concurrent jobs when not configured
+	DefaultQueueSize = 64               // Jobs waiting for a worker before Submit fails
+	DefaultRetention = 30 * time.Minute // How long finished jobs stay queryable
+)
+
+// ErrQueueFull is returned by Submit when no more jobs can be queued
+var ErrQueueFull = errors.New("job queue is full")
+
+// ErrNotFound is returned for unknown (or evicted) job IDs
+var ErrNotFound = errors.New("job not found")
+
+// Reporter lets a running job publish its current stage and progress (0-1)
+type Reporter func(stage string, progress float64)
+
+// Func is the work performed by a job. The returned result is published to subscribers.
+type Func func(ctx context.Context, report Reporter) (interface{}, error)
+
+// Snapshot is the externally visible state of a job
+type Snapshot struct {
+	ID        string      `json:"id"`
+	Kind      string      `json:"kind"`
+	Status    Status      `json:"status"`
+	Stage     string      `json:"stage,omitempty"`
+	Progress  float64     `json:"progress"`
+	Result    interface{} `json:"result,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	CreatedAt time.Time   `json:"createdAt"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+}
+
+// job is the internal state of a job
+type job struct {
+	snap        Snapshot
+	fn          Func
+	ctx         context.Context
+	cancel      context.CancelFunc
+	subscribers map[chan Snapshot]struct{}
+}
+
+// Manager runs jobs on a fixed pool of workers
+type Manager struct {
+	mu        sync.Mutex
+	jobs      map[string]*job
+	queue     chan *job
+	retention time.Duration
+}
+
+// NewManager starts a manager with the given number of workers
+func NewManager(workers int) *Manager {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	m := &Manager{
+		jobs:      make(map[string]*job),
+		queue:     make(chan *job, DefaultQueueSize),
+		retention: DefaultRetention,
+	}
+	for i := 0; i < workers; i++ {
+		go m.worker()
+	}
+	log.Printf("[jobs] Started manager with %d workers", workers)
+	return m
+}
+
+// Submit queues a job and returns its initial snapshot
+func (m *Manager) Submit(kind string, fn Func) (Snapshot, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	j := &job{
+		snap: Snapshot{
+			ID:        ids.New(),
+			Kind:      kind,
+			Status:    StatusQueued,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		fn:          fn,
+		ctx:         ctx,
+		cancel:      cancel,
+		subscribers: make(map[chan Snapshot]struct{}),
+	}
+
+	m.mu.Lock()
+	m.evictLocked(now)
+	select {
+	case m.queue <- j:
+	default:
+		m.mu.Unlock()
+		cancel()
+		return Snapshot{}, ErrQueueFull
+	}
+	m.jobs[j.snap.ID] = j
+	snap := j.snap
+	m.mu.Unlock()
+
+	log.Printf("[jobs] Queued %s job %s", kind, snap.ID)
+	return snap, nil
+}
+
+// Get returns the current snapshot of a job
+func (m *Manager) Get(id string) (Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Snapshot{}, ErrNotFound
+	}
+	return j.snap, nil
+}
+
+// Cancel stops a queued or running job
+func (m *Manager) Cancel(id string) (Snapshot, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return Snapshot{}, ErrNotFound
+	}
+	if j.snap.Status.Terminal() {
+		snap := j.snap
+		m.mu.Unlock()
+		return snap, nil
+	}
+	j.cancel()
+	m.updateLocked(j, func(s *Snapshot) {
+		s.Status = StatusCancelled
+		s.Error = context.Canceled.Error()
+	})
+	snap := j.snap
+	m.mu.Unlock()
+	log.Printf("[jobs] Cancelled job %s", id)
+	return snap, nil
+}
+
+// Subscribe returns a channel receiving every update to the job, starting with
+// its current state. The channel is closed once the job finishes; call the
+// returned function to unsubscribe early.
+func (m *Manager) Subscribe(id string) (<-chan Snapshot, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	ch := make(chan Snapshot, 16)
+	ch <- j.snap
+	if j.snap.Status.Terminal() {
+		close(ch)
+		return ch, func() {}, nil
+	}
+	j.subscribers[ch] = struct{}{}
+	unsubscribe := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := j.subscribers[ch]; ok {
+			delete(j.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe, nil
+}
+
+func (m *Manager) worker() {
+	for j := range m.queue {
+		m.run(j)
+	}
+}
+
+func (m *Manager) run(j *job) {
+	m.mu.Lock()
+	if j.snap.Status != StatusQueued {
+		// Cancelled while waiting in the queue
+		m.mu.Unlock()
+		return
+	}
+	m.updateLocked(j, func(s *Snapshot) { s.Status = StatusRunning })
+	m.mu.Unlock()
+	log.Printf("[jobs] Running %s job %s", j.snap.Kind, j.snap.ID)
+
+	report := func(stage string, progress float64) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if j.snap.Status.Terminal() {
+			return
+		}
+		m.updateLocked(j, func(s *Snapshot) {
+			s.Stage = stage
+			s.Progress = progress
+		})
+	}
+	result, err := call(j, report)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j.cancel()
+	if j.snap.Status.Terminal() {
+		return
+	}
+	m.updateLocked(j, func(s *Snapshot) {
+		switch {
+		case err != nil && errors.Is(err, context.Canceled):
+			s.Status = StatusCancelled
+			s.Error = err.Error()
+		case err != nil:
+			s.Status = StatusFailed
+			s.Error = err.Error()
+		default:
+			s.Status = StatusComplete
+			s.Progress = 1
+			s.Result = result
+		}
+	})
+	log.Printf("[jobs] Finished job %s with status %s", j.snap.ID, j.snap.Status)
+}
+
+// call runs the job's function, turning a panic into an error, so a bug in
+// one job fails that job instead of the whole server
+func call(j *job, report Reporter) (result interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("[jobs] Job %s panicked: %v\n%s", j.snap.ID, p, debug.Stack())
+			result, err = nil, fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return j.fn(j.ctx, report)
+}
+
+// updateLocked applies fn to the job and notifies subscribers. m.mu must be held.
+func (m *Manager) updateLocked(j *job, fn func(s *Snapshot)) {
+	fn(&j.snap)
+	j.snap.UpdatedAt = time.Now()
+	terminal := j.snap.Status.Terminal()
+	for ch := range j.subscribers {
+		select {
+		case ch <- j.snap:
+		default:
+			// Slow subscriber: drop intermediate updates, but always deliver the final state
+			if terminal {
+				select {
+				case <-ch:
+				default:
+				}
+				ch <- j.snap
+			}
+		}
+		if terminal {
+			delete(j.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// evictLocked drops finished jobs older than the retention period. m.mu must be held.
+func (m *Manager) evictLocked(now time.Time) {
+	for id, j := range m.jobs {
+		if j.snap.Status.Terminal() && now.Sub(j.snap.UpdatedAt) > m.retention {
+			delete(m.jobs, id)
+		}
+	}
+}
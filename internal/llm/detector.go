@@ -0,0 +1,87 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/image"
+)
+
+// DetectorExtractor finds notes by colour with image.DetectNotes, without
+// calling any model. Notes have accurate geometry and colour but no text,
+// and no edges are found.
+type DetectorExtractor struct {
+	Options image.DetectOptions
+}
+
+func init() {
+	Register("cv", func(cfg ExtractorConfig) (Extractor, error) {
+		return DetectorExtractor{}, nil
+	})
+}
+
+// Name returns the registered backend name.
+func (DetectorExtractor) Name() string { return "cv" }
+
+// Extract detects the notes in the image.
+func (d DetectorExtractor) Extract(ctx context.Context, input ExtractPostitNotesInput) (*Extraction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	detected, err := image.DetectNotesData(input.ImageData, d.Options)
+	if err != nil {
+		return nil, err
+	}
+	notes := make([]ExtractPostitNotesOutput, len(detected))
+	for i, n := range detected {
+		notes[i] = detectedNote(n)
+	}
+	return &Extraction{Notes: notes, Edges: []Edge{}}, nil
+}
+
+// detectedNote converts a detected note, which has no text yet
+func detectedNote(n image.DetectedNote) ExtractPostitNotesOutput {
+	return ExtractPostitNotesOutput{
+		BackgroundColor: n.Color,
+		Location:        map[string]int{"x": n.X, "y": n.Y},
+		Scale:           1,
+		Size:            map[string]int{"width": n.Width, "height": n.Height},
+		State:           "normal",
+		WidgetType:      "Note",
+	}
+}
+
+// WithDetectorFallback returns an extractor that runs e, and falls back to
+// the colour detector when e fails, for example because the model is
+// unreachable or its API key is missing. Cancellation is not retried.
+func WithDetectorFallback(e Extractor) Extractor {
+	if _, ok := e.(DetectorExtractor); ok {
+		return e
+	}
+	return fallbackExtractor{primary: e}
+}
+
+// fallbackExtractor is the extractor returned by WithDetectorFallback
+type fallbackExtractor struct {
+	primary  Extractor
+	detector DetectorExtractor
+}
+
+// Name returns the name of the primary backend.
+func (f fallbackExtractor) Name() string { return f.primary.Name() }
+
+// Extract runs the primary backend, or the detector if it fails.
+func (f fallbackExtractor) Extract(ctx context.Context, input ExtractPostitNotesInput) (*Extraction, error) {
+	extraction, err := f.primary.Extract(ctx, input)
+	if err == nil || errors.Is(err, context.Canceled) || ctx.Err() != nil {
+		return extraction, err
+	}
+	log.Printf("[ExtractPostitNotes] %s failed, falling back to colour detection: %v", f.primary.Name(), err)
+	extraction, detectErr := f.detector.Extract(ctx, input)
+	if detectErr != nil {
+		return nil, errors.Join(err, detectErr)
+	}
+	extraction.Fallback = err.Error()
+	return extraction, nil
+}
@@ -30,33 +30,99 @@ type ExtractPostitNotesOutput struct {
 	WidgetType      string         `json:"widget_type"`
 }
 
-// ExtractPostitNotes extracts notes from an image using Google Gemini.
-func ExtractPostitNotes(input ExtractPostitNotesInput) ([]ExtractPostitNotesOutput, error) {
-	apiKey := os.Getenv("GOOGLE_GENAI_API_KEY")
-	if apiKey == "" {
-		log.Printf("[ExtractPostitNotes] GOOGLE_GENAI_API_KEY environment variable is not set")
-		return nil, errors.New("GOOGLE_GENAI_API_KEY not set in environment")
-	}
-	log.Printf("[ExtractPostitNotes] API key found, length: %d", len(apiKey))
+// Edge directions
+const (
+	DirectionForward  = "forward"  // arrow points at the target
+	DirectionBackward = "backward" // arrow points at the source
+	DirectionBoth     = "both"     // arrow heads at both ends
+	DirectionNone     = "none"     // plain line
+)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-	log.Printf("[ExtractPostitNotes] Created context with 5-minute timeout")
+// Edge is a line or arrow drawn between two notes
+type Edge struct {
+	Source    int    `json:"source"` // index of the note in Extraction.Notes
+	Target    int    `json:"target"`
+	Direction string `json:"direction"`
+	Label     string `json:"label,omitempty"`
+}
 
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+// Extraction is everything extracted from one image
+type Extraction struct {
+	Notes []ExtractPostitNotesOutput `json:"notes"`
+	Edges []Edge                     `json:"edges"`
+
+	// Fallback is why the configured backend failed, when the notes come
+	// from colour detection instead (see WithDetectorFallback)
+	Fallback string `json:"fallback,omitempty"`
+}
+
+// DefaultGeminiModel is the Gemini model used when no model is configured.
+const DefaultGeminiModel = "gemini-2.5-flash-preview-05-20"
+
+// extractionPrompt is the instruction sent alongside the image to every backend.
+const extractionPrompt = `Analyze <image> for post-it notes. Extract content, color, size, and precise top-left pixel location ('x','y'). Relative positioning and size matter, but location ('x','y') is key.
+
+NB: The relative location of the notes within the image frame is important.
+
+Also find lines and arrows drawn between notes. Report each as an edge between the two notes it connects, using their 0-based positions in the "notes" array. "direction" is "forward" if the arrow head is at the target, "backward" if it is at the source, "both" for a double-headed arrow and "none" for a plain line. "label" is any text written on the line, or "".
+
+Return a JSON object with this structure:
+{
+  "notes": [
+    {
+      "background_color": "<hex_code>",
+      "location": {"x": <pixel>, "y": <pixel>}, // Top-left corner
+      "scale": <float>,
+      "size": {"height": <pixel>, "width": <pixel>},
+      "state": "<string>",
+      "text": "<extracted_text>",
+      "widget_type": "Note"
+    }
+  ],
+  "edges": [
+    {"source": <note_index>, "target": <note_index>, "direction": "forward|backward|both|none", "label": "<text>"}
+  ]
+}`
+
+// ExtractPostitNotes extracts notes from an image using the extractor
+// configured in the environment (see ConfigFromEnv). If it fails, notes are
+// found by colour detection instead, without their text.
+func ExtractPostitNotes(input ExtractPostitNotesInput) (*Extraction, error) {
+	extractor, err := NewExtractor(ConfigFromEnv())
 	if err != nil {
-		log.Printf("[ExtractPostitNotes] Failed to create Gemini client: %v", err)
 		return nil, err
 	}
-	defer client.Close()
-	log.Printf("[ExtractPostitNotes] Successfully created Gemini client")
+	return WithDetectorFallback(extractor).Extract(context.Background(), input)
+}
 
-	log.Printf("[ExtractPostitNotes] Processing image data, size: %d bytes", len(input.ImageData))
+// GeminiExtractor extracts notes using Google Gemini.
+type GeminiExtractor struct {
+	APIKey string
+	Model  string
+}
 
-	// Use controlled generation with responseSchema and responseMimeType
-	config := &genai.GenerationConfig{
-		ResponseMIMEType: "application/json",
-		ResponseSchema: &genai.Schema{
+func init() {
+	Register("gemini", func(cfg ExtractorConfig) (Extractor, error) {
+		apiKey := cfg.APIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("GOOGLE_GENAI_API_KEY")
+		}
+		model := cfg.Model
+		if model == "" {
+			model = DefaultGeminiModel
+		}
+		return &GeminiExtractor{APIKey: apiKey, Model: model}, nil
+	})
+}
+
+// Name returns the registered backend name.
+func (g *GeminiExtractor) Name() string { return "gemini" }
+
+// notesSchema is the response schema for Extract
+var notesSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"notes": {
 			Type: genai.TypeArray,
 			Items: &genai.Schema{
 				Type: genai.TypeObject,
@@ -85,75 +151,176 @@ func ExtractPostitNotes(input ExtractPostitNotesInput) ([]ExtractPostitNotesOutp
 				Required: []string{"background_color", "location", "scale", "size", "text", "widget_type"},
 			},
 		},
+		"edges": {
+			Type: genai.TypeArray,
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"source":    {Type: genai.TypeInteger},
+					"target":    {Type: genai.TypeInteger},
+					"direction": {Type: genai.TypeString, Format: "enum", Enum: []string{DirectionForward, DirectionBackward, DirectionBoth, DirectionNone}},
+					"label":     {Type: genai.TypeString},
+				},
+				Required: []string{"source", "target", "direction"},
+			},
+		},
+	},
+	Required: []string{"notes", "edges"},
+}
+
+// textsSchema is the response schema for ReadText
+var textsSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"texts": {Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}},
+	},
+	Required: []string{"texts"},
+}
+
+// Extract extracts notes from an image using Google Gemini.
+func (g *GeminiExtractor) Extract(ctx context.Context, input ExtractPostitNotesInput) (*Extraction, error) {
+	log.Printf("[ExtractPostitNotes] Processing image data, size: %d bytes", len(input.ImageData))
+	texts, err := g.generate(ctx, notesSchema, genai.Text(extractionPrompt), imagePart(input))
+	if err != nil {
+		return nil, err
 	}
-	log.Printf("[ExtractPostitNotes] Created generation config with JSON schema")
 
-	model := client.GenerativeModel("gemini-2.5-flash-preview-05-20")
-	model.GenerationConfig = *config
-	log.Printf("[ExtractPostitNotes] Created model with config")
+	// Parse the JSON object from the LLM response
+	for _, txt := range texts {
+		log.Printf("[ExtractPostitNotes] Extracted JSON string: %s", extractJSONFromMarkdown(txt))
+		if extraction, err := parseExtraction(txt); err == nil && len(extraction.Notes) > 0 {
+			log.Printf("[ExtractPostitNotes] Successfully parsed %d notes and %d edges from response", len(extraction.Notes), len(extraction.Edges))
+			return extraction, nil
+		} else {
+			log.Printf("[ExtractPostitNotes] Failed to parse JSON or no notes found: %v", err)
+		}
+	}
+	log.Printf("[ExtractPostitNotes] No valid JSON found in LLM response")
+	return nil, errors.New("No valid JSON found in LLM response")
+}
 
-	prompt := `Analyze <image> for post-it notes. Extract content, color, size, and precise top-left pixel location ('x','y'). Relative positioning and size matter, but location ('x','y') is key.
+// ReadText reads the text of cropped notes, all in one request.
+func (g *GeminiExtractor) ReadText(ctx context.Context, notes []ExtractPostitNotesInput) ([]string, error) {
+	log.Printf("[ReadText] Reading text of %d notes with %s", len(notes), g.Model)
+	parts := []genai.Part{genai.Text(textPrompt(len(notes)))}
+	for _, n := range notes {
+		parts = append(parts, imagePart(n))
+	}
+	texts, err := g.generate(ctx, textsSchema, parts...)
+	if err != nil {
+		return nil, err
+	}
+	for _, txt := range texts {
+		result, err := parseTexts(txt, len(notes))
+		if err == nil {
+			return result, nil
+		}
+		log.Printf("[ReadText] Failed to parse note texts: %v", err)
+	}
+	return nil, errors.New("No valid JSON found in LLM response")
+}
 
-NB: The relative location of the notes within the image frame is important.
+// imagePart converts an image to a Gemini request part
+func imagePart(input ExtractPostitNotesInput) genai.Part {
+	// Extract format from MIME type (e.g., "image/png" -> "png")
+	format := strings.TrimPrefix(input.MimeType, "image/")
+	return genai.ImageData(format, input.ImageData)
+}
 
-Return JSON array. Each object structure:
-{
-  "background_color": "<hex_code>",
-  "location": {"x": <pixel>, "y": <pixel>}, // Top-left corner
-  "scale": <float>,
-  "size": {"height": <pixel>, "width": <pixel>},
-  "state": "<string>",
-  "text": "<extracted_text>",
-  "widget_type": "Note"
-}`
+// generate sends parts to the model with the response constrained to schema,
+// and returns the text parts of the response
+func (g *GeminiExtractor) generate(ctx context.Context, schema *genai.Schema, parts ...genai.Part) ([]string, error) {
+	apiKey := g.APIKey
+	if apiKey == "" {
+		log.Printf("[ExtractPostitNotes] GOOGLE_GENAI_API_KEY environment variable is not set")
+		return nil, errors.New("GOOGLE_GENAI_API_KEY not set in environment")
+	}
+	log.Printf("[ExtractPostitNotes] API key found, length: %d", len(apiKey))
 
-	// Create content parts with the prompt and image data
-	log.Printf("[ExtractPostitNotes] Using MIME type: '%s'", input.MimeType)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
 
-	// Extract format from MIME type (e.g., "image/png" -> "png")
-	format := input.MimeType
-	if strings.HasPrefix(format, "image/") {
-		format = strings.TrimPrefix(format, "image/")
+	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+	if err != nil {
+		log.Printf("[ExtractPostitNotes] Failed to create Gemini client: %v", err)
+		return nil, err
 	}
-	log.Printf("[ExtractPostitNotes] Using format for ImageData: '%s'", format)
+	defer client.Close()
 
-	parts := []genai.Part{
-		genai.Text(prompt),
-		genai.ImageData(format, input.ImageData),
+	// Use controlled generation with responseSchema and responseMimeType
+	model := client.GenerativeModel(g.Model)
+	model.GenerationConfig = genai.GenerationConfig{
+		ResponseMIMEType: "application/json",
+		ResponseSchema:   schema,
 	}
-	log.Printf("[ExtractPostitNotes] Created parts with prompt and image data")
+	log.Printf("[ExtractPostitNotes] Created model %s with JSON schema", g.Model)
 
 	resp, err := model.GenerateContent(ctx, parts...)
 	if err != nil {
 		log.Printf("[ExtractPostitNotes] Failed to generate content: %v", err)
 		return nil, err
 	}
-	log.Printf("[ExtractPostitNotes] Successfully generated content from model")
-
 	// Log the full raw LLM response for debugging
 	respJson, _ := json.MarshalIndent(resp, "", "  ")
 	log.Printf("[ExtractPostitNotes] Raw LLM response: %s", string(respJson))
 
-	// Parse the JSON array from the LLM response
-	var outputs []ExtractPostitNotesOutput
+	var texts []string
 	for _, c := range resp.Candidates {
 		if c.Content != nil {
 			for _, part := range c.Content.Parts {
 				if txt, ok := part.(genai.Text); ok {
-					jsonStr := extractJSONFromMarkdown(string(txt))
-					log.Printf("[ExtractPostitNotes] Extracted JSON string: %s", jsonStr)
-					if err := json.Unmarshal([]byte(jsonStr), &outputs); err == nil && len(outputs) > 0 {
-						log.Printf("[ExtractPostitNotes] Successfully parsed %d notes from response", len(outputs))
-						return outputs, nil
-					} else {
-						log.Printf("[ExtractPostitNotes] Failed to parse JSON or no notes found: %v", err)
-					}
+					texts = append(texts, string(txt))
 				}
 			}
 		}
 	}
-	log.Printf("[ExtractPostitNotes] No valid JSON array found in LLM response")
-	return nil, errors.New("No valid JSON array found in LLM response")
+	return texts, nil
+}
+
+// parseExtraction parses a model response: an object with "notes" and
+// "edges", or a bare array of notes from models that ignore the edges.
+// Edges are normalised so they always point from source to target.
+func parseExtraction(content string) (*Extraction, error) {
+	jsonStr := extractJSONFromMarkdown(content)
+	var extraction Extraction
+	var notes []ExtractPostitNotesOutput
+	if err := json.Unmarshal([]byte(jsonStr), &notes); err == nil {
+		extraction.Notes = notes
+	} else if err := json.Unmarshal([]byte(jsonStr), &extraction); err != nil {
+		return nil, err
+	}
+	extraction.Edges = NormalizeEdges(extraction.Edges, len(extraction.Notes))
+	return &extraction, nil
+}
+
+// NormalizeEdges drops edges that do not join two different known notes or
+// that repeat an earlier edge, and turns backward edges around
+func NormalizeEdges(edges []Edge, notes int) []Edge {
+	out := []Edge{}
+	seen := map[[2]int]bool{}
+	for _, e := range edges {
+		if e.Source < 0 || e.Target < 0 || e.Source >= notes || e.Target >= notes || e.Source == e.Target {
+			log.Printf("[NormalizeEdges] Dropping edge %d->%d: not between two of the %d notes", e.Source, e.Target, notes)
+			continue
+		}
+		switch e.Direction {
+		case DirectionBackward:
+			e.Source, e.Target, e.Direction = e.Target, e.Source, DirectionForward
+		case DirectionForward, DirectionBoth, DirectionNone:
+		default:
+			e.Direction = DirectionNone
+		}
+		key := [2]int{e.Source, e.Target}
+		if e.Direction != DirectionForward && key[0] > key[1] {
+			key[0], key[1] = key[1], key[0] // undirected, so either order is the same edge
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, e)
+	}
+	return out
 }
 
 // extractJSONFromMarkdown extracts JSON from a Markdown code block if present.
@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Extractor extracts Post-it notes, and the lines between them, from an image.
+// Implementations are registered by name and selected via ExtractorConfig.Provider.
+type Extractor interface {
+	Name() string
+	Extract(ctx context.Context, input ExtractPostitNotesInput) (*Extraction, error)
+}
+
+// ExtractorConfig selects and configures an extraction backend.
+type ExtractorConfig struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+	APIKey   string `json:"apiKey,omitempty"`
+	BaseURL  string `json:"baseURL,omitempty"`
+}
+
+// ExtractorFactory builds an Extractor from its configuration.
+type ExtractorFactory func(cfg ExtractorConfig) (Extractor, error)
+
+// DefaultProvider is used when no provider is configured.
+const DefaultProvider = "gemini"
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]ExtractorFactory{}
+)
+
+// Register makes an extraction backend available under the given name.
+func Register(name string, factory ExtractorFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[strings.ToLower(name)] = factory
+}
+
+// Providers returns the names of all registered backends.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewExtractor builds the extractor for cfg.Provider.
+func NewExtractor(cfg ExtractorConfig) (Extractor, error) {
+	provider := strings.ToLower(strings.TrimSpace(cfg.Provider))
+	if provider == "" {
+		provider = DefaultProvider
+	}
+	registryMu.RLock()
+	factory, ok := registry[provider]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown LLM provider %q (available: %s)", provider, strings.Join(Providers(), ", "))
+	}
+	return factory(cfg)
+}
+
+// ConfigFromEnv reads the extractor configuration from
+// LLM_PROVIDER, LLM_MODEL, LLM_API_KEY and LLM_BASE_URL.
+func ConfigFromEnv() ExtractorConfig {
+	return ExtractorConfig{
+		Provider: os.Getenv("LLM_PROVIDER"),
+		Model:    os.Getenv("LLM_MODEL"),
+		APIKey:   os.Getenv("LLM_API_KEY"),
+		BaseURL:  os.Getenv("LLM_BASE_URL"),
+	}
+}
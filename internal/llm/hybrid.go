@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/image"
+)
+
+// Extraction modes
+const (
+	ModeFull   = "full"   // the model finds the notes and reads them from the whole image
+	ModeHybrid = "hybrid" // notes are found by colour detection and the model only reads their text
+)
+
+// DefaultTextBatchSize is how many note crops go to the model in one request
+const DefaultTextBatchSize = 8
+
+// TextReader is implemented by backends that can read the text of notes
+// cropped from the photo. It returns one text per note, in order.
+type TextReader interface {
+	ReadText(ctx context.Context, notes []ExtractPostitNotesInput) ([]string, error)
+}
+
+// textPrompt asks for the text of n cropped notes
+func textPrompt(n int) string {
+	return fmt.Sprintf(`Each of the %d images is a single Post-it note cropped from a photo of a board. Read the handwritten or printed text on each note, in the order the images are given. Keep line breaks as "\n". Use "" for a note without readable text.
+
+Return a JSON object with exactly %d texts: {"texts": ["<text of image 1>", "<text of image 2>", ...]}`, n, n)
+}
+
+// parseTexts parses a ReadText response: an object with "texts" or a bare
+// array. Missing texts are left empty and extra ones dropped.
+func parseTexts(content string, n int) ([]string, error) {
+	jsonStr := extractJSONFromMarkdown(content)
+	var texts []string
+	if err := json.Unmarshal([]byte(jsonStr), &texts); err != nil {
+		var obj struct {
+			Texts []string `json:"texts"`
+		}
+		if err := json.Unmarshal([]byte(jsonStr), &obj); err != nil {
+			return nil, err
+		}
+		texts = obj.Texts
+	}
+	if len(texts) != n {
+		log.Printf("[parseTexts] Expected %d texts, got %d", n, len(texts))
+	}
+	out := make([]string, n)
+	copy(out, texts)
+	return out, nil
+}
+
+// HybridExtractor finds notes by colour detection, which gives accurate
+// geometry, and sends crops of the notes to a model in batches to read
+// their text. Edges are not found in this mode.
+type HybridExtractor struct {
+	Reader    TextReader
+	Backend   string // name of the backend reading the text
+	Options   image.DetectOptions
+	BatchSize int // defaults to DefaultTextBatchSize
+}
+
+// ErrInvalidMode is wrapped by WithMode errors for modes e cannot run in
+var ErrInvalidMode = errors.New("invalid mode")
+
+// WithMode returns the extractor for an extraction mode: e itself for
+// ModeFull (or ""), and a HybridExtractor reading text with e for
+// ModeHybrid. A detector fallback around e is kept around the result.
+func WithMode(e Extractor, mode string) (Extractor, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", ModeFull:
+		return e, nil
+	case ModeHybrid:
+	default:
+		return nil, fmt.Errorf("%w: unknown extraction mode %q (use %q or %q)", ErrInvalidMode, mode, ModeFull, ModeHybrid)
+	}
+	switch v := e.(type) {
+	case *HybridExtractor:
+		return v, nil
+	case fallbackExtractor:
+		hybrid, err := WithMode(v.primary, mode)
+		if err != nil {
+			return nil, err
+		}
+		return WithDetectorFallback(hybrid), nil
+	}
+	reader, ok := e.(TextReader)
+	if !ok {
+		return nil, fmt.Errorf("%w: the %s backend cannot read note text, so it does not support %s mode", ErrInvalidMode, e.Name(), ModeHybrid)
+	}
+	return &HybridExtractor{Reader: reader, Backend: e.Name()}, nil
+}
+
+// Name returns the mode and the backend reading the text.
+func (h *HybridExtractor) Name() string { return ModeHybrid + "+" + h.Backend }
+
+// Extract detects the notes, then reads their text batch by batch.
+func (h *HybridExtractor) Extract(ctx context.Context, input ExtractPostitNotesInput) (*Extraction, error) {
+	crops, err := image.DetectNoteCrops(input.ImageData, h.Options)
+	if err != nil {
+		return nil, err
+	}
+	batchSize := h.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultTextBatchSize
+	}
+	log.Printf("[HybridExtractor] Detected %d notes, reading text with %s in batches of %d", len(crops), h.Backend, batchSize)
+
+	notes := make([]ExtractPostitNotesOutput, len(crops))
+	for i, c := range crops {
+		notes[i] = detectedNote(c.Note)
+	}
+	for start := 0; start < len(crops); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		batch := crops[start:min(start+batchSize, len(crops))]
+		inputs := make([]ExtractPostitNotesInput, len(batch))
+		for i, c := range batch {
+			inputs[i] = ExtractPostitNotesInput{ImageData: c.Data, MimeType: c.MimeType}
+		}
+		texts, err := h.Reader.ReadText(ctx, inputs)
+		if err != nil {
+			return nil, fmt.Errorf("reading text of notes %d-%d: %w", start+1, start+len(batch), err)
+		}
+		for i, text := range texts {
+			notes[start+i].Text = text
+		}
+	}
+	return &Extraction{Notes: notes, Edges: []Edge{}}, nil
+}
@@ -1,5 +1,14 @@
 package llm
 
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
 // Note represents a detected note in raw image pixel coordinates
 // The image size must be provided separately for scaling.
 type Note struct {
@@ -11,21 +20,68 @@ type Note struct {
 	Scale   float64 // scale of the note (to match anchor scale)
 }
 
-// AnalyzeImage returns mock note data and the image size (width, height).
-func AnalyzeImage(imageData []byte) ([]Note, int, int) {
+// MockExtractor returns deterministic note data without calling any model.
+// Notes are placed at the four corners and centre of the image, so the
+// whole upload -> scan -> create flow can be exercised offline.
+type MockExtractor struct{}
+
+func init() {
+	Register("mock", func(cfg ExtractorConfig) (Extractor, error) {
+		return MockExtractor{}, nil
+	})
+}
+
+// Name returns the registered backend name.
+func (MockExtractor) Name() string { return "mock" }
+
+// Extract returns five fixed notes laid out relative to the image size, with
+// an arrow from the top left note to the centre and a line down the right side.
+// Images that cannot be decoded are treated as 1280x720.
+func (MockExtractor) Extract(ctx context.Context, input ExtractPostitNotesInput) (*Extraction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	imgW, imgH := 1280, 720 // mock image size
-	return []Note{
-		// Top-left
-		{Content: "Top Left", Color: "#FF0000", X: 0, Y: 0, Width: 200, Height: 200},
-		// Top-right
-		{Content: "Top Right", Color: "#00FF00", X: 1080, Y: 0, Width: 200, Height: 200},
-		// Bottom-left
-		{Content: "Bottom Left", Color: "#0000FF", X: 0, Y: 520, Width: 200, Height: 200},
-		// Bottom-right
-		{Content: "Bottom Right", Color: "#FFFF00", X: 1080, Y: 520, Width: 200, Height: 200},
-		// Center
-		{Content: "Center", Color: "#800080", X: 540, Y: 260, Width: 200, Height: 200},
-	}, imgW, imgH
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(input.ImageData)); err == nil {
+		imgW, imgH = cfg.Width, cfg.Height
+	}
+	size := min(imgW, imgH) / 4
+	note := func(text, color string, x, y int) ExtractPostitNotesOutput {
+		return ExtractPostitNotesOutput{
+			BackgroundColor: color,
+			Location:        map[string]int{"x": x, "y": y},
+			Scale:           1,
+			Size:            map[string]int{"width": size, "height": size},
+			State:           "normal",
+			Text:            text,
+			WidgetType:      "Note",
+		}
+	}
+	return &Extraction{
+		Notes: []ExtractPostitNotesOutput{
+			note("Top Left", "#FF0000", 0, 0),
+			note("Top Right", "#00FF00", imgW-size, 0),
+			note("Bottom Left", "#0000FF", 0, imgH-size),
+			note("Bottom Right", "#FFFF00", imgW-size, imgH-size),
+			note("Center", "#800080", (imgW-size)/2, (imgH-size)/2),
+		},
+		Edges: []Edge{
+			{Source: 0, Target: 4, Direction: DirectionForward, Label: "leads to"},
+			{Source: 1, Target: 3, Direction: DirectionNone},
+		},
+	}, nil
+}
+
+// ReadText names each note by its position, "Note 1", "Note 2", ...
+func (MockExtractor) ReadText(ctx context.Context, notes []ExtractPostitNotesInput) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	texts := make([]string, len(notes))
+	for i := range notes {
+		texts[i] = fmt.Sprintf("Note %d", i+1)
+	}
+	return texts, nil
 }
 
 // Re-export ExtractPostitNotes and its types for use by other packages
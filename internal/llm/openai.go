@@ -0,0 +1,260 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultOpenAIBaseURL is used when no base URL is configured.
+	DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+	// DefaultOpenAIModel is used when no model is configured.
+	DefaultOpenAIModel = "gpt-4o"
+)
+
+// OpenAIExtractor extracts notes using any server implementing the
+// OpenAI /v1/chat/completions API with image input (OpenAI, vLLM, llama.cpp, ...).
+type OpenAIExtractor struct {
+	BaseURL string
+	Model   string
+	APIKey  string // optional for local servers
+	HTTP    *http.Client
+}
+
+func init() {
+	Register("openai", func(cfg ExtractorConfig) (Extractor, error) {
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = DefaultOpenAIBaseURL
+		}
+		model := cfg.Model
+		if model == "" {
+			model = DefaultOpenAIModel
+		}
+		return &OpenAIExtractor{
+			BaseURL: baseURL,
+			Model:   model,
+			APIKey:  cfg.APIKey,
+			HTTP:    &http.Client{Timeout: 5 * time.Minute},
+		}, nil
+	})
+}
+
+// notesJSONSchema mirrors the genai.Schema used by the Gemini backend.
+var notesJSONSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"notes": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"background_color": map[string]interface{}{"type": "string"},
+					"location": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"x": map[string]interface{}{"type": "integer"},
+							"y": map[string]interface{}{"type": "integer"},
+						},
+						"required":             []string{"x", "y"},
+						"additionalProperties": false,
+					},
+					"scale": map[string]interface{}{"type": "number"},
+					"size": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"height": map[string]interface{}{"type": "integer"},
+							"width":  map[string]interface{}{"type": "integer"},
+						},
+						"required":             []string{"height", "width"},
+						"additionalProperties": false,
+					},
+					"text":        map[string]interface{}{"type": "string"},
+					"widget_type": map[string]interface{}{"type": "string"},
+				},
+				"required":             []string{"background_color", "location", "scale", "size", "text", "widget_type"},
+				"additionalProperties": false,
+			},
+		},
+		"edges": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"source":    map[string]interface{}{"type": "integer"},
+					"target":    map[string]interface{}{"type": "integer"},
+					"direction": map[string]interface{}{"type": "string", "enum": []string{DirectionForward, DirectionBackward, DirectionBoth, DirectionNone}},
+					"label":     map[string]interface{}{"type": "string"},
+				},
+				"required":             []string{"source", "target", "direction", "label"},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             []string{"notes", "edges"},
+	"additionalProperties": false,
+}
+
+// textsJSONSchema is the response schema for ReadText
+var textsJSONSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"texts": map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"type": "string"},
+		},
+	},
+	"required":             []string{"texts"},
+	"additionalProperties": false,
+}
+
+// Name returns the registered backend name.
+func (o *OpenAIExtractor) Name() string { return "openai" }
+
+// endpoint returns the chat completions URL, accepting base URLs with or without /v1.
+func (o *OpenAIExtractor) endpoint() string {
+	base := strings.TrimRight(o.BaseURL, "/")
+	if strings.HasSuffix(base, "/chat/completions") {
+		return base
+	}
+	if !strings.HasSuffix(base, "/v1") {
+		base += "/v1"
+	}
+	return base + "/chat/completions"
+}
+
+// Extract extracts notes from an image using an OpenAI-compatible server.
+func (o *OpenAIExtractor) Extract(ctx context.Context, input ExtractPostitNotesInput) (*Extraction, error) {
+	log.Printf("[OpenAIExtractor] Sending %d bytes (%s) to %s, model=%s", len(input.ImageData), input.MimeType, o.endpoint(), o.Model)
+	contents, err := o.complete(ctx, []map[string]interface{}{
+		{"type": "text", "text": extractionPrompt},
+		imageContent(input),
+	}, "postit_notes", notesJSONSchema)
+	if err != nil {
+		return nil, err
+	}
+	for _, content := range contents {
+		extraction, err := parseExtraction(content)
+		if err == nil && len(extraction.Notes) > 0 {
+			log.Printf("[OpenAIExtractor] Successfully parsed %d notes and %d edges from response", len(extraction.Notes), len(extraction.Edges))
+			return extraction, nil
+		}
+		log.Printf("[OpenAIExtractor] Failed to parse JSON or no notes found: %v", err)
+	}
+	return nil, errors.New("No valid JSON found in LLM response")
+}
+
+// ReadText reads the text of cropped notes, all in one request.
+func (o *OpenAIExtractor) ReadText(ctx context.Context, notes []ExtractPostitNotesInput) ([]string, error) {
+	log.Printf("[OpenAIExtractor] Reading text of %d notes with %s, model=%s", len(notes), o.endpoint(), o.Model)
+	content := []map[string]interface{}{{"type": "text", "text": textPrompt(len(notes))}}
+	for _, n := range notes {
+		content = append(content, imageContent(n))
+	}
+	contents, err := o.complete(ctx, content, "note_texts", textsJSONSchema)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range contents {
+		texts, err := parseTexts(c, len(notes))
+		if err == nil {
+			return texts, nil
+		}
+		log.Printf("[OpenAIExtractor] Failed to parse note texts: %v", err)
+	}
+	return nil, errors.New("No valid JSON found in LLM response")
+}
+
+// imageContent is a chat message part holding an image as a data URL
+func imageContent(input ExtractPostitNotesInput) map[string]interface{} {
+	mimeType := input.MimeType
+	if mimeType == "" {
+		mimeType = "image/png"
+	}
+	dataURL := "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(input.ImageData)
+	return map[string]interface{}{"type": "image_url", "image_url": map[string]string{"url": dataURL}}
+}
+
+// complete sends one user message and returns the content of each choice,
+// constrained to the JSON schema
+func (o *OpenAIExtractor) complete(ctx context.Context, content []map[string]interface{}, schemaName string, schema map[string]interface{}) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	payload := map[string]interface{}{
+		"model": o.Model,
+		"messages": []map[string]interface{}{
+			{
+				"role":    "user",
+				"content": content,
+			},
+		},
+		"response_format": map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   schemaName,
+				"strict": true,
+				"schema": schema,
+			},
+		},
+		"temperature": 0,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.endpoint(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.APIKey)
+	}
+
+	client := o.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("[OpenAIExtractor] Request failed: %v", err)
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Printf("[OpenAIExtractor] Server returned %d: %s", resp.StatusCode, string(respBody))
+		return nil, fmt.Errorf("LLM server error %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	log.Printf("[OpenAIExtractor] Raw LLM response: %s", string(respBody))
+
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(respBody, &completion); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	contents := make([]string, len(completion.Choices))
+	for i, choice := range completion.Choices {
+		contents[i] = choice.Message.Content
+	}
+	return contents, nil
+}
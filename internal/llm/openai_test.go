@@ -0,0 +1,178 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// chatServer is a stand-in for an OpenAI-compatible server. It answers every
+// request with status and, for 2xx, a single choice holding content.
+func chatServer(t *testing.T, status int, content string, requests *[]map[string]interface{}) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("request body is not JSON: %v", err)
+		}
+		body["authorization"] = r.Header.Get("Authorization")
+		if requests != nil {
+			*requests = append(*requests, body)
+		}
+		if status < 200 || status >= 300 {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"error":{"message":"model overloaded"}}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": content}}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOpenAIExtractorRequest(t *testing.T) {
+	var requests []map[string]interface{}
+	srv := chatServer(t, http.StatusOK, `{"notes":[],"edges":[]}`, &requests)
+	o := &OpenAIExtractor{BaseURL: srv.URL, Model: "test-model", APIKey: "secret", HTTP: srv.Client()}
+
+	image := []byte{0x89, 'P', 'N', 'G'}
+	o.Extract(context.Background(), ExtractPostitNotesInput{ImageData: image, MimeType: "image/png"})
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	req := requests[0]
+	if req["model"] != "test-model" || req["authorization"] != "Bearer secret" {
+		t.Errorf("got model %v authorization %v", req["model"], req["authorization"])
+	}
+
+	messages := req["messages"].([]interface{})
+	content := messages[0].(map[string]interface{})["content"].([]interface{})
+	if len(content) != 2 {
+		t.Fatalf("got %d content parts, want text and image", len(content))
+	}
+	if text := content[0].(map[string]interface{}); text["type"] != "text" || text["text"] != extractionPrompt {
+		t.Errorf("first part is not the extraction prompt: %v", text)
+	}
+	part := content[1].(map[string]interface{})
+	wantURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(image)
+	if url := part["image_url"].(map[string]interface{})["url"]; part["type"] != "image_url" || url != wantURL {
+		t.Errorf("got image part %v, want data URL %s", part, wantURL)
+	}
+
+	format := req["response_format"].(map[string]interface{})
+	schema := format["json_schema"].(map[string]interface{})
+	if format["type"] != "json_schema" || schema["name"] != "postit_notes" || schema["strict"] != true {
+		t.Errorf("got response_format %v", format)
+	}
+	got, _ := json.Marshal(schema["schema"])
+	want, _ := json.Marshal(notesJSONSchema)
+	if string(got) != string(want) {
+		t.Errorf("got schema %s, want %s", got, want)
+	}
+}
+
+func TestOpenAIExtractorBaseURLs(t *testing.T) {
+	for _, suffix := range []string{"", "/", "/v1", "/v1/chat/completions"} {
+		srv := chatServer(t, http.StatusOK, `[{"text":"a","location":{"x":1,"y":2},"size":{"width":3,"height":4}}]`, nil)
+		o := &OpenAIExtractor{BaseURL: srv.URL + suffix, HTTP: srv.Client()}
+		if _, err := o.Extract(context.Background(), ExtractPostitNotesInput{ImageData: []byte{1}}); err != nil {
+			t.Errorf("base URL %q: %v", suffix, err)
+		}
+	}
+}
+
+func TestOpenAIExtractorReplies(t *testing.T) {
+	const note = `{"background_color":"#FFFF88","location":{"x":10,"y":20},"scale":1,"size":{"width":100,"height":90},"text":"Hello","widget_type":"Note"}`
+	tests := []struct {
+		name    string
+		content string
+		notes   int
+		edges   []Edge
+	}{
+		{"object", `{"notes":[` + note + `],"edges":[]}`, 1, []Edge{}},
+		{"fenced json", "```json\n{\"notes\":[" + note + "," + note + "],\"edges\":[{\"source\":1,\"target\":0,\"direction\":\"backward\",\"label\":\"\"}]}\n```", 2,
+			[]Edge{{Source: 0, Target: 1, Direction: DirectionForward}}},
+		{"fenced without language", "```\n[" + note + "]\n```", 1, []Edge{}},
+		{"bare array", "[" + note + "," + note + "," + note + "]", 3, []Edge{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := chatServer(t, http.StatusOK, tt.content, nil)
+			o := &OpenAIExtractor{BaseURL: srv.URL, HTTP: srv.Client()}
+			extraction, err := o.Extract(context.Background(), ExtractPostitNotesInput{ImageData: []byte{1}})
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			if len(extraction.Notes) != tt.notes {
+				t.Fatalf("got %d notes, want %d", len(extraction.Notes), tt.notes)
+			}
+			n := extraction.Notes[0]
+			if n.Text != "Hello" || n.BackgroundColor != "#FFFF88" || n.Location["x"] != 10 || n.Location["y"] != 20 || n.Size["width"] != 100 || n.Size["height"] != 90 {
+				t.Errorf("got note %+v", n)
+			}
+			if len(extraction.Edges) != len(tt.edges) {
+				t.Fatalf("got edges %+v, want %+v", extraction.Edges, tt.edges)
+			}
+			for i, e := range tt.edges {
+				if got := extraction.Edges[i]; got.Source != e.Source || got.Target != e.Target || got.Direction != e.Direction {
+					t.Errorf("edge %d: got %+v, want %+v", i, got, e)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAIExtractorErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		content string
+		want    string
+	}{
+		{"unauthorized", http.StatusUnauthorized, "", "LLM server error 401"},
+		{"rate limited", http.StatusTooManyRequests, "", "LLM server error 429"},
+		{"server error", http.StatusInternalServerError, "", "model overloaded"},
+		{"not JSON", http.StatusOK, "I could not find any notes.", "No valid JSON"},
+		{"no notes", http.StatusOK, `{"notes":[],"edges":[]}`, "No valid JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := chatServer(t, tt.status, tt.content, nil)
+			o := &OpenAIExtractor{BaseURL: srv.URL, HTTP: srv.Client()}
+			_, err := o.Extract(context.Background(), ExtractPostitNotesInput{ImageData: []byte{1}})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		srv := chatServer(t, http.StatusOK, "", nil)
+		srv.Close()
+		o := &OpenAIExtractor{BaseURL: srv.URL, HTTP: srv.Client()}
+		if _, err := o.Extract(context.Background(), ExtractPostitNotesInput{ImageData: []byte{1}}); err == nil {
+			t.Error("got no error from a closed server")
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		srv := chatServer(t, http.StatusOK, "[]", nil)
+		o := &OpenAIExtractor{BaseURL: srv.URL, HTTP: srv.Client()}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := o.Extract(ctx, ExtractPostitNotesInput{ImageData: []byte{1}}); !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, want context.Canceled", err)
+		}
+	})
+}
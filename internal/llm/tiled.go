@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/image"
+)
+
+// DefaultTileWorkers is how many tiles are extracted at once
+const DefaultTileWorkers = 4
+
+// TileResult is the extraction of one tile, with note locations already
+// translated to the full image
+type TileResult struct {
+	Tile       image.Tile
+	Extraction *Extraction
+}
+
+// ExtractTiles extracts every tile with e, up to workers at a time, and
+// moves the notes found into full image coordinates. progress, if set, is
+// called after each tile. The first failure cancels the remaining tiles.
+func ExtractTiles(ctx context.Context, e Extractor, tiles []image.Tile, workers int, progress func(done, total int)) ([]TileResult, error) {
+	if workers <= 0 {
+		workers = DefaultTileWorkers
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]TileResult, len(tiles))
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		done     int
+		sem      = make(chan struct{}, workers)
+	)
+	for i := range tiles {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			t := tiles[i]
+			extraction, err := e.Extract(ctx, ExtractPostitNotesInput{ImageData: t.Data, MimeType: t.MimeType})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Printf("[ExtractTiles] Tile %d at (%d,%d) failed: %v", i+1, t.X, t.Y, err)
+				if firstErr == nil {
+					firstErr = fmt.Errorf("tile %d: %w", i+1, err)
+				}
+				cancel()
+				return
+			}
+			for j := range extraction.Notes {
+				loc := extraction.Notes[j].Location
+				extraction.Notes[j].Location = map[string]int{"x": loc["x"] + t.X, "y": loc["y"] + t.Y}
+			}
+			results[i] = TileResult{Tile: t, Extraction: extraction}
+			done++
+			log.Printf("[ExtractTiles] Tile %d/%d at (%d,%d): %d notes", i+1, len(tiles), t.X, t.Y, len(extraction.Notes))
+			if progress != nil {
+				progress(done, len(tiles))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
@@ -0,0 +1,154 @@
+package mapping
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+)
+
+// PaletteColor is a named note color
+type PaletteColor struct {
+	Name  string `json:"name"`
+	Color string `json:"color"` // #RRGGBBAA, as MCS stores it
+	lab   lab
+}
+
+// Palette is the set of colors extracted note colors are snapped to
+type Palette []PaletteColor
+
+// DefaultPaletteColors are the standard Canvus note colors
+var DefaultPaletteColors = map[string]string{
+	"yellow": "#fff173ff",
+	"orange": "#ffb35cff",
+	"red":    "#ff7070ff",
+	"pink":   "#ff9ee0ff",
+	"purple": "#c59cffff",
+	"blue":   "#8cc4ffff",
+	"green":  "#a6e67eff",
+	"white":  "#ffffffff",
+}
+
+// NewPalette builds a palette from color names to hex codes (#RRGGBB or
+// #RRGGBBAA). An empty map gives the default palette.
+func NewPalette(colors map[string]string) (Palette, error) {
+	if len(colors) == 0 {
+		colors = DefaultPaletteColors
+	}
+	names := make([]string, 0, len(colors))
+	for name := range colors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	palette := make(Palette, 0, len(names))
+	for _, name := range names {
+		r, g, b, a, ok := parseHexColor(colors[name])
+		if !ok {
+			return nil, fmt.Errorf("palette color %q: invalid hex color %q", name, colors[name])
+		}
+		palette = append(palette, PaletteColor{
+			Name:  name,
+			Color: fmt.Sprintf("#%02x%02x%02x%02x", r, g, b, a),
+			lab:   toLab(r, g, b),
+		})
+	}
+	return palette, nil
+}
+
+// ColorMatch records how an extracted color was snapped to the palette
+type ColorMatch struct {
+	Raw      string  `json:"raw"`            // color as extracted
+	Color    string  `json:"color"`          // palette color used, or the raw color if it could not be parsed
+	Name     string  `json:"name,omitempty"` // palette color name
+	Distance float64 `json:"distance"`       // CIE76 ΔE between the two; around 2.3 is just noticeable
+}
+
+// Snap returns the palette color perceptually closest to a hex color. Colors
+// that cannot be parsed are kept as they are.
+func (p Palette) Snap(raw string) ColorMatch {
+	match := ColorMatch{Raw: raw, Color: raw}
+	r, g, b, _, ok := parseHexColor(raw)
+	if !ok || len(p) == 0 {
+		return match
+	}
+	c := toLab(r, g, b)
+	best := -1.0
+	for _, pc := range p {
+		if d := c.snapDistance(pc.lab); best < 0 || d < best {
+			best = d
+			match.Color, match.Name, match.Distance = pc.Color, pc.Name, c.distance(pc.lab)
+		}
+	}
+	return match
+}
+
+// SnapNoteColors sets each note's background color to the closest palette
+// color and returns the matches, by note index
+func SnapNoteColors(notes []canvusapi.Note, palette Palette) []ColorMatch {
+	matches := make([]ColorMatch, len(notes))
+	for i := range notes {
+		matches[i] = palette.Snap(notes[i].BackgroundColor)
+		notes[i].BackgroundColor = matches[i].Color
+	}
+	return matches
+}
+
+// parseHexColor parses #RRGGBB or #RRGGBBAA, with or without the #
+func parseHexColor(s string) (r, g, b, a uint8, ok bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(s) == 6 {
+		s += "ff"
+	}
+	if len(s) != 8 {
+		return 0, 0, 0, 0, false
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return 0, 0, 0, 0, false
+	}
+	return uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v), true
+}
+
+// lab is a color in CIE L*a*b* (D65 white point)
+type lab struct{ l, a, b float64 }
+
+// distance is the CIE76 color difference
+func (c lab) distance(o lab) float64 {
+	return math.Sqrt((c.l-o.l)*(c.l-o.l) + (c.a-o.a)*(c.a-o.a) + (c.b-o.b)*(c.b-o.b))
+}
+
+// snapDistance is the difference used to pick a palette color: CIE76 with
+// lightness differences halved, as lighting changes how light a note looks
+// in a photo far more than its hue. Otherwise a grey note is closer to blue
+// than to white.
+func (c lab) snapDistance(o lab) float64 {
+	dl := (c.l - o.l) / 2
+	return math.Sqrt(dl*dl + (c.a-o.a)*(c.a-o.a) + (c.b-o.b)*(c.b-o.b))
+}
+
+// toLab converts an sRGB color to L*a*b*
+func toLab(r, g, b uint8) lab {
+	linear := func(v uint8) float64 {
+		c := float64(v) / 255
+		if c <= 0.04045 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	lr, lg, lb := linear(r), linear(g), linear(b)
+	// sRGB to XYZ, normalised by the D65 white point
+	x := (0.4124564*lr + 0.3575761*lg + 0.1804375*lb) / 0.95047
+	y := 0.2126729*lr + 0.7151522*lg + 0.0721750*lb
+	z := (0.0193339*lr + 0.1191920*lg + 0.9503041*lb) / 1.08883
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return lab{l: 116*fy - 16, a: 500 * (fx - fy), b: 200 * (fy - fz)}
+}
@@ -0,0 +1,100 @@
+package mapping
+
+import (
+	"testing"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+)
+
+func TestPaletteSnap(t *testing.T) {
+	palette, err := NewPalette(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		raw  string
+		want string // palette color name; "" keeps the raw color
+	}{
+		{"washed-out yellow", "#E8D96A", "yellow"},
+		{"shadowed yellow", "#c8b850", "yellow"},
+		{"near pink", "#f7a1d9", "pink"},
+		{"near red", "#ff6f6f", "red"},
+		{"without #", "8cc4ff", "blue"},
+		{"with alpha", "#a6e67e80", "green"},
+		{"padded", " #ffb35c ", "orange"},
+		{"light grey", "#e0e0e0", "white"},
+		{"mid grey", "#808080", "white"},
+		{"empty", "", ""},
+		{"shorthand", "#fff", ""},
+		{"five digits", "#12345", ""},
+		{"not hex", "#gg0000", ""},
+		{"a name", "not-a-color", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := palette.Snap(tt.raw)
+			if got.Raw != tt.raw || got.Name != tt.want {
+				t.Fatalf("Snap(%q) = %+v, want %q", tt.raw, got, tt.want)
+			}
+			if tt.want == "" {
+				if got.Color != tt.raw || got.Distance != 0 {
+					t.Errorf("Snap(%q) = %+v, want the raw color kept", tt.raw, got)
+				}
+				return
+			}
+			if got.Color != DefaultPaletteColors[tt.want] {
+				t.Errorf("Snap(%q) color = %s, want %s", tt.raw, got.Color, DefaultPaletteColors[tt.want])
+			}
+		})
+	}
+
+	// Palette colors snap to themselves
+	for name, hex := range DefaultPaletteColors {
+		if got := palette.Snap(hex); got.Name != name || got.Distance > 1e-9 {
+			t.Errorf("Snap(%s) = %+v, want %s at distance 0", hex, got, name)
+		}
+	}
+	if got := (Palette{}).Snap("#E8D96A"); got.Color != "#E8D96A" || got.Name != "" {
+		t.Errorf("empty palette: got %+v, want the raw color kept", got)
+	}
+}
+
+func TestNewPalette(t *testing.T) {
+	palette, err := NewPalette(map[string]string{"sky": "#8CC4FF", "sun": "FFF17380"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sorted by name, stored as lowercase #RRGGBBAA
+	if len(palette) != 2 || palette[0].Name != "sky" || palette[0].Color != "#8cc4ffff" || palette[1].Color != "#fff17380" {
+		t.Errorf("got %+v", palette)
+	}
+	for _, hex := range []string{"", "#fff", "blue"} {
+		if _, err := NewPalette(map[string]string{"bad": hex}); err == nil {
+			t.Errorf("got no error for %q", hex)
+		}
+	}
+}
+
+func TestSnapNoteColors(t *testing.T) {
+	palette, _ := NewPalette(nil)
+	notes := []canvusapi.Note{
+		{BackgroundColor: "#E8D96A"},
+		{BackgroundColor: "not-a-color"},
+		{BackgroundColor: "#f7a1d9"},
+	}
+	matches := SnapNoteColors(notes, palette)
+	want := []struct{ color, name string }{
+		{"#fff173ff", "yellow"},
+		{"not-a-color", ""},
+		{"#ff9ee0ff", "pink"},
+	}
+	for i, w := range want {
+		if notes[i].BackgroundColor != w.color || matches[i].Name != w.name || matches[i].Color != w.color {
+			t.Errorf("note %d: got %s, match %+v, want %s %q", i, notes[i].BackgroundColor, matches[i], w.color, w.name)
+		}
+	}
+	if matches[0].Raw != "#E8D96A" {
+		t.Errorf("got raw %q, want the extracted color", matches[0].Raw)
+	}
+}
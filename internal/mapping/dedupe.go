@@ -0,0 +1,135 @@
+package mapping
+
+import (
+	"log"
+	"sort"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/llm"
+)
+
+// Thresholds for treating two notes from different tiles as the same note
+const (
+	dupIoU         = 0.5 // boxes overlap this much whatever the text says
+	dupContainment = 0.8 // one box lies mostly inside the other, as when a tile cut a note in two...
+	dupContainText = 0.5 // ...and their texts are at least this similar
+	dupLooseIoU    = 0.2 // boxes overlap a little...
+	dupLooseText   = 0.8 // ...but their texts nearly agree
+)
+
+// tiledNote is a note found in one tile, with its index in the merged result
+type tiledNote struct {
+	note     llm.ExtractPostitNotesOutput
+	tile     int
+	interior int
+	index    int // in all notes, in tile order
+	merged   int // index in the merged notes
+}
+
+// MergeTiles combines the extractions of overlapping tiles into one, in
+// full image coordinates. A note seen by two tiles is kept once, from the
+// tile where it lies furthest from an inner edge and so is least likely to
+// be cut off. Edges are remapped to the merged notes.
+func MergeTiles(results []llm.TileResult, imageWidth, imageHeight int) *llm.Extraction {
+	var all []*tiledNote
+	for t, r := range results {
+		if r.Extraction == nil {
+			continue
+		}
+		for _, n := range r.Extraction.Notes {
+			x, y, w, h := noteBox(n)
+			all = append(all, &tiledNote{note: n, tile: t, index: len(all), interior: r.Tile.Interior(x, y, w, h, imageWidth, imageHeight), merged: -1})
+		}
+	}
+	// Best placed notes first, so each duplicate group is represented by its best copy
+	order := make([]*tiledNote, len(all))
+	copy(order, all)
+	sort.SliceStable(order, func(i, j int) bool { return order[i].interior > order[j].interior })
+
+	var kept []*tiledNote
+	for _, n := range order {
+		for _, k := range kept {
+			if k.tile != n.tile && duplicateNotes(k.note, n.note) {
+				n.merged = -2 - k.index // resolved below, once kept notes have their final index
+				if k.note.Text == "" {
+					k.note.Text = n.note.Text
+				}
+				break
+			}
+		}
+		if n.merged == -1 {
+			kept = append(kept, n)
+		}
+	}
+
+	// Keep the notes in tile order, which is close to the order they were read in
+	merged := &llm.Extraction{Notes: []llm.ExtractPostitNotesOutput{}, Edges: []llm.Edge{}}
+	for _, n := range all {
+		if n.merged == -1 {
+			n.merged = len(merged.Notes)
+			merged.Notes = append(merged.Notes, n.note)
+		}
+	}
+	for _, n := range all {
+		if n.merged < -1 {
+			n.merged = all[-2-n.merged].merged
+		}
+	}
+
+	// Edges refer to notes by their index within the tile
+	offset := 0
+	for _, r := range results {
+		if r.Extraction == nil {
+			continue
+		}
+		for _, e := range r.Extraction.Edges {
+			if e.Source < 0 || e.Target < 0 || e.Source >= len(r.Extraction.Notes) || e.Target >= len(r.Extraction.Notes) {
+				continue
+			}
+			e.Source, e.Target = all[offset+e.Source].merged, all[offset+e.Target].merged
+			merged.Edges = append(merged.Edges, e)
+		}
+		if merged.Fallback == "" {
+			merged.Fallback = r.Extraction.Fallback
+		}
+		offset += len(r.Extraction.Notes)
+	}
+	merged.Edges = llm.NormalizeEdges(merged.Edges, len(merged.Notes))
+
+	log.Printf("[MergeTiles] Merged %d notes from %d tiles into %d", len(all), len(results), len(merged.Notes))
+	return merged
+}
+
+// duplicateNotes reports whether two notes from different tiles are the same note
+func duplicateNotes(a, b llm.ExtractPostitNotesOutput) bool {
+	ax, ay, aw, ah := noteBox(a)
+	bx, by, bw, bh := noteBox(b)
+	iw := max(0, minInt(ax+aw, bx+bw)-max(ax, bx))
+	ih := max(0, minInt(ay+ah, by+bh)-max(ay, by))
+	inter := float64(iw * ih)
+	if inter == 0 {
+		return false
+	}
+	areaA, areaB := float64(aw*ah), float64(bw*bh)
+	iou := inter / (areaA + areaB - inter)
+	if iou >= dupIoU {
+		return true
+	}
+	text := 1.0 // a note read without text matches anything
+	if a.Text != "" && b.Text != "" {
+		text = TextSimilarity(a.Text, b.Text)
+	}
+	containment := inter / min(areaA, areaB)
+	return (containment >= dupContainment && text >= dupContainText) ||
+		(iou >= dupLooseIoU && text >= dupLooseText)
+}
+
+func noteBox(n llm.ExtractPostitNotesOutput) (x, y, w, h int) {
+	return n.Location["x"], n.Location["y"], n.Size["width"], n.Size["height"]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
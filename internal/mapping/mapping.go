@@ -1,29 +1,20 @@
 package mapping
 
-import "github.com/jaypaulb/CanvusNoteMapper/internal/llm"
+import (
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/llm"
+)
 
-// MapNotesToZone maps notes from image pixel coordinates to the target zone, scaling and offsetting as needed.
-func MapNotesToZone(notes []llm.Note, imageWidth, imageHeight int, zoneDimensions [2]int, zoneLocation [2]int) []llm.Note {
-	zoneW, zoneH := zoneDimensions[0], zoneDimensions[1]
-	zoneX, zoneY := zoneLocation[0]+10, zoneLocation[1]+10
-	if imageWidth == 0 || imageHeight == 0 || zoneW == 0 || zoneH == 0 {
-		return notes // fallback: no mapping
+// FitToZone returns the scale that fits an image into a zone while maintaining
+// aspect ratio, and the offsets that centre the scaled image within the zone.
+func FitToZone(imageWidth, imageHeight, zoneWidth, zoneHeight float64) (scale, offsetX, offsetY float64) {
+	if imageWidth <= 0 || imageHeight <= 0 || zoneWidth <= 0 || zoneHeight <= 0 {
+		return 1, 0, 0
 	}
-
-	// Calculate scale to fit the image into the zone, maintaining aspect ratio
-	scale := min(float64(zoneW)/float64(imageWidth), float64(zoneH)/float64(imageHeight))
-	offsetX := (float64(zoneW) - float64(imageWidth)*scale) / 2
-	offsetY := (float64(zoneH) - float64(imageHeight)*scale) / 2
-
-	mapped := make([]llm.Note, len(notes))
-	for i, n := range notes {
-		mapped[i] = n
-		mapped[i].X = int(float64(n.X)*scale+offsetX) + zoneX
-		mapped[i].Y = int(float64(n.Y)*scale+offsetY) + zoneY
-		mapped[i].Width = int(float64(n.Width) * scale)
-		mapped[i].Height = int(float64(n.Height) * scale)
-	}
-	return mapped
+	scale = min(zoneWidth/imageWidth, zoneHeight/imageHeight)
+	offsetX = (zoneWidth - imageWidth*scale) / 2
+	offsetY = (zoneHeight - imageHeight*scale) / 2
+	return scale, offsetX, offsetY
 }
 
 func min(a, b float64) float64 {
@@ -34,25 +25,56 @@ func min(a, b float64) float64 {
 }
 
 // MapNotesToMCSFormat transforms mapped notes to the MCS API note creation format.
-func MapNotesToMCSFormat(notes []llm.Note) []map[string]interface{} {
-	mcsNotes := make([]map[string]interface{}, len(notes))
+func MapNotesToMCSFormat(notes []llm.Note) []canvusapi.Note {
+	mcsNotes := make([]canvusapi.Note, len(notes))
 	for i, n := range notes {
-		mcsNotes[i] = map[string]interface{}{
-			"background_color": n.Color,
-			"text":             n.Content,
-			"location": map[string]interface{}{
-				"x": n.X,
-				"y": n.Y,
+		mcsNotes[i] = canvusapi.Note{
+			WidgetBase: canvusapi.WidgetBase{
+				WidgetType: canvusapi.TypeNote,
+				Location:   &canvusapi.Location{X: float64(n.X), Y: float64(n.Y)},
+				Size:       &canvusapi.Size{Width: float64(n.Width), Height: float64(n.Height)},
+				State:      "normal",
 			},
-			"size": map[string]interface{}{
-				"width":  n.Width,
-				"height": n.Height,
-			},
-			"scale":       n.Scale,
-			"widget_type": "Note",
-			"state":       "normal",
+			BackgroundColor: n.Color,
+			Text:            n.Content,
 			// Add more fields as needed (e.g., pinned, depth, etc.)
 		}
+		if n.Scale != 0 {
+			mcsNotes[i].Scale = canvusapi.Float(n.Scale)
+		}
 	}
 	return mcsNotes
 }
+
+// Connector styling for edges
+const (
+	connectorColor = "#e7e7f2ff"
+	connectorWidth = 5
+	arrowTip       = "solid-equilateral-triangle"
+	plainTip       = "none"
+)
+
+// MapEdgeToConnector builds the connector for an edge between two created
+// notes. Edges are normalised, so arrows only ever point at the target or both ways.
+func MapEdgeToConnector(edge llm.Edge, srcID, dstID string) canvusapi.Connector {
+	end := func(id string, arrow bool) *canvusapi.ConnectorEnd {
+		tip := plainTip
+		if arrow {
+			tip = arrowTip
+		}
+		return &canvusapi.ConnectorEnd{
+			ID:           id,
+			RelLocation:  &canvusapi.Location{X: 0.5, Y: 0.5},
+			Tip:          tip,
+			AutoLocation: canvusapi.Bool(true),
+		}
+	}
+	return canvusapi.Connector{
+		WidgetBase: canvusapi.WidgetBase{WidgetType: canvusapi.TypeConnector, State: "normal"},
+		Src:        end(srcID, edge.Direction == llm.DirectionBoth),
+		Dst:        end(dstID, edge.Direction == llm.DirectionForward || edge.Direction == llm.DirectionBoth),
+		LineColor:  connectorColor,
+		LineWidth:  connectorWidth,
+		Type:       "curve",
+	}
+}
@@ -0,0 +1,157 @@
+package mcs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+)
+
+// FailurePolicy decides what CreateNotes does when a note cannot be created
+type FailurePolicy string
+
+const (
+	// PolicyRollback stops at the first failure and deletes the notes already created
+	PolicyRollback FailurePolicy = "rollback"
+	// PolicyReport creates as many notes as possible and reports each outcome
+	PolicyReport FailurePolicy = "report"
+)
+
+// DefaultBatchConcurrency is the number of notes created in parallel
+const DefaultBatchConcurrency = 4
+
+// BatchOptions configures CreateNotes
+type BatchOptions struct {
+	Concurrency int
+	Policy      FailurePolicy // default PolicyRollback
+}
+
+// NoteResult is the outcome for one note of a batch, in request order
+type NoteResult struct {
+	Index   int             `json:"index"`
+	ID      string          `json:"id,omitempty"`
+	Created *canvusapi.Note `json:"-"`
+	Error   string          `json:"error,omitempty"`
+	Skipped bool            `json:"skipped,omitempty"` // not attempted because the batch was stopped
+}
+
+// BatchResult reports a batch creation
+type BatchResult struct {
+	Policy         FailurePolicy `json:"policy"`
+	Results        []NoteResult  `json:"results"`
+	CreatedIDs     []string      `json:"createdIDs"`           // widgets that exist on the canvas after the batch
+	Failed         int           `json:"failed"`               // notes that could not be created
+	RolledBack     []string      `json:"rolledBack,omitempty"` // widgets deleted by the rollback
+	RollbackErrors []string      `json:"rollbackErrors,omitempty"`
+}
+
+// CreateNotes creates notes on the canvas with bounded concurrency. With
+// PolicyRollback the first failure stops the remaining notes and deletes the
+// ones already created, and an error is returned; with PolicyReport all notes
+// are attempted and failures are only reported in the result. The result is
+// returned in both cases, so callers always know which widgets exist.
+func (c *MCSClient) CreateNotes(ctx context.Context, canvasID string, notes []canvusapi.Note, opts BatchOptions) (*BatchResult, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultBatchConcurrency
+	}
+	if opts.Policy == "" {
+		opts.Policy = PolicyRollback
+	}
+	if opts.Policy != PolicyRollback && opts.Policy != PolicyReport {
+		return nil, fmt.Errorf("unknown failure policy %q", opts.Policy)
+	}
+	client := canvusapi.NewClient(c.Server, canvasID, c.APIKey)
+	result := &BatchResult{Policy: opts.Policy, Results: make([]NoteResult, len(notes))}
+
+	// A failure or cancellation stops new notes from starting, but requests
+	// already in flight are left to finish: cancelling one could leave a note
+	// the server created without us learning its ID, so it could not be rolled back
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		sem      = make(chan struct{}, opts.Concurrency)
+		stopped  = make(chan struct{})
+		stopOnce sync.Once
+	)
+	stop := func() { stopOnce.Do(func() { close(stopped) }) }
+	inFlightCtx := context.WithoutCancel(ctx) // cancelling ctx stops new notes only
+	for i := range notes {
+		result.Results[i] = NoteResult{Index: i}
+		select {
+		case sem <- struct{}{}:
+		case <-stopped:
+		case <-ctx.Done():
+		}
+		if isClosed(stopped) || ctx.Err() != nil {
+			result.Results[i].Skipped = true
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			created, err := client.CreateNoteTyped(inFlightCtx, &notes[i])
+			mu.Lock()
+			defer mu.Unlock()
+			r := &result.Results[i]
+			if err != nil {
+				r.Error = err.Error()
+				log.Printf("[CreateNotes] Note %d failed: %v", i+1, err)
+				if firstErr == nil {
+					firstErr = fmt.Errorf("note %d: %w", i+1, err)
+				}
+				if opts.Policy == PolicyRollback {
+					stop()
+				}
+				return
+			}
+			r.ID, r.Created = created.ID, created
+		}(i)
+	}
+	wg.Wait()
+
+	for _, r := range result.Results {
+		switch {
+		case r.ID != "":
+			result.CreatedIDs = append(result.CreatedIDs, r.ID)
+		case r.Error != "":
+			result.Failed++
+		}
+	}
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr == nil || opts.Policy == PolicyReport {
+		return result, nil
+	}
+
+	// Roll back even if the caller's context is cancelled, so nothing is left half-imported
+	rollbackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Minute)
+	defer cancel()
+	var remaining []string
+	for _, id := range result.CreatedIDs {
+		if err := client.DeleteNote(rollbackCtx, id); err != nil {
+			log.Printf("[CreateNotes] Rollback failed to delete note %s: %v", id, err)
+			result.RollbackErrors = append(result.RollbackErrors, fmt.Sprintf("%s: %v", id, err))
+			remaining = append(remaining, id)
+			continue
+		}
+		result.RolledBack = append(result.RolledBack, id)
+	}
+	log.Printf("[CreateNotes] Rolled back %d of %d notes after: %v", len(result.RolledBack), len(result.CreatedIDs), firstErr)
+	result.CreatedIDs = remaining
+	return result, firstErr
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,187 @@
+package mcs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+)
+
+func batchNotes(n int) []canvusapi.Note {
+	notes := make([]canvusapi.Note, n)
+	for i := range notes {
+		notes[i].Text = fmt.Sprintf("note %d", i+1)
+	}
+	return notes
+}
+
+// deletes returns the IDs the server was asked to delete, sorted
+func (s *noteServer) deletes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for _, r := range s.requests {
+		if id, ok := strings.CutPrefix(r, "DELETE /notes/"); ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestCreateNotesReport(t *testing.T) {
+	srv := newNoteServer(t, "canvas-1")
+	srv.fail = func(r *http.Request, n int) int {
+		if r.Method == http.MethodPost && n == 1 {
+			return http.StatusBadRequest
+		}
+		return 0
+	}
+	client := NewClient(srv.URL, "test-key", "canvas-1")
+	result, err := client.CreateNotes(context.Background(), "canvas-1", batchNotes(5), BatchOptions{Concurrency: 1, Policy: PolicyReport})
+	if err != nil {
+		t.Fatalf("CreateNotes: %v", err)
+	}
+	if result.Policy != PolicyReport || result.Failed != 1 || len(result.CreatedIDs) != 4 {
+		t.Errorf("got %d failed and %d created, want 1 and 4", result.Failed, len(result.CreatedIDs))
+	}
+	for i, r := range result.Results {
+		if r.Index != i || r.Skipped {
+			t.Errorf("result %d: %+v", i, r)
+		}
+		if failed := r.Error != ""; failed != (i == 1) || failed == (r.ID != "") {
+			t.Errorf("result %d: got ID %q error %q, want only note 2 to fail", i, r.ID, r.Error)
+		}
+	}
+	if d := srv.deletes(); len(d) != 0 {
+		t.Errorf("report mode deleted %v", d)
+	}
+}
+
+func TestCreateNotesRollback(t *testing.T) {
+	srv := newNoteServer(t, "canvas-1")
+	srv.fail = func(r *http.Request, n int) int {
+		if r.Method == http.MethodPost && n == 2 {
+			return http.StatusBadRequest
+		}
+		return 0
+	}
+	client := NewClient(srv.URL, "test-key", "canvas-1")
+	result, err := client.CreateNotes(context.Background(), "canvas-1", batchNotes(5), BatchOptions{Concurrency: 1})
+	if err == nil || !strings.Contains(err.Error(), "note 3") {
+		t.Fatalf("got error %v, want one for note 3", err)
+	}
+	if result == nil {
+		t.Fatal("got no result with the error")
+	}
+	// Notes 1 and 2 were created and rolled back; 4 and 5 never started
+	if got := srv.deletes(); strings.Join(got, ",") != "note-1,note-2" {
+		t.Errorf("got deletes %v, want note-1 and note-2", got)
+	}
+	sort.Strings(result.RolledBack)
+	if strings.Join(result.RolledBack, ",") != "note-1,note-2" || len(result.CreatedIDs) != 0 || result.Failed != 1 {
+		t.Errorf("got rolled back %v, created %v, %d failed", result.RolledBack, result.CreatedIDs, result.Failed)
+	}
+	for i, skipped := range []bool{false, false, false, true, true} {
+		if result.Results[i].Skipped != skipped {
+			t.Errorf("result %d: skipped = %v, want %v", i, result.Results[i].Skipped, skipped)
+		}
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.counts[http.MethodPost] != 3 || len(srv.notes) != 0 {
+		t.Errorf("got %d POSTs and %d notes left, want 3 and none", srv.counts[http.MethodPost], len(srv.notes))
+	}
+}
+
+func TestCreateNotesRollbackReportsFailedDeletes(t *testing.T) {
+	srv := newNoteServer(t, "canvas-1")
+	srv.fail = func(r *http.Request, n int) int {
+		switch {
+		case r.Method == http.MethodPost && n == 1:
+			return http.StatusBadRequest
+		case r.Method == http.MethodDelete:
+			return http.StatusForbidden
+		}
+		return 0
+	}
+	client := NewClient(srv.URL, "test-key", "canvas-1")
+	result, err := client.CreateNotes(context.Background(), "canvas-1", batchNotes(3), BatchOptions{Concurrency: 1})
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	// The note that could not be deleted still exists, so it stays in CreatedIDs
+	if len(result.RolledBack) != 0 || len(result.RollbackErrors) != 1 || strings.Join(result.CreatedIDs, ",") != "note-1" {
+		t.Errorf("got rolled back %v, errors %v, created %v", result.RolledBack, result.RollbackErrors, result.CreatedIDs)
+	}
+}
+
+func TestCreateNotesCancelledRollsBack(t *testing.T) {
+	srv := newNoteServer(t, "canvas-1")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv.fail = func(r *http.Request, n int) int {
+		// The client goes away while note 2 is being created
+		if r.Method == http.MethodPost && n == 1 {
+			cancel()
+		}
+		return 0
+	}
+	client := NewClient(srv.URL, "test-key", "canvas-1")
+	result, err := client.CreateNotes(ctx, "canvas-1", batchNotes(4), BatchOptions{Concurrency: 1})
+	if err != context.Canceled {
+		t.Fatalf("got error %v, want context.Canceled", err)
+	}
+	// The request in flight still completes, and both notes are deleted
+	// even though the caller's context is done
+	if got := srv.deletes(); strings.Join(got, ",") != "note-1,note-2" {
+		t.Errorf("got deletes %v, want note-1 and note-2", got)
+	}
+	if len(result.CreatedIDs) != 0 || !result.Results[2].Skipped || !result.Results[3].Skipped {
+		t.Errorf("got created %v and results %+v", result.CreatedIDs, result.Results)
+	}
+}
+
+func TestCreateNotesConcurrency(t *testing.T) {
+	srv := newNoteServer(t, "canvas-1")
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	srv.fail = func(r *http.Request, n int) int {
+		if r.Method != http.MethodPost {
+			return 0
+		}
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return 0
+	}
+	client := NewClient(srv.URL, "test-key", "canvas-1")
+	result, err := client.CreateNotes(context.Background(), "canvas-1", batchNotes(9), BatchOptions{Concurrency: 3})
+	if err != nil {
+		t.Fatalf("CreateNotes: %v", err)
+	}
+	if len(result.CreatedIDs) != 9 {
+		t.Errorf("got %d created, want 9", len(result.CreatedIDs))
+	}
+	if peak != 3 {
+		t.Errorf("got at most %d requests at once, want 3", peak)
+	}
+}
+
+func TestCreateNotesUnknownPolicy(t *testing.T) {
+	client := NewClient("http://mcs.invalid", "test-key", "canvas-1")
+	if _, err := client.CreateNotes(context.Background(), "canvas-1", batchNotes(1), BatchOptions{Policy: "retry"}); err == nil {
+		t.Error("got no error for an unknown policy")
+	}
+}
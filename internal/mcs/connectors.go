@@ -0,0 +1,37 @@
+package mcs
+
+import (
+	"context"
+	"log"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+)
+
+// ConnectorResult is the outcome of creating one connector, in request order
+type ConnectorResult struct {
+	Index int    `json:"index"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// CreateConnectors creates connectors between existing widgets. Every
+// connector is attempted; failures are only reported in the results.
+func (c *MCSClient) CreateConnectors(ctx context.Context, canvasID string, connectors []canvusapi.Connector) []ConnectorResult {
+	client := canvusapi.NewClient(c.Server, canvasID, c.APIKey)
+	results := make([]ConnectorResult, len(connectors))
+	for i := range connectors {
+		results[i].Index = i
+		if err := ctx.Err(); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		created, err := client.CreateConnectorTyped(ctx, &connectors[i])
+		if err != nil {
+			log.Printf("[CreateConnectors] Connector %d (%s -> %s) failed: %v", i+1, connectors[i].Src.ID, connectors[i].Dst.ID, err)
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].ID = created.ID
+	}
+	return results
+}
@@ -0,0 +1,64 @@
+package mcs
+
+import (
+	"fmt"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+)
+
+// Frame is where a widget's local coordinate system sits on the canvas: a
+// point (x, y) in the widget's own units is at (X + x*Scale, Y + y*Scale)
+type Frame struct {
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Scale float64 `json:"scale"`
+}
+
+// CanvasFrame is the frame of the canvas itself
+var CanvasFrame = Frame{Scale: 1}
+
+// Apply converts a point from the frame's local units to canvas coordinates
+func (f Frame) Apply(x, y float64) (float64, float64) {
+	return f.X + x*f.Scale, f.Y + y*f.Scale
+}
+
+// Child returns the frame of a widget placed at loc with the given scale inside f
+func (f Frame) Child(loc *canvusapi.Location, scale float64) Frame {
+	if scale == 0 {
+		scale = 1
+	}
+	var x, y float64
+	if loc != nil {
+		x, y = f.Apply(loc.X, loc.Y)
+	}
+	return Frame{X: x, Y: y, Scale: f.Scale * scale}
+}
+
+// maxParentDepth guards against parent cycles in malformed data
+const maxParentDepth = 32
+
+// resolveFrame returns the absolute frame of widget id. Widget locations are
+// relative to their parent, so the parent chain is walked up to the canvas;
+// the SharedCanvas widget is the root and does not transform its children.
+func resolveFrame(id string, lookup func(id string) (*canvusapi.Widget, error)) (Frame, error) {
+	var chain []*canvusapi.Widget
+	for next := id; next != ""; {
+		if len(chain) == maxParentDepth {
+			return Frame{}, fmt.Errorf("parent chain of %s is deeper than %d (cycle?)", id, maxParentDepth)
+		}
+		w, err := lookup(next)
+		if err != nil {
+			return Frame{}, fmt.Errorf("failed to resolve parent %s of %s: %w", next, id, err)
+		}
+		if w.WidgetType == canvusapi.TypeSharedCanvas {
+			break
+		}
+		chain = append(chain, w)
+		next = w.ParentID
+	}
+	frame := CanvasFrame
+	for i := len(chain) - 1; i >= 0; i-- {
+		frame = frame.Child(chain[i].Location, chain[i].ScaleValue())
+	}
+	return frame, nil
+}
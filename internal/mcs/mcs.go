@@ -1,10 +1,9 @@
 package mcs
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
+	"log"
 
 	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
 )
@@ -21,15 +20,35 @@ type CanvasInfo struct {
 	Name string `json:"name"`
 }
 
+// AnchorInfo describes an anchor. X, Y, Width, Height and Scale are as
+// stored on the anchor, i.e. relative to its parent; Absolute is where the
+// anchor actually is on the canvas.
 type AnchorInfo struct {
-	ID     string  `json:"id"`
-	Name   string  `json:"name"`
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	Scale    float64 `json:"scale"`
+	Depth    float64 `json:"depth"`
+	ParentID string  `json:"parentID,omitempty"`
+	Pinned   bool    `json:"pinned"`
+	Absolute *Bounds `json:"absolute,omitempty"` // nil if the parent chain could not be resolved
+}
+
+// Bounds is a widget's bounding box in canvas coordinates
+type Bounds struct {
 	X      float64 `json:"x"`
 	Y      float64 `json:"y"`
 	Width  float64 `json:"width"`
 	Height float64 `json:"height"`
-	Scale  float64 `json:"scale"`
-	// Add more fields as needed
+	Scale  float64 `json:"scale"` // product of the widget's and its parents' scales
+}
+
+// Frame returns the frame of the widget these bounds belong to
+func (b Bounds) Frame() Frame {
+	return Frame{X: b.X, Y: b.Y, Scale: b.Scale}
 }
 
 type CanvasSize struct {
@@ -41,27 +60,12 @@ func NewClient(server, apiKey, canvasID string) *MCSClient {
 	return &MCSClient{Server: server, APIKey: apiKey, CanvasID: canvasID}
 }
 
-func (c *MCSClient) GetCanvases() ([]CanvasInfo, error) {
+func (c *MCSClient) GetCanvases(ctx context.Context) ([]CanvasInfo, error) {
 	client := canvusapi.NewClient(c.Server, c.CanvasID, c.APIKey)
-	var canvasesRaw []map[string]interface{}
-	url := client.Server + "/api/v1/canvases"
-	req, err := http.NewRequest("GET", url, nil)
+	canvasesRaw, err := client.GetCanvases(ctx)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Private-Token", c.APIKey)
-	resp, err := client.HTTP.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("MCS error: %s", string(bodyBytes))
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&canvasesRaw); err != nil {
-		return nil, err
-	}
 	result := make([]CanvasInfo, 0, len(canvasesRaw))
 	for _, canvas := range canvasesRaw {
 		id, _ := canvas["id"].(string)
@@ -73,129 +77,95 @@ func (c *MCSClient) GetCanvases() ([]CanvasInfo, error) {
 	return result, nil
 }
 
-func (c *MCSClient) GetAnchors(canvasID string) ([]AnchorInfo, error) {
+// GetAnchors lists the anchors in a canvas with their absolute bounds
+func (c *MCSClient) GetAnchors(ctx context.Context, canvasID string) ([]AnchorInfo, error) {
 	client := canvusapi.NewClient(c.Server, canvasID, c.APIKey)
-	// Fetch anchors from the correct endpoint
-	url := client.Server + "/api/v1/canvases/" + canvasID + "/anchors"
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Private-Token", c.APIKey)
-	resp, err := client.HTTP.Do(req)
+	anchors, err := client.GetAnchorsTyped(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("MCS error: %s", string(bodyBytes))
-	}
-	var anchorsRaw []map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&anchorsRaw); err != nil {
-		return nil, err
-	}
-	result := make([]AnchorInfo, 0, len(anchorsRaw))
-	for i, a := range anchorsRaw {
-		anchor := AnchorInfo{}
-		if id, ok := a["id"].(string); ok {
-			anchor.ID = id
-		}
-		if name, ok := a["anchor_name"].(string); ok {
-			anchor.Name = name
+	// Parents can be any kind of widget, so resolve against the full widget list
+	// once rather than fetching each parent separately
+	var lookup func(id string) (*canvusapi.Widget, error)
+	if widgets, err := client.GetWidgetsTyped(ctx); err != nil {
+		log.Printf("[GetAnchors] Failed to fetch widgets, absolute bounds unavailable: %v", err)
+	} else {
+		byID := make(map[string]*canvusapi.Widget, len(widgets))
+		for i := range widgets {
+			byID[widgets[i].ID] = &widgets[i]
 		}
-		if loc, ok := a["location"].(map[string]interface{}); ok {
-			if x, ok := loc["x"].(float64); ok {
-				anchor.X = x
-			}
-			if y, ok := loc["y"].(float64); ok {
-				anchor.Y = y
+		lookup = func(id string) (*canvusapi.Widget, error) {
+			if w, ok := byID[id]; ok {
+				return w, nil
 			}
+			return nil, fmt.Errorf("widget %s not found", id)
 		}
-		if size, ok := a["size"].(map[string]interface{}); ok {
-			if w, ok := size["width"].(float64); ok {
-				anchor.Width = w
-			}
-			if h, ok := size["height"].(float64); ok {
-				anchor.Height = h
-			}
-		}
-		// Add more fields as needed
-		fmt.Printf("[GetAnchors] Parsed anchor %d: %+v\n", i, anchor)
-		result = append(result, anchor)
 	}
-	fmt.Printf("[GetAnchors] Returning %d anchors\n", len(result))
+	result := make([]AnchorInfo, 0, len(anchors))
+	for i := range anchors {
+		result = append(result, anchorInfo(&anchors[i], lookup))
+	}
+	log.Printf("[GetAnchors] Returning %d anchors", len(result))
 	return result, nil
 }
 
-func (c *MCSClient) CreateNote(canvasID string, noteData map[string]interface{}) (map[string]interface{}, error) {
+func (c *MCSClient) GetCanvasSize(ctx context.Context, canvasID string) (*CanvasSize, error) {
 	client := canvusapi.NewClient(c.Server, canvasID, c.APIKey)
-	return client.CreateNote(noteData)
-}
-
-func (c *MCSClient) GetCanvasSize(canvasID string) (*CanvasSize, error) {
-	client := canvusapi.NewClient(c.Server, canvasID, c.APIKey)
-	widgets, err := client.GetWidgets(false)
+	widgets, err := client.GetWidgetsTyped(ctx)
 	if err != nil {
 		return nil, err
 	}
-	for _, w := range widgets {
-		if wtype, ok := w["widget_type"].(string); ok && wtype == "SharedCanvas" {
-			width, _ := w["width"].(float64)
-			height, _ := w["height"].(float64)
-			return &CanvasSize{Width: width, Height: height}, nil
-		}
-	}
-	return nil, fmt.Errorf("SharedCanvas widget not found")
+	return canvasSizeOf(widgets)
 }
 
-func (c *MCSClient) GetAnchorInfo(canvasID, anchorID string) (*AnchorInfo, error) {
+// GetAnchorInfo returns one anchor with its absolute bounds
+func (c *MCSClient) GetAnchorInfo(ctx context.Context, canvasID, anchorID string) (*AnchorInfo, error) {
 	client := canvusapi.NewClient(c.Server, canvasID, c.APIKey)
-	url := client.Server + "/api/v1/canvases/" + canvasID + "/anchors/" + anchorID
-	req, err := http.NewRequest("GET", url, nil)
+	a, err := client.GetAnchorTyped(ctx, anchorID)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Private-Token", c.APIKey)
-	resp, err := client.HTTP.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("MCS error: %s", string(bodyBytes))
-	}
-	var a map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&a); err != nil {
-		return nil, err
-	}
-	anchor := AnchorInfo{}
-	if id, ok := a["id"].(string); ok {
-		anchor.ID = id
-	}
-	if name, ok := a["anchor_name"].(string); ok {
-		anchor.Name = name
-	}
-	if loc, ok := a["location"].(map[string]interface{}); ok {
-		if x, ok := loc["x"].(float64); ok {
-			anchor.X = x
-		}
-		if y, ok := loc["y"].(float64); ok {
-			anchor.Y = y
-		}
-	}
-	if size, ok := a["size"].(map[string]interface{}); ok {
-		if w, ok := size["width"].(float64); ok {
-			anchor.Width = w
-		}
-		if h, ok := size["height"].(float64); ok {
-			anchor.Height = h
+	anchor := anchorInfo(a, func(id string) (*canvusapi.Widget, error) {
+		return client.GetWidgetTyped(ctx, id)
+	})
+	return &anchor, nil
+}
+
+// anchorInfo converts an anchor, resolving its absolute bounds through its
+// parents with lookup (skipped if lookup is nil)
+func anchorInfo(a *canvusapi.Anchor, lookup func(id string) (*canvusapi.Widget, error)) AnchorInfo {
+	info := AnchorInfo{
+		ID:       a.ID,
+		Name:     a.AnchorName,
+		Scale:    a.ScaleValue(),
+		Depth:    a.DepthValue(),
+		ParentID: a.ParentID,
+		Pinned:   a.Pinned != nil && *a.Pinned,
+	}
+	if a.Location != nil {
+		info.X, info.Y = a.Location.X, a.Location.Y
+	}
+	if a.Size != nil {
+		info.Width, info.Height = a.Size.Width, a.Size.Height
+	}
+	if lookup == nil {
+		return info
+	}
+	parent := CanvasFrame
+	if a.ParentID != "" {
+		var err error
+		if parent, err = resolveFrame(a.ParentID, lookup); err != nil {
+			log.Printf("[anchorInfo] Anchor %s: %v", a.ID, err)
+			return info
 		}
 	}
-	if scale, ok := a["scale"].(float64); ok {
-		anchor.Scale = scale
+	frame := parent.Child(a.Location, a.ScaleValue())
+	info.Absolute = &Bounds{
+		X:      frame.X,
+		Y:      frame.Y,
+		Width:  info.Width * frame.Scale,
+		Height: info.Height * frame.Scale,
+		Scale:  frame.Scale,
 	}
-	// Add more fields as needed
-	return &anchor, nil
+	return info
 }
@@ -0,0 +1,154 @@
+package mcs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+)
+
+// ErrNoFreeSpace is returned when a box does not fit anywhere on the canvas
+var ErrNoFreeSpace = errors.New("no free space on the canvas")
+
+// canvasSizeOf finds the SharedCanvas widget, whose size is the size of the canvas
+func canvasSizeOf(widgets []canvusapi.Widget) (*CanvasSize, error) {
+	for _, w := range widgets {
+		if w.WidgetType != canvusapi.TypeSharedCanvas {
+			continue
+		}
+		if w.Size != nil {
+			return &CanvasSize{Width: w.Size.Width, Height: w.Size.Height}, nil
+		}
+		// Older servers report the size as top-level width and height
+		var size CanvasSize
+		json.Unmarshal(w.Extra["width"], &size.Width)
+		json.Unmarshal(w.Extra["height"], &size.Height)
+		return &size, nil
+	}
+	return nil, fmt.Errorf("SharedCanvas widget not found")
+}
+
+// widgetBounds returns the bounding boxes of the widgets on the canvas,
+// resolved through their parents. Widgets without a size (connectors) and
+// deleted widgets are left out.
+func widgetBounds(widgets []canvusapi.Widget) []Bounds {
+	byID := make(map[string]*canvusapi.Widget, len(widgets))
+	for i := range widgets {
+		byID[widgets[i].ID] = &widgets[i]
+	}
+	lookup := func(id string) (*canvusapi.Widget, error) {
+		if w, ok := byID[id]; ok {
+			return w, nil
+		}
+		return nil, fmt.Errorf("widget %s not found", id)
+	}
+	var boxes []Bounds
+	for _, w := range widgets {
+		if w.WidgetType == canvusapi.TypeSharedCanvas || w.State == "deleted" || w.Size == nil {
+			continue
+		}
+		parent, err := resolveFrame(w.ParentID, lookup)
+		if err != nil {
+			log.Printf("[widgetBounds] Skipping widget %s: %v", w.ID, err)
+			continue
+		}
+		frame := parent.Child(w.Location, w.ScaleValue())
+		boxes = append(boxes, Bounds{
+			X:      frame.X,
+			Y:      frame.Y,
+			Width:  w.Size.Width * frame.Scale,
+			Height: w.Size.Height * frame.Scale,
+			Scale:  frame.Scale,
+		})
+	}
+	return boxes
+}
+
+// overlaps reports whether two boxes overlap once b is grown by margin on every side
+func overlaps(a, b Bounds, margin float64) bool {
+	return a.X < b.X+b.Width+margin && b.X-margin < a.X+a.Width &&
+		a.Y < b.Y+b.Height+margin && b.Y-margin < a.Y+a.Height
+}
+
+// findFreeSpace returns the top-left corner of a width x height box that keeps
+// margin clear of every box and lies within the canvas. Candidate corners are
+// the canvas corner and the right and bottom edges of existing widgets; the
+// first that fits in reading order (top to bottom, left to right) wins.
+func findFreeSpace(canvas CanvasSize, boxes []Bounds, width, height, margin float64) (float64, float64, bool) {
+	xs := []float64{margin}
+	ys := []float64{margin}
+	for _, b := range boxes {
+		xs = append(xs, b.X+b.Width+margin)
+		ys = append(ys, b.Y+b.Height+margin)
+	}
+	sort.Float64s(xs)
+	sort.Float64s(ys)
+	for _, y := range ys {
+		if y < 0 || y+height > canvas.Height-margin {
+			continue
+		}
+		for _, x := range xs {
+			if x < 0 || x+width > canvas.Width-margin {
+				continue
+			}
+			candidate := Bounds{X: x, Y: y, Width: width, Height: height}
+			free := true
+			for _, b := range boxes {
+				if overlaps(candidate, b, margin) {
+					free = false
+					break
+				}
+			}
+			if free {
+				return x, y, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// FindFreeSpace finds a place on the canvas for a width x height box that
+// does not overlap any widget, keeping margin around them
+func (c *MCSClient) FindFreeSpace(ctx context.Context, canvasID string, width, height, margin float64) (*canvusapi.Location, error) {
+	client := canvusapi.NewClient(c.Server, canvasID, c.APIKey)
+	widgets, err := client.GetWidgetsTyped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	canvas, err := canvasSizeOf(widgets)
+	if err != nil {
+		return nil, err
+	}
+	boxes := widgetBounds(widgets)
+	x, y, ok := findFreeSpace(*canvas, boxes, width, height, margin)
+	if !ok {
+		return nil, fmt.Errorf("%w for %.0fx%.0f (canvas %.0fx%.0f, %d widgets)", ErrNoFreeSpace, width, height, canvas.Width, canvas.Height, len(boxes))
+	}
+	log.Printf("[FindFreeSpace] %.0fx%.0f fits at (%.0f, %.0f) on canvas %s (%.0fx%.0f, %d widgets)", width, height, x, y, canvasID, canvas.Width, canvas.Height, len(boxes))
+	return &canvusapi.Location{X: x, Y: y}, nil
+}
+
+// CreateAnchor creates a top-level anchor
+func (c *MCSClient) CreateAnchor(ctx context.Context, canvasID, name string, location *canvusapi.Location, size *canvusapi.Size) (*AnchorInfo, error) {
+	client := canvusapi.NewClient(c.Server, canvasID, c.APIKey)
+	created, err := client.CreateAnchorTyped(ctx, &canvusapi.Anchor{
+		WidgetBase: canvusapi.WidgetBase{
+			WidgetType: canvusapi.TypeAnchor,
+			Location:   location,
+			Size:       size,
+			Scale:      canvusapi.Float(1),
+			State:      "normal",
+		},
+		AnchorName: name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	anchor := anchorInfo(created, nil)
+	anchor.Absolute = &Bounds{X: anchor.X, Y: anchor.Y, Width: anchor.Width, Height: anchor.Height, Scale: 1}
+	return &anchor, nil
+}
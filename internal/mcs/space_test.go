@@ -0,0 +1,50 @@
+package mcs
+
+import "testing"
+
+func TestFindFreeSpace(t *testing.T) {
+	canvas := CanvasSize{Width: 1000, Height: 800}
+	tests := []struct {
+		name          string
+		canvas        CanvasSize
+		boxes         []Bounds
+		width, height float64
+		wantX, wantY  float64
+		wantOK        bool
+	}{
+		{"empty canvas", canvas, nil, 100, 50, 10, 10, true},
+		{"fills the canvas up to the margin", CanvasSize{Width: 120, Height: 70}, nil, 100, 50, 10, 10, true},
+		{"one pixel too wide", CanvasSize{Width: 120, Height: 70}, nil, 101, 50, 0, 0, false},
+		{"one pixel too tall", CanvasSize{Width: 120, Height: 70}, nil, 100, 51, 0, 0, false},
+		{"fully occupied", canvas, []Bounds{{X: 0, Y: 0, Width: 1000, Height: 800}}, 10, 10, 0, 0, false},
+		{"right of a widget", canvas, []Bounds{{X: 0, Y: 0, Width: 300, Height: 200}}, 100, 100, 310, 10, true},
+		{"below a widget that fills the row", canvas, []Bounds{{X: 0, Y: 0, Width: 950, Height: 200}}, 100, 100, 10, 210, true},
+		{"in the gap between widgets", canvas, []Bounds{
+			{X: 0, Y: 0, Width: 300, Height: 500},
+			{X: 420, Y: 0, Width: 580, Height: 500},
+		}, 100, 100, 310, 10, true},
+		{"gap too narrow for the margin", canvas, []Bounds{
+			{X: 0, Y: 0, Width: 300, Height: 500},
+			{X: 419, Y: 0, Width: 581, Height: 500},
+		}, 100, 100, 10, 510, true},
+		{"widget off the canvas", canvas, []Bounds{{X: -500, Y: -500, Width: 100, Height: 100}}, 100, 100, 10, 10, true},
+		{"box larger than the canvas", canvas, nil, 2000, 100, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, y, ok := findFreeSpace(tt.canvas, tt.boxes, tt.width, tt.height, 10)
+			if ok != tt.wantOK || x != tt.wantX || y != tt.wantY {
+				t.Errorf("got (%v, %v) %v, want (%v, %v) %v", x, y, ok, tt.wantX, tt.wantY, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			placed := Bounds{X: x, Y: y, Width: tt.width, Height: tt.height}
+			for _, b := range tt.boxes {
+				if overlaps(placed, b, 10) {
+					t.Errorf("placed box %+v is within the margin of %+v", placed, b)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package mcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/mapping"
+)
+
+// SyncAction is what a sync does with one note
+type SyncAction string
+
+const (
+	SyncCreate    SyncAction = "create"    // in the photo but not on the canvas
+	SyncUpdate    SyncAction = "update"    // matched, but moved or edited
+	SyncUnchanged SyncAction = "unchanged" // matched and already up to date
+	SyncDelete    SyncAction = "delete"    // on the canvas but no longer in the photo
+	SyncMissing   SyncAction = "missing"   // like SyncDelete, but left in place
+)
+
+const (
+	// DefaultMinMatchScore is the lowest score at which two notes are considered the same
+	DefaultMinMatchScore = 0.55
+	// DefaultMatchDistance is used when SyncOptions.MaxDistance is not set
+	DefaultMatchDistance = 1000
+)
+
+// Weights of the match score; text dominates so a moved note still matches,
+// while colour and position let an edited note match in place
+const (
+	textWeight     = 0.55
+	colorWeight    = 0.15
+	positionWeight = 0.30
+)
+
+// ExistingNote is a previously imported note as it is now on the canvas
+type ExistingNote struct {
+	Note   canvusapi.Note
+	Parent Frame // frame of the note's parent, which its location is relative to
+}
+
+// SyncOptions configures PlanSync
+type SyncOptions struct {
+	DeleteMissing bool    // delete existing notes with no match instead of leaving them
+	MinScore      float64 // default DefaultMinMatchScore
+	MaxDistance   float64 // canvas distance at which position stops counting towards a match
+}
+
+// SyncChange is one step of a sync
+type SyncChange struct {
+	Action  SyncAction      `json:"action"`
+	Index   int             `json:"index"`           // incoming note, -1 for an existing note with no match
+	ID      string          `json:"id,omitempty"`    // existing note, or the note created
+	Score   float64         `json:"score,omitempty"` // match score of an update or unchanged note
+	Changes []string        `json:"changes,omitempty"`
+	Note    *canvusapi.Note `json:"note,omitempty"` // note to create, or the fields to update
+	Error   string          `json:"error,omitempty"`
+	Result  *canvusapi.Note `json:"-"` // note as created or updated
+}
+
+// SyncPlan is the diff between the notes in a photo and those on the canvas
+type SyncPlan struct {
+	Changes   []SyncChange `json:"changes"`
+	Create    int          `json:"create"`
+	Update    int          `json:"update"`
+	Unchanged int          `json:"unchanged"`
+	Delete    int          `json:"delete"`
+	Missing   int          `json:"missing"`
+	Failed    int          `json:"failed,omitempty"`
+}
+
+// placed is a note's geometry on the canvas
+type placed struct {
+	x, y, width, height float64 // top left and size
+}
+
+func (p placed) center() (float64, float64) {
+	return p.x + p.width/2, p.y + p.height/2
+}
+
+func placeIn(n *canvusapi.Note, f Frame) placed {
+	scale := n.ScaleValue()
+	var p placed
+	if n.Location != nil {
+		p.x, p.y = f.Apply(n.Location.X, n.Location.Y)
+	}
+	if n.Size != nil {
+		p.width, p.height = n.Size.Width*scale*f.Scale, n.Size.Height*scale*f.Scale
+	}
+	return p
+}
+
+// PlanSync matches incoming notes, positioned in frame, against existing notes
+// by text similarity, colour and position, and works out what to create,
+// update and delete. Each note is matched at most once, best matches first.
+func PlanSync(incoming []canvusapi.Note, frame Frame, existing []ExistingNote, opts SyncOptions) *SyncPlan {
+	if opts.MinScore <= 0 {
+		opts.MinScore = DefaultMinMatchScore
+	}
+	if opts.MaxDistance <= 0 {
+		opts.MaxDistance = DefaultMatchDistance
+	}
+	in := make([]placed, len(incoming))
+	for i := range incoming {
+		in[i] = placeIn(&incoming[i], frame)
+	}
+	ex := make([]placed, len(existing))
+	for j := range existing {
+		ex[j] = placeIn(&existing[j].Note, existing[j].Parent)
+	}
+
+	type candidate struct {
+		i, j  int
+		score float64
+	}
+	var candidates []candidate
+	for i := range incoming {
+		for j := range existing {
+			ix, iy := in[i].center()
+			jx, jy := ex[j].center()
+			position := math.Max(0, 1-math.Hypot(ix-jx, iy-jy)/opts.MaxDistance)
+			color := 0.0
+			if sameColor(incoming[i].BackgroundColor, existing[j].Note.BackgroundColor) {
+				color = 1
+			}
+			score := textWeight*mapping.TextSimilarity(incoming[i].Text, existing[j].Note.Text) + colorWeight*color + positionWeight*position
+			if score >= opts.MinScore {
+				candidates = append(candidates, candidate{i, j, score})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool { return candidates[a].score > candidates[b].score })
+
+	plan := &SyncPlan{}
+	matchedIn := make([]int, len(incoming))
+	for i := range matchedIn {
+		matchedIn[i] = -1
+	}
+	matchedEx := make([]bool, len(existing))
+	scores := make([]float64, len(incoming))
+	for _, c := range candidates {
+		if matchedIn[c.i] >= 0 || matchedEx[c.j] {
+			continue
+		}
+		matchedIn[c.i], matchedEx[c.j], scores[c.i] = c.j, true, c.score
+	}
+
+	for i := range incoming {
+		j := matchedIn[i]
+		if j < 0 {
+			note := incoming[i]
+			plan.Changes = append(plan.Changes, SyncChange{Action: SyncCreate, Index: i, Note: &note})
+			plan.Create++
+			continue
+		}
+		change := SyncChange{Action: SyncUnchanged, Index: i, ID: existing[j].Note.ID, Score: math.Round(scores[i]*1000) / 1000}
+		if patch, changes := notePatch(&incoming[i], in[i], &existing[j], ex[j]); len(changes) > 0 {
+			change.Action, change.Changes, change.Note = SyncUpdate, changes, patch
+			plan.Update++
+		} else {
+			plan.Unchanged++
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+	for j := range existing {
+		if matchedEx[j] {
+			continue
+		}
+		change := SyncChange{Action: SyncMissing, Index: -1, ID: existing[j].Note.ID}
+		if opts.DeleteMissing {
+			change.Action = SyncDelete
+			plan.Delete++
+		} else {
+			plan.Missing++
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+	return plan
+}
+
+// notePatch returns the fields to update so the existing note matches the
+// incoming one, with the location converted to the existing note's parent
+func notePatch(incoming *canvusapi.Note, in placed, existing *ExistingNote, ex placed) (*canvusapi.Note, []string) {
+	// A new photo never reproduces positions exactly, so ignore jitter of a
+	// few percent of the note's size
+	eps := math.Max(0.5, 0.05*math.Max(ex.width, ex.height))
+	patch := &canvusapi.Note{}
+	var changes []string
+	parent := existing.Parent
+	if math.Abs(in.x-ex.x) > eps || math.Abs(in.y-ex.y) > eps {
+		patch.Location = &canvusapi.Location{X: (in.x - parent.X) / parent.Scale, Y: (in.y - parent.Y) / parent.Scale}
+		changes = append(changes, "moved")
+	}
+	if math.Abs(in.width-ex.width) > eps || math.Abs(in.height-ex.height) > eps {
+		patch.Size = &canvusapi.Size{Width: in.width / parent.Scale, Height: in.height / parent.Scale}
+		patch.Scale = canvusapi.Float(1)
+		changes = append(changes, "resized")
+	}
+	if mapping.NormalizeText(incoming.Text) != mapping.NormalizeText(existing.Note.Text) && incoming.Text != "" {
+		patch.Text = incoming.Text
+		changes = append(changes, "text")
+	}
+	if !sameColor(incoming.BackgroundColor, existing.Note.BackgroundColor) && incoming.BackgroundColor != "" {
+		patch.BackgroundColor = incoming.BackgroundColor
+		changes = append(changes, "color")
+	}
+	return patch, changes
+}
+
+// ApplySync carries out a plan. Sync is not atomic: every step is attempted
+// and failures are recorded on the step and counted in plan.Failed.
+func (c *MCSClient) ApplySync(ctx context.Context, canvasID string, plan *SyncPlan) (*BatchResult, error) {
+	client := canvusapi.NewClient(c.Server, canvasID, c.APIKey)
+	var (
+		creates []canvusapi.Note
+		owners  []int
+	)
+	for k, change := range plan.Changes {
+		if change.Action == SyncCreate {
+			creates = append(creates, *change.Note)
+			owners = append(owners, k)
+		}
+	}
+	var batch *BatchResult
+	if len(creates) > 0 {
+		var err error
+		if batch, err = c.CreateNotes(ctx, canvasID, creates, BatchOptions{Policy: PolicyReport}); batch == nil {
+			return nil, err
+		}
+		for n, res := range batch.Results {
+			change := &plan.Changes[owners[n]]
+			change.ID, change.Result = res.ID, res.Created
+			switch {
+			case res.Error != "":
+				change.Error = res.Error
+			case res.Skipped:
+				change.Error = "not attempted: " + ctx.Err().Error()
+			}
+		}
+	}
+
+	for k := range plan.Changes {
+		change := &plan.Changes[k]
+		if ctx.Err() != nil && (change.Action == SyncUpdate || change.Action == SyncDelete) {
+			change.Error = "not attempted: " + ctx.Err().Error()
+			continue
+		}
+		switch change.Action {
+		case SyncUpdate:
+			updated, err := client.UpdateNoteTyped(ctx, change.ID, change.Note)
+			if err != nil {
+				change.Error = err.Error()
+				continue
+			}
+			change.Result = updated
+		case SyncDelete:
+			var apiErr *canvusapi.APIError
+			if err := client.DeleteNote(ctx, change.ID); err != nil && !(errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound) {
+				change.Error = err.Error()
+			}
+		}
+	}
+	plan.Failed = 0
+	for _, change := range plan.Changes {
+		if change.Error != "" {
+			plan.Failed++
+			log.Printf("[ApplySync] %s %s (note %d) failed: %s", change.Action, change.ID, change.Index+1, change.Error)
+		}
+	}
+	log.Printf("[ApplySync] Canvas %s: %d created, %d updated, %d deleted, %d failed", canvasID, plan.Create, plan.Update, plan.Delete, plan.Failed)
+	return batch, nil
+}
+
+// GetExistingNotes fetches notes by ID with the frames of their parents,
+// leaving out notes that have been deleted
+func (c *MCSClient) GetExistingNotes(ctx context.Context, canvasID string, ids []string) ([]ExistingNote, error) {
+	client := canvusapi.NewClient(c.Server, canvasID, c.APIKey)
+	frames := map[string]Frame{"": CanvasFrame}
+	lookup := func(id string) (*canvusapi.Widget, error) {
+		return client.GetWidgetTyped(ctx, id)
+	}
+	var notes []ExistingNote
+	for _, id := range ids {
+		note, err := client.GetNoteTyped(ctx, id)
+		var apiErr *canvusapi.APIError
+		switch {
+		case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("failed to fetch note %s: %w", id, err)
+		case note.State == "deleted":
+			continue
+		}
+		frame, ok := frames[note.ParentID]
+		if !ok {
+			if frame, err = resolveFrame(note.ParentID, lookup); err != nil {
+				return nil, err
+			}
+			frames[note.ParentID] = frame
+		}
+		notes = append(notes, ExistingNote{Note: *note, Parent: frame})
+	}
+	return notes, nil
+}
+
+// sameColor compares colours written as #RRGGBB or #RRGGBBAA, in any case
+func sameColor(a, b string) bool {
+	return normalizeColor(a) == normalizeColor(b)
+}
+
+func normalizeColor(c string) string {
+	c = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(c), "#"))
+	if len(c) == 6 {
+		c += "ff"
+	}
+	return c
+}
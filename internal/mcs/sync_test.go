@@ -0,0 +1,328 @@
+package mcs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+)
+
+// noteServer is a stand-in MCS server for the notes of one canvas. Notes are
+// kept by ID; fail can answer a request with an error status instead, given
+// the request and how many requests with the same method came before it.
+type noteServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	notes    map[string]map[string]interface{}
+	requests []string // "METHOD /notes/id", in the order they arrived
+	counts   map[string]int
+	nextID   int
+	fail     func(r *http.Request, n int) int
+}
+
+func newNoteServer(t *testing.T, canvasID string) *noteServer {
+	t.Helper()
+	s := &noteServer{notes: map[string]map[string]interface{}{}, counts: map[string]int{}}
+	prefix := "/api/v1/canvases/" + canvasID
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, prefix)
+		s.mu.Lock()
+		n := s.counts[r.Method]
+		s.counts[r.Method]++
+		s.requests = append(s.requests, r.Method+" "+path)
+		fail := s.fail
+		s.mu.Unlock()
+		if fail != nil {
+			if status := fail(r, n); status != 0 {
+				w.WriteHeader(status)
+				w.Write([]byte(`{"msg":"failed"}`))
+				return
+			}
+		}
+
+		var body map[string]interface{}
+		if r.Method == http.MethodPost || r.Method == http.MethodPatch {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("%s %s: %v", r.Method, path, err)
+			}
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		id := strings.TrimPrefix(path, "/notes/")
+		switch {
+		case r.Method == http.MethodPost && path == "/notes":
+			s.nextID++
+			body["id"] = fmt.Sprintf("note-%d", s.nextID)
+			s.notes[body["id"].(string)] = body
+			json.NewEncoder(w).Encode(body)
+		case s.notes[id] == nil:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"msg":"not found"}`))
+		case r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(s.notes[id])
+		case r.Method == http.MethodPatch:
+			for k, v := range body {
+				s.notes[id][k] = v
+			}
+			json.NewEncoder(w).Encode(s.notes[id])
+		case r.Method == http.MethodDelete:
+			delete(s.notes, id)
+		default:
+			t.Errorf("unexpected MCS request %s %s", r.Method, path)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// put adds a note to the server as if it already existed on the canvas
+func (s *noteServer) put(id string, fields map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fields["id"] = id
+	s.notes[id] = fields
+}
+
+// syncNote builds a note at (x, y) in its parent, size x size
+func syncNote(id, text, color string, x, y, size float64) canvusapi.Note {
+	n := canvusapi.Note{Text: text, BackgroundColor: color}
+	n.ID = id
+	n.Location = &canvusapi.Location{X: x, Y: y}
+	n.Size = &canvusapi.Size{Width: size, Height: size}
+	n.Scale = canvusapi.Float(1)
+	return n
+}
+
+func TestPlanSync(t *testing.T) {
+	const yellow, pink = "#FFFF88", "#FF88CC"
+	incoming := syncNote("", "Buy milk", yellow, 100, 100, 100)
+	tests := []struct {
+		name     string
+		incoming []canvusapi.Note
+		frame    Frame // of the incoming notes; the canvas if zero
+		existing []ExistingNote
+		opts     SyncOptions
+		want     []SyncAction
+		changes  [][]string
+		check    func(t *testing.T, plan *SyncPlan)
+	}{
+		{
+			name:     "exact match",
+			incoming: []canvusapi.Note{incoming},
+			existing: []ExistingNote{{Note: syncNote("a", "Buy milk", "#ffff88ff", 100, 100, 100), Parent: CanvasFrame}},
+			want:     []SyncAction{SyncUnchanged},
+			check: func(t *testing.T, plan *SyncPlan) {
+				if c := plan.Changes[0]; c.ID != "a" || c.Score != 1 {
+					t.Errorf("got %+v, want a match with a at score 1", c)
+				}
+			},
+		},
+		{
+			name:     "jitter within 5% of the size is ignored",
+			incoming: []canvusapi.Note{incoming},
+			existing: []ExistingNote{{Note: syncNote("a", "buy  MILK", yellow, 104, 96, 103), Parent: CanvasFrame}},
+			want:     []SyncAction{SyncUnchanged},
+		},
+		{
+			name:     "moved note",
+			incoming: []canvusapi.Note{incoming},
+			existing: []ExistingNote{{Note: syncNote("a", "Buy milk", yellow, 400, 100, 100), Parent: CanvasFrame}},
+			want:     []SyncAction{SyncUpdate},
+			changes:  [][]string{{"moved"}},
+			check: func(t *testing.T, plan *SyncPlan) {
+				c := plan.Changes[0]
+				// 0.55 text + 0.15 colour + 0.30 * (1 - 300/1000) position
+				if c.Score != 0.91 {
+					t.Errorf("got score %v, want 0.91", c.Score)
+				}
+				if l := c.Note.Location; l == nil || l.X != 100 || l.Y != 100 || c.Note.Size != nil || c.Note.Text != "" {
+					t.Errorf("got patch %+v, want only the location (100,100)", c.Note)
+				}
+			},
+		},
+		{
+			name:     "recoloured note",
+			incoming: []canvusapi.Note{incoming},
+			existing: []ExistingNote{{Note: syncNote("a", "Buy milk", pink, 100, 100, 100), Parent: CanvasFrame}},
+			want:     []SyncAction{SyncUpdate},
+			changes:  [][]string{{"color"}},
+			check: func(t *testing.T, plan *SyncPlan) {
+				if c := plan.Changes[0]; c.Score != 0.85 || c.Note.BackgroundColor != yellow || c.Note.Location != nil {
+					t.Errorf("got score %v and patch %+v, want 0.85 and only the colour", c.Score, c.Note)
+				}
+			},
+		},
+		{
+			name:     "edited text in place",
+			incoming: []canvusapi.Note{incoming},
+			existing: []ExistingNote{{Note: syncNote("a", "Buy oat milk", yellow, 100, 100, 100), Parent: CanvasFrame}},
+			want:     []SyncAction{SyncUpdate},
+			changes:  [][]string{{"text"}},
+		},
+		{
+			name:     "new note below the threshold",
+			incoming: []canvusapi.Note{syncNote("", "Call the plumber", pink, 100, 100, 100)},
+			existing: []ExistingNote{{Note: syncNote("a", "Buy milk", yellow, 2000, 2000, 100), Parent: CanvasFrame}},
+			want:     []SyncAction{SyncCreate, SyncMissing},
+			check: func(t *testing.T, plan *SyncPlan) {
+				if plan.Create != 1 || plan.Missing != 1 || plan.Changes[0].Note.Text != "Call the plumber" || plan.Changes[1].ID != "a" {
+					t.Errorf("got plan %+v", plan)
+				}
+			},
+		},
+		{
+			name:     "unmatched notes are deleted with DeleteMissing",
+			incoming: nil,
+			existing: []ExistingNote{{Note: syncNote("a", "Buy milk", yellow, 100, 100, 100), Parent: CanvasFrame}},
+			opts:     SyncOptions{DeleteMissing: true},
+			want:     []SyncAction{SyncDelete},
+		},
+		{
+			name:     "each existing note matches once, best score first",
+			incoming: []canvusapi.Note{syncNote("", "Buy milk", yellow, 600, 100, 100), incoming},
+			existing: []ExistingNote{{Note: syncNote("a", "Buy milk", yellow, 100, 100, 100), Parent: CanvasFrame}},
+			want:     []SyncAction{SyncCreate, SyncUnchanged},
+		},
+		{
+			// The existing note sits at (0,0) in an anchor at (1000,500) scaled
+			// 2x, so it covers (1000,500)-(1100,600) on the canvas
+			name:     "parent-relative existing note",
+			incoming: []canvusapi.Note{syncNote("", "Buy milk", yellow, 1100, 500, 200)},
+			existing: []ExistingNote{{Note: syncNote("a", "Buy milk", yellow, 0, 0, 50), Parent: Frame{X: 1000, Y: 500, Scale: 2}}},
+			want:     []SyncAction{SyncUpdate},
+			changes:  [][]string{{"moved", "resized"}},
+			check: func(t *testing.T, plan *SyncPlan) {
+				p := plan.Changes[0].Note
+				if p.Location == nil || p.Location.X != 50 || p.Location.Y != 0 {
+					t.Errorf("got location %+v, want (50,0) in the anchor", p.Location)
+				}
+				if p.Size == nil || p.Size.Width != 100 || p.Size.Height != 100 || p.ScaleValue() != 1 {
+					t.Errorf("got size %+v scale %v, want 100x100 at scale 1 in the anchor", p.Size, p.ScaleValue())
+				}
+			},
+		},
+		{
+			name:     "incoming notes in a scaled frame",
+			incoming: []canvusapi.Note{syncNote("", "Buy milk", yellow, 50, 50, 50)},
+			frame:    Frame{Scale: 2},
+			existing: []ExistingNote{{Note: syncNote("a", "Buy milk", yellow, 100, 100, 100), Parent: CanvasFrame}},
+			want:     []SyncAction{SyncUnchanged},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame := tt.frame
+			if frame == (Frame{}) {
+				frame = CanvasFrame
+			}
+			plan := PlanSync(tt.incoming, frame, tt.existing, tt.opts)
+			if len(plan.Changes) != len(tt.want) {
+				t.Fatalf("got %d changes %+v, want %v", len(plan.Changes), plan.Changes, tt.want)
+			}
+			for k, action := range tt.want {
+				if got := plan.Changes[k].Action; got != action {
+					t.Errorf("change %d: got %s, want %s", k, got, action)
+				}
+			}
+			for k, changes := range tt.changes {
+				if got := strings.Join(plan.Changes[k].Changes, ","); got != strings.Join(changes, ",") {
+					t.Errorf("change %d: got changes %q, want %q", k, got, changes)
+				}
+			}
+			if tt.check != nil {
+				tt.check(t, plan)
+			}
+		})
+	}
+}
+
+func TestApplySync(t *testing.T) {
+	srv := newNoteServer(t, "canvas-1")
+	srv.put("moved", map[string]interface{}{"text": "Moved", "background_color": "#FFFF88"})
+	srv.put("rejected", map[string]interface{}{"text": "Rejected"})
+	srv.fail = func(r *http.Request, n int) int {
+		if r.Method == http.MethodPatch && strings.HasSuffix(r.URL.Path, "/rejected") {
+			return http.StatusBadRequest
+		}
+		return 0
+	}
+
+	moveTo := &canvusapi.Note{}
+	moveTo.Location = &canvusapi.Location{X: 10, Y: 20}
+	plan := &SyncPlan{
+		Changes: []SyncChange{
+			{Action: SyncCreate, Index: 0, Note: &canvusapi.Note{Text: "New"}},
+			{Action: SyncUpdate, Index: 1, ID: "moved", Note: moveTo},
+			{Action: SyncUpdate, Index: 2, ID: "rejected", Note: &canvusapi.Note{Text: "Edited"}},
+			{Action: SyncUnchanged, Index: 3, ID: "same"},
+			{Action: SyncDelete, Index: -1, ID: "rejected"},
+			{Action: SyncDelete, Index: -1, ID: "already-gone"},
+			{Action: SyncMissing, Index: -1, ID: "left"},
+		},
+		Create: 1, Update: 2, Unchanged: 1, Delete: 2, Missing: 1,
+	}
+	client := NewClient(srv.URL, "test-key", "canvas-1")
+	batch, err := client.ApplySync(context.Background(), "canvas-1", plan)
+	if err != nil {
+		t.Fatalf("ApplySync: %v", err)
+	}
+	if batch == nil || len(batch.CreatedIDs) != 1 || plan.Changes[0].ID != batch.CreatedIDs[0] || plan.Changes[0].Result == nil {
+		t.Errorf("got batch %+v and create %+v, want the created note's ID on the change", batch, plan.Changes[0])
+	}
+	if r := plan.Changes[1].Result; r == nil || r.Location == nil || r.Location.X != 10 || r.Text != "Moved" {
+		t.Errorf("got update result %+v, want the moved note", r)
+	}
+	if plan.Changes[2].Error == "" || plan.Failed != 1 {
+		t.Errorf("got error %q and %d failed, want the rejected update as the only failure", plan.Changes[2].Error, plan.Failed)
+	}
+	if plan.Changes[5].Error != "" {
+		t.Errorf("deleting a note that is already gone failed: %s", plan.Changes[5].Error)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	want := []string{
+		"POST /notes",
+		"PATCH /notes/moved",
+		"PATCH /notes/rejected",
+		"DELETE /notes/rejected",
+		"DELETE /notes/already-gone",
+	}
+	if got := strings.Join(srv.requests, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("got requests\n%s\nwant\n%s", got, strings.Join(want, "\n"))
+	}
+	if _, ok := srv.notes["rejected"]; ok {
+		t.Error("note rejected was not deleted")
+	}
+}
+
+func TestApplySyncCancelled(t *testing.T) {
+	srv := newNoteServer(t, "canvas-1")
+	plan := &SyncPlan{Changes: []SyncChange{
+		{Action: SyncCreate, Index: 0, Note: &canvusapi.Note{Text: "New"}},
+		{Action: SyncUpdate, Index: 1, ID: "a", Note: &canvusapi.Note{Text: "Edited"}},
+		{Action: SyncDelete, Index: -1, ID: "b"},
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewClient(srv.URL, "test-key", "canvas-1").ApplySync(ctx, "canvas-1", plan); err != nil {
+		t.Fatalf("ApplySync: %v", err)
+	}
+	if plan.Failed != 3 {
+		t.Errorf("got %d failed, want every step not attempted", plan.Failed)
+	}
+	for _, c := range plan.Changes {
+		if !strings.HasPrefix(c.Error, "not attempted") {
+			t.Errorf("%s %s: got error %q", c.Action, c.ID, c.Error)
+		}
+	}
+	if len(srv.requests) != 0 {
+		t.Errorf("got requests %v after cancellation", srv.requests)
+	}
+}
@@ -0,0 +1,123 @@
+package scans
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jaypaulb/CanvusNoteMapper/internal/canvusapi"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/ids"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/image"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/llm"
+	"github.com/jaypaulb/CanvusNoteMapper/internal/mapping"
+)
+
+// DefaultTTL is how long a scan is kept after its last update
+const DefaultTTL = time.Hour
+
+// ErrNotFound is returned for unknown or expired scan IDs
+var ErrNotFound = errors.New("scan not found or expired")
+
+// Zone holds the target canvas/anchor chosen for a scan
+type Zone struct {
+	CanvasID string `json:"canvasID,omitempty"`
+	AnchorID string `json:"zoneID,omitempty"`
+}
+
+// Scan is the state of one person's scan: the uploaded image, the
+// extraction result and the zone it will be imported into
+type Scan struct {
+	ID        string
+	Image     *image.ProcessedImage
+	Notes     []canvusapi.Note     // extracted notes in MCS format (image pixel coordinates)
+	Edges     []llm.Edge           // lines between notes, by index into Notes
+	Colors    []mapping.ColorMatch // extracted colors and the palette colors they were snapped to, by note index
+	Zone      Zone
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Store keeps scans in memory, evicting them after a period of inactivity
+type Store struct {
+	mu    sync.Mutex
+	scans map[string]*Scan
+	ttl   time.Duration
+}
+
+// NewStore creates a store whose scans expire ttl after their last update
+func NewStore(ttl time.Duration) *Store {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Store{scans: make(map[string]*Scan), ttl: ttl}
+}
+
+// TTL returns how long a scan is kept after its last update
+func (s *Store) TTL() time.Duration {
+	return s.ttl
+}
+
+// Create starts a new, empty scan
+func (s *Store) Create(zone Zone) Scan {
+	now := time.Now()
+	scan := &Scan{ID: ids.New(), Zone: zone, CreatedAt: now, UpdatedAt: now}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictLocked(now)
+	s.scans[scan.ID] = scan
+	return *scan
+}
+
+// Get returns a copy of the scan with the given ID
+func (s *Store) Get(id string) (Scan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scan, ok := s.liveLocked(id, time.Now())
+	if !ok {
+		return Scan{}, ErrNotFound
+	}
+	return *scan, nil
+}
+
+// Update applies fn to the scan under the store lock and returns the updated copy
+func (s *Store) Update(id string, fn func(scan *Scan)) (Scan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	scan, ok := s.liveLocked(id, now)
+	if !ok {
+		return Scan{}, ErrNotFound
+	}
+	fn(scan)
+	scan.UpdatedAt = now
+	return *scan, nil
+}
+
+// Delete removes a scan
+func (s *Store) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.scans, id)
+}
+
+// liveLocked returns the scan if it exists and has not expired. s.mu must be held.
+func (s *Store) liveLocked(id string, now time.Time) (*Scan, bool) {
+	scan, ok := s.scans[id]
+	if !ok {
+		return nil, false
+	}
+	if now.Sub(scan.UpdatedAt) > s.ttl {
+		delete(s.scans, id)
+		return nil, false
+	}
+	return scan, true
+}
+
+// evictLocked drops expired scans. s.mu must be held.
+func (s *Store) evictLocked(now time.Time) {
+	for id, scan := range s.scans {
+		if now.Sub(scan.UpdatedAt) > s.ttl {
+			delete(s.scans, id)
+		}
+	}
+}
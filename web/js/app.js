@@ -34,6 +34,9 @@ window.addEventListener('DOMContentLoaded', () => {
     // Default to dark mode
     setDarkMode(localStorage.getItem('darkmode') !== '0');
 
+    // --- Server-side Configuration (persisted credentials and defaults) ---
+    let serverConfig = {};
+
     // --- Persist and Restore Credentials ---
     const mcsServer = localStorage.getItem('mcsServer');
     const apiKey = localStorage.getItem('apiKey');
@@ -43,6 +46,61 @@ window.addEventListener('DOMContentLoaded', () => {
     // --- Credentials ---
     const credentialsForm = document.getElementById('credentials-form');
     const credentialsStatus = document.getElementById('credentials-status');
+    const tlsInsecure = document.getElementById('tls-insecure');
+
+    // TLS settings for self-signed MCS servers
+    function tlsSettings() {
+        return {
+            caFile: document.getElementById('tls-ca-file').value.trim(),
+            fingerprint: document.getElementById('tls-fingerprint').value.trim(),
+            insecureSkipVerify: tlsInsecure.checked
+        };
+    }
+    tlsInsecure.addEventListener('change', () => {
+        if (tlsInsecure.checked && !confirm('Disabling certificate verification lets anyone on the network intercept your API key. Only use this for testing. Continue?')) {
+            tlsInsecure.checked = false;
+        }
+    });
+
+    // Test the connection with the entered settings without saving them
+    document.getElementById('test-connection').addEventListener('click', async () => {
+        credentialsStatus.textContent = 'Testing connection...';
+        try {
+            const res = await fetch('/api/test-connection', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    mcsServer: document.getElementById('mcs-server').value,
+                    apiKey: document.getElementById('api-key').value,
+                    tls: tlsSettings()
+                })
+            });
+            const data = await res.json();
+            credentialsStatus.innerHTML = '';
+            if (data.status === 'ok') {
+                const c = data.connection;
+                credentialsStatus.textContent = `Connected: ${c.canvases} canvases` + (c.tlsVersion ? ` (${c.tlsVersion}, certificate ${c.subject})` : '');
+            } else {
+                credentialsStatus.textContent = `Connection failed (${data.kind || 'error'}): ${data.error}`;
+                if (data.certificate) {
+                    const info = document.createElement('div');
+                    info.textContent = `Server certificate: ${data.certificate.subject}, issued by ${data.certificate.issuer}, SHA-256 ${data.certificate.fingerprint}. Only pin it after checking it with your MCS administrator.`;
+                    const pin = document.createElement('button');
+                    pin.type = 'button';
+                    pin.textContent = 'Pin this certificate';
+                    pin.addEventListener('click', () => {
+                        document.getElementById('tls-fingerprint').value = data.certificate.fingerprint;
+                        document.getElementById('tls-options').open = true;
+                    });
+                    credentialsStatus.append(info, pin);
+                }
+            }
+            if (data.warning) credentialsStatus.append(document.createElement('br'), 'Warning: ' + data.warning);
+        } catch (err) {
+            credentialsStatus.textContent = 'Connection test failed: ' + err;
+        }
+    });
+
     credentialsForm.addEventListener('submit', async (e) => {
         e.preventDefault();
         const mcsServer = document.getElementById('mcs-server').value;
@@ -52,10 +110,11 @@ window.addEventListener('DOMContentLoaded', () => {
         const res = await fetch('/api/set-credentials', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ mcsServer, apiKey })
+            body: JSON.stringify({ mcsServer, apiKey, tls: tlsSettings() })
         });
         const data = await res.json();
         credentialsStatus.textContent = data.status === 'ok' ? 'Credentials saved.' : (data.error || 'Error');
+        if (data.warning) credentialsStatus.textContent += ' Warning: ' + data.warning;
         if (data.status === 'ok') {
             await fetchCanvases();
             // Switch to Scan tab
@@ -100,6 +159,9 @@ window.addEventListener('DOMContentLoaded', () => {
                 });
             }
             if (canvasSelect.options.length > 0) {
+                if (serverConfig.defaultCanvasID && data.canvases.some(c => c.id === serverConfig.defaultCanvasID)) {
+                    canvasSelect.value = serverConfig.defaultCanvasID;
+                }
                 await fetchAnchors(canvasSelect.value);
             } else {
                 anchorStatus.textContent = 'No canvases found. Please check your credentials or server connection.';
@@ -124,18 +186,17 @@ window.addEventListener('DOMContentLoaded', () => {
             }
             const data = await res.json();
             if (Array.isArray(data.anchors)) {
-                anchorData = data.anchors.map(a => ({
-                    id: a.id,
-                    name: a.name
-                }));
+                anchorData = data.anchors;
                 anchorSelect.innerHTML = '';
                 anchorData.forEach(a => {
                     const opt = document.createElement('option');
                     opt.value = a.id;
-                    opt.textContent = a.name;
+                    opt.textContent = anchorLabel(a);
                     anchorSelect.appendChild(opt);
                 });
-                anchorStatus.textContent = '';
+                anchorStatus.textContent = anchorData.length === 0
+                    ? 'No anchors on this canvas. Use "Create in New Anchor" to make one in free space.'
+                    : '';
             } else {
                 anchorStatus.textContent = 'No anchors found for this canvas.';
             }
@@ -145,6 +206,13 @@ window.addEventListener('DOMContentLoaded', () => {
         updateCanvasAnchorInfo();
     }
 
+    // Label an anchor with where it appears on the canvas, including its and its parents' scale
+    function anchorLabel(anchor) {
+        const box = anchor.absolute || { x: anchor.x, y: anchor.y, width: anchor.width * (anchor.scale || 1), height: anchor.height * (anchor.scale || 1) };
+        const pinned = anchor.pinned ? ', pinned' : '';
+        return `${anchor.name} [${Math.round(box.width)}x${Math.round(box.height)} @ ${Math.floor(box.x)}, ${Math.floor(box.y)}${pinned}]`;
+    }
+
     async function fetchAnchorInfo(canvasID, anchorID) {
         anchorStatus.textContent = 'Refreshing anchor details...';
         try {
@@ -180,9 +248,7 @@ window.addEventListener('DOMContentLoaded', () => {
         const anchorID = anchorOption.value;
         const anchor = await fetchAnchorInfo(canvasID, anchorID);
         if (anchor) {
-            const x = Math.floor(anchor.x);
-            const y = Math.floor(anchor.y);
-            anchorOption.textContent = `${anchor.name} [${anchor.width}x${anchor.height} @ ${x}, ${y}]`;
+            anchorOption.textContent = anchorLabel(anchor);
         } else {
             anchorOption.textContent = anchorOption.value;
         }
@@ -208,9 +274,58 @@ window.addEventListener('DOMContentLoaded', () => {
     const preview = document.getElementById('preview');
     const uploadBtn = document.getElementById('upload-btn');
     let uploadedImage = null;
+    let uploadedPhotos = [];
     let lastScanData = null;
     let selectedNotes = [];
     const imageInputLabel = document.getElementById('image-input-label');
+    const cancelJobBtn = document.getElementById('cancel-job');
+    const autoPerspective = document.getElementById('auto-perspective');
+    const hybridMode = document.getElementById('hybrid-mode');
+    // Extraction mode for uploads: "hybrid" reads cropped notes, "full" the whole photo
+    const extractionMode = () => (hybridMode && hybridMode.checked ? 'hybrid' : 'full');
+    const tiledMode = document.getElementById('tiled-mode');
+    const attachToAnchor = document.getElementById('attach-to-anchor');
+    const sourceImage = document.getElementById('source-image');
+    let currentJobID = null;
+
+    // --- Scan Jobs ---
+    // Upload and scan run as background jobs; follow progress via Server-Sent Events.
+    // Resolves with the job result, or with { error } if the job failed or was cancelled.
+    function followJob(accepted) {
+        if (!accepted || !accepted.jobID) return Promise.resolve(accepted);
+        currentJobID = accepted.jobID;
+        if (cancelJobBtn) cancelJobBtn.style.display = 'inline-block';
+        return new Promise((resolve) => {
+            const events = new EventSource(accepted.eventsURL || `/api/jobs/${accepted.jobID}/events`);
+            const finish = (result) => {
+                events.close();
+                currentJobID = null;
+                if (cancelJobBtn) cancelJobBtn.style.display = 'none';
+                resolve(result);
+            };
+            events.addEventListener('progress', (e) => {
+                const job = JSON.parse(e.data);
+                const pct = Math.round((job.progress || 0) * 100);
+                imageStatus.textContent = job.stage ? `Processing: ${job.stage} (${pct}%)...` : 'Queued...';
+            });
+            events.addEventListener('complete', (e) => finish(JSON.parse(e.data).result || {}));
+            events.addEventListener('failed', (e) => finish({ error: JSON.parse(e.data).error || 'Job failed' }));
+            events.addEventListener('cancelled', () => finish({ error: 'Cancelled' }));
+            // EventSource reconnects on its own after network drops; the server replays the current state
+            events.onerror = () => console.warn('[followJob] Event stream interrupted, reconnecting...');
+        });
+    }
+
+    if (cancelJobBtn) {
+        cancelJobBtn.addEventListener('click', async () => {
+            if (!currentJobID) return;
+            try {
+                await fetch(`/api/jobs/${currentJobID}/cancel`, { method: 'POST' });
+            } catch (err) {
+                console.error('[cancelJob] Failed to cancel job:', err);
+            }
+        });
+    }
 
     // Debug: Check if elements are found
     console.log('[DOM Check] imageInput:', imageInput);
@@ -239,11 +354,15 @@ window.addEventListener('DOMContentLoaded', () => {
             };
             reader.readAsDataURL(file);
             uploadedImage = file;
+            // Several photos of a wide board are stitched, left to right in file name order
+            uploadedPhotos = Array.from(e.target.files).sort((a, b) => a.name.localeCompare(b.name, undefined, { numeric: true }));
             
             // Show upload button
             console.log('[imageInput] Showing upload button');
             uploadBtn.style.display = 'inline-block';
-            imageStatus.textContent = 'File selected. Click Upload to process.';
+            imageStatus.textContent = uploadedPhotos.length > 1
+                ? `${uploadedPhotos.length} photos selected. Click Upload to stitch and process them.`
+                : 'File selected. Click Upload to process.';
             console.log('[imageInput] Upload button display style:', uploadBtn.style.display);
         } else {
             console.log('[imageInput] No file selected');
@@ -259,26 +378,16 @@ window.addEventListener('DOMContentLoaded', () => {
 
         imageStatus.textContent = 'Uploading and processing...';
         const formData = new FormData();
-        formData.append('image', uploadedImage);
-        
-        // Get selected anchor info for zone parameters
-        const anchorOption = anchorSelect.options[anchorSelect.selectedIndex];
-        let zoneWidth = 640, zoneHeight = 480, zoneX = 0, zoneY = 0, zoneScale = 1;
-        if (anchorOption) {
-            const anchor = anchorData.find(a => a.id === anchorOption.value);
-            if (anchor) {
-                zoneWidth = Math.round(anchor.width);
-                zoneHeight = Math.round(anchor.height);
-                zoneX = Math.round(anchor.x);
-                zoneY = Math.round(anchor.y);
-                zoneScale = anchor.scale !== undefined ? Math.round(anchor.scale * 100) / 100 : 1;
-            }
+        if (uploadedPhotos.length > 1) {
+            uploadedPhotos.forEach(photo => formData.append('image', photo));
+        } else {
+            formData.append('image', uploadedImage);
         }
-        
-        // Add zone parameters to the upload request
-        formData.append('zoneDimensions', JSON.stringify([zoneWidth, zoneHeight]));
-        formData.append('zoneLocation', JSON.stringify([zoneX, zoneY]));
-        formData.append('zoneScale', JSON.stringify(zoneScale));
+        formData.append('canvasID', canvasSelect.value || '');
+        formData.append('zoneID', anchorSelect.value || '');
+        formData.append('autoPerspective', autoPerspective && autoPerspective.checked ? 'true' : 'false');
+        formData.append('mode', extractionMode());
+        formData.append('tiled', tiledMode && tiledMode.checked ? 'true' : 'false');
         
         try {
             console.log('[uploadBtn] Starting upload and processing...');
@@ -294,7 +403,7 @@ window.addEventListener('DOMContentLoaded', () => {
                 throw new Error(`HTTP error! status: ${res.status}, response: ${errorText}`);
             }
             
-            const data = await res.json();
+            const data = await followJob(await res.json());
             console.log('[uploadBtn] Response data:', data);
             
             if (data.status === 'complete' && data.notes) {
@@ -308,7 +417,7 @@ window.addEventListener('DOMContentLoaded', () => {
                 console.log('[uploadBtn] Calling renderThumbnails with notes:', data.notes);
                 renderThumbnails(data.notes || []);
                 
-                imageStatus.textContent = `Processing complete. Found ${data.notes.length} notes.`;
+                imageStatus.textContent = `Processing complete. Found ${data.notes.length} notes.` + (data.warning ? ` ${data.warning}` : '');
                 console.log('[uploadBtn] Updated image status');
             } else if (data.error) {
                 console.log('[uploadBtn] Upload failed with error:', data.error);
@@ -330,6 +439,11 @@ window.addEventListener('DOMContentLoaded', () => {
     const deselectAllBtn = document.getElementById('deselect-all');
     const createBtn = document.getElementById('create-notes');
     const createStatus = document.getElementById('create-status');
+    const undoImportBtn = document.getElementById('undo-import');
+    const syncBtn = document.getElementById('sync-notes');
+    const createAnchorBtn = document.getElementById('create-anchor');
+    const syncDeleteMissing = document.getElementById('sync-delete-missing');
+    let lastImportID = null;
 
     function renderThumbnails(notes) {
         console.log('[renderThumbnails] Called with notes:', notes);
@@ -340,12 +454,16 @@ window.addEventListener('DOMContentLoaded', () => {
             console.log('[renderThumbnails] No notes to render, hiding interface');
             if (scanResultsContainer) scanResultsContainer.style.display = 'none';
             if (createBtn) createBtn.style.display = 'none';
+            if (syncBtn) syncBtn.style.display = 'none';
+            if (createAnchorBtn) createAnchorBtn.style.display = 'none';
             return;
         }
         
         console.log('[renderThumbnails] Showing scan results container');
         if (scanResultsContainer) scanResultsContainer.style.display = '';
         if (createBtn) createBtn.style.display = '';
+        if (syncBtn) syncBtn.style.display = '';
+        if (createAnchorBtn) createAnchorBtn.style.display = '';
         if (thumbnailsDiv) thumbnailsDiv.innerHTML = '';
         
         // If selectedNotes is empty, select all by default
@@ -360,6 +478,11 @@ window.addEventListener('DOMContentLoaded', () => {
             const thumb = document.createElement('div');
             thumb.className = 'thumbnail';
             if (note.background_color) thumb.style.background = note.background_color;
+            // Show the photographed color behind the palette color it was snapped to
+            const color = lastScanData && lastScanData.colors ? lastScanData.colors[idx] : null;
+            if (color && color.raw !== color.color) {
+                thumb.title = `Photo color ${color.raw}, snapped to ${color.name || color.color}`;
+            }
             thumb.innerHTML = `
                 <input type="checkbox" class="note-checkbox" data-idx="${idx}" ${selectedNotes.includes(idx) ? 'checked' : ''}>
                 <div><strong>${note.text || 'Note'}</strong></div>
@@ -697,25 +820,14 @@ window.addEventListener('DOMContentLoaded', () => {
                 stream = null;
             }
             
-            // Get selected anchor info for zone parameters
-            const anchorOption = anchorSelect.options[anchorSelect.selectedIndex];
-            let zoneWidth = 640, zoneHeight = 480, zoneX = 0, zoneY = 0, zoneScale = 1;
-            if (anchorOption) {
-                const anchor = anchorData.find(a => a.id === anchorOption.value);
-                if (anchor) {
-                    zoneWidth = Math.round(anchor.width);
-                    zoneHeight = Math.round(anchor.height);
-                    zoneX = Math.round(anchor.x);
-                    zoneY = Math.round(anchor.y);
-                    zoneScale = anchor.scale !== undefined ? Math.round(anchor.scale * 100) / 100 : 1;
-                }
-            }
-            
             const formData = new FormData();
             formData.append('image', blob, 'capture.png');
-            formData.append('zoneDimensions', JSON.stringify([zoneWidth, zoneHeight]));
-            formData.append('zoneLocation', JSON.stringify([zoneX, zoneY]));
-            formData.append('zoneScale', JSON.stringify(zoneScale));
+            formData.append('canvasID', canvasSelect.value || '');
+            formData.append('zoneID', anchorSelect.value || '');
+            formData.append('autoPerspective', autoPerspective && autoPerspective.checked ? 'true' : 'false');
+            formData.append('mode', extractionMode());
+            formData.append('tiled', tiledMode && tiledMode.checked ? 'true' : 'false');
+        formData.append('tiled', tiledMode && tiledMode.checked ? 'true' : 'false');
             
             try {
                 const res = await fetch('/api/upload-image', {
@@ -728,11 +840,11 @@ window.addEventListener('DOMContentLoaded', () => {
                     throw new Error(`HTTP error! status: ${res.status}, response: ${errorText}`);
                 }
                 
-                const data = await res.json();
+                const data = await followJob(await res.json());
                 if (data.status === 'complete' && data.notes) {
                     lastScanData = data;
                     renderThumbnails(data.notes || []);
-                    imageStatus.textContent = `Processing complete. Found ${data.notes.length} notes.`;
+                    imageStatus.textContent = `Processing complete. Found ${data.notes.length} notes.` + (data.warning ? ` ${data.warning}` : '');
                 } else if (data.error) {
                     imageStatus.textContent = 'Processing failed: ' + data.error;
                 } else {
@@ -746,39 +858,81 @@ window.addEventListener('DOMContentLoaded', () => {
     });
 
     // --- Create Notes ---
-    async function createNotes() {
+    // Builds the request shared by create and sync, or reports why it cannot be sent.
+    // With newAnchor no zone is needed: the server creates one.
+    async function notesRequest(newAnchor = false) {
         if (!lastScanData || selectedNotes.length === 0) {
             createStatus.textContent = 'Select at least one note.';
-            return;
+            return null;
         }
         const canvasID = canvasSelect.value;
-        const zoneID = anchorSelect.value;
-        if (!canvasID || !zoneID) {
-            createStatus.textContent = 'Please select both a canvas and a zone before creating notes.';
-            return;
+        const zoneID = newAnchor ? '' : anchorSelect.value;
+        if (!canvasID) {
+            createStatus.textContent = 'Please select a canvas before creating notes.';
+            return null;
         }
-        const anchor = await fetchAnchorInfo(canvasID, zoneID);
-        if (!anchor) {
-            createStatus.textContent = 'Failed to refresh anchor details.';
-            return;
+        if (!newAnchor) {
+            if (!zoneID) {
+                createStatus.textContent = 'Please select both a canvas and a zone before creating notes.';
+                return null;
+            }
+            const anchor = await fetchAnchorInfo(canvasID, zoneID);
+            if (!anchor) {
+                createStatus.textContent = 'Failed to refresh anchor details.';
+                return null;
+            }
         }
         // Send notes as detected (raw), backend will handle transformation
         const notesToSend = selectedNotes.map(i => (lastScanData.notes ? lastScanData.notes[i] : lastScanData[i]));
+        // Edges refer to notes by index, so renumber them for the selection and drop those to unselected notes
+        const position = new Map(selectedNotes.map((noteIndex, i) => [noteIndex, i]));
+        const edgesToSend = (lastScanData.edges || [])
+            .filter(e => position.has(e.source) && position.has(e.target))
+            .map(e => ({ ...e, source: position.get(e.source), target: position.get(e.target) }));
+        return {
+            scanID: lastScanData.scanID,
+            canvasID,
+            zoneID,
+            notes: notesToSend,
+            edges: edgesToSend,
+            placement: attachToAnchor && attachToAnchor.checked ? 'parent' : 'absolute',
+            imageWidth: lastScanData.imageWidth,
+            imageHeight: lastScanData.imageHeight
+        };
+    }
+
+    // Create the selected notes in the chosen anchor, or in a new anchor placed in free canvas space
+    async function createNotes(newAnchor = false) {
+        const body = await notesRequest(newAnchor);
+        if (!body) return;
+        if (sourceImage.checked) {
+            body.sourceImage = {
+                position: document.getElementById('source-image-position').value,
+                pinned: document.getElementById('source-image-pinned').checked
+            };
+        }
         try {
-            const res = await fetch('/api/create-notes', {
+            const res = await fetch(newAnchor ? '/api/create-anchor' : '/api/create-notes', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({
-                    canvasID,
-                    zoneID,
-                    notes: notesToSend,
-                    imageWidth: lastScanData.imageWidth,
-                    imageHeight: lastScanData.imageHeight
-                })
+                body: JSON.stringify(body)
             });
             const data = await res.json();
+            if (data.importID) {
+                lastImportID = data.importID;
+                undoImportBtn.style.display = '';
+            }
             if (res.ok && data.status) {
                 createStatus.textContent = data.status;
+                if (data.sourceImage && data.sourceImage.error) {
+                    createStatus.textContent += ` (photo not placed: ${data.sourceImage.error})`;
+                }
+                if (data.anchor) {
+                    createStatus.textContent += ` in new anchor "${data.anchor.name}"`;
+                    await fetchAnchors(body.canvasID);
+                    anchorSelect.value = data.anchor.id;
+                    updateCanvasAnchorInfo();
+                }
             } else {
                 createStatus.textContent = data.error || 'Error creating notes. Please check your canvas/zone selection and try again.';
             }
@@ -787,8 +941,118 @@ window.addEventListener('DOMContentLoaded', () => {
             createStatus.textContent = 'Network or server error while creating notes.';
         }
     }
+
+    // Sync with the notes imported into the anchor before: show the planned changes, then apply them
+    async function syncNotes() {
+        const body = await notesRequest();
+        if (!body) return;
+        body.deleteMissing = syncDeleteMissing.checked;
+        const post = payload => fetch('/api/sync-notes', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(payload)
+        });
+        try {
+            createStatus.textContent = 'Comparing with imported notes...';
+            let res = await post({ ...body, dryRun: true });
+            let data = await res.json();
+            if (!res.ok) {
+                createStatus.textContent = data.error || 'Failed to compare with imported notes.';
+                return;
+            }
+            const plan = data.plan;
+            const summary = `${plan.create} new, ${plan.update} changed, ${plan.unchanged} unchanged` +
+                (plan.delete ? `, ${plan.delete} to delete` : '') +
+                (plan.missing ? `, ${plan.missing} no longer in the photo (kept)` : '');
+            if (plan.create + plan.update + plan.delete === 0) {
+                createStatus.textContent = `Nothing to sync: ${summary}.`;
+                return;
+            }
+            if (!confirm(`Sync will make these changes: ${summary}. Continue?`)) {
+                createStatus.textContent = 'Sync cancelled.';
+                return;
+            }
+            res = await post(body);
+            data = await res.json();
+            if (data.importID) {
+                lastImportID = data.importID;
+                undoImportBtn.style.display = '';
+            }
+            createStatus.textContent = res.ok ? `${data.status}: ${summary}` : (data.error || 'Failed to sync notes.');
+        } catch (err) {
+            console.error('[Sync Notes] Network or server error:', err);
+            createStatus.textContent = 'Network or server error while syncing notes.';
+        }
+    }
+    syncBtn.addEventListener('click', syncNotes);
+
     if (!createBtn._bound) {
-        createBtn.addEventListener('click', createNotes);
+        createBtn.addEventListener('click', () => createNotes());
         createBtn._bound = true;
     }
+    createAnchorBtn.addEventListener('click', () => createNotes(true));
+
+    // Undo the most recent import: deletes the notes it created, leaving any that were moved or edited since
+    undoImportBtn.addEventListener('click', async () => {
+        if (!lastImportID) return;
+        try {
+            let res = await fetch(`/api/imports/${lastImportID}/undo`, { method: 'POST' });
+            let data = await res.json();
+            if (!res.ok) {
+                createStatus.textContent = data.error || 'Failed to undo import.';
+                return;
+            }
+            const modified = data.skipped.filter(s => s.reason === 'modified since import').length;
+            if (modified > 0 && confirm(`${modified} note(s) were moved or edited since the import. Delete them too?`)) {
+                res = await fetch(`/api/imports/${lastImportID}/undo?force=true`, { method: 'POST' });
+                const forced = await res.json();
+                forced.deleted = data.deleted.concat(forced.deleted || []);
+                data = forced;
+            }
+            createStatus.textContent = `Import ${data.status}: ${data.deleted.length} note(s) deleted` +
+                (data.failed.length ? `, ${data.failed.length} failed` : '');
+            if (data.status === 'undone') {
+                lastImportID = null;
+                undoImportBtn.style.display = 'none';
+            }
+        } catch (err) {
+            console.error('[Undo Import] Network or server error:', err);
+            createStatus.textContent = 'Network or server error while undoing import.';
+        }
+    });
+
+    // Load persisted configuration; if credentials are already saved on the server, go straight to scanning
+    (async () => {
+        try {
+            const res = await fetch('/api/get-config');
+            if (!res.ok) return;
+            serverConfig = await res.json();
+            if (serverConfig.mcsServer && !document.getElementById('mcs-server').value) {
+                document.getElementById('mcs-server').value = serverConfig.mcsServer;
+            }
+            if (serverConfig.tls) {
+                document.getElementById('tls-ca-file').value = serverConfig.tls.caFile || '';
+                document.getElementById('tls-fingerprint').value = serverConfig.tls.fingerprint || '';
+                tlsInsecure.checked = !!serverConfig.tls.insecureSkipVerify;
+            }
+            if (autoPerspective && serverConfig.mapping) {
+                autoPerspective.checked = !!serverConfig.mapping.autoPerspective;
+            }
+            if (tiledMode && serverConfig.mapping) {
+                tiledMode.checked = !!serverConfig.mapping.tiled;
+            }
+            if (attachToAnchor && serverConfig.mapping) {
+                attachToAnchor.checked = serverConfig.mapping.placement === 'parent';
+            }
+            if (hybridMode) {
+                hybridMode.checked = serverConfig.llmMode === 'hybrid';
+            }
+            if (serverConfig.mcsServer && serverConfig.hasApiKey) {
+                credentialsStatus.textContent = 'Using saved credentials.';
+                await fetchCanvases();
+            }
+        } catch (err) {
+            console.error('[getConfig] Failed to load server configuration:', err);
+        }
+    })();
 });